@@ -0,0 +1,286 @@
+// Package archive extracts zip and tar archives concurrently.
+//
+// Every entry's path is checked before anything is written, and entries that
+// would land outside of the destination directory, like "../../etc/passwd"
+// or "/etc/passwd", fail with ErrUnsafePath. Symbolic links, hard links and
+// device files are never created, since a link extracted early can redirect
+// later entries outside of the destination just as well; they fail with
+// ErrUnsupportedEntry.
+//
+// By default the first entry that fails stops extraction, like
+// spara.RunWithContext. The OnEntryError option changes that policy.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/heyimalex/spara"
+	"github.com/heyimalex/spara/internal/ctxio"
+)
+
+var (
+	ErrUnsafePath       = errors.New("archive: entry path escapes the destination")
+	ErrUnsupportedEntry = errors.New("archive: unsupported entry type")
+	ErrNilReader        = errors.New("archive: reader must not be nil")
+)
+
+// EntryError is the error for a single entry that failed to extract.
+type EntryError struct {
+	Name string
+	Err  error
+}
+
+func (e *EntryError) Error() string {
+	return "archive: " + e.Name + ": " + e.Err.Error()
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// Option configures ExtractZip and ExtractTar.
+type Option func(*config)
+
+type config struct {
+	onError func(err *EntryError) error
+}
+
+// OnEntryError sets the policy for entries that fail to extract. fn is called
+// with each failure, and extraction carries on if it returns nil, or stops
+// with whatever error it returns. Calls may be concurrent. A partially
+// written file is left in place for fn to deal with.
+func OnEntryError(fn func(err *EntryError) error) Option {
+	return func(c *config) {
+		c.onError = fn
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// fail applies the error policy to an entry's error.
+func (c *config) fail(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	entryErr := &EntryError{Name: name, Err: err}
+	if c.onError == nil {
+		return entryErr
+	}
+	return c.onError(entryErr)
+}
+
+// ExtractZip extracts every entry of r into the directory dst, across up to
+// workers goroutines. Zip archives can be read at random, so entries are
+// both read and written concurrently. If several entries have the same name,
+// only the last one is extracted, since that's the one that would be left
+// after extracting them in order.
+func ExtractZip(parent context.Context, workers int, r *zip.Reader, dst string, opts ...Option) error {
+	if r == nil {
+		return ErrNilReader
+	}
+	c := newConfig(opts)
+	files := lastZipEntries(r.File)
+	return spara.RunWithContext(parent, workers, len(files), func(ctx context.Context, i int) error {
+		f := files[i]
+		return c.fail(f.Name, extractZipFile(ctx, f, dst))
+	})
+}
+
+// lastZipEntries returns files without the entries that a later entry of the
+// same name replaces, keeping them in order.
+func lastZipEntries(files []*zip.File) []*zip.File {
+	last := make(map[string]int, len(files))
+	for i, f := range files {
+		last[entryKey(f.Name)] = i
+	}
+	if len(last) == len(files) {
+		return files
+	}
+	kept := make([]*zip.File, 0, len(last))
+	for i, f := range files {
+		if last[entryKey(f.Name)] == i {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// entryKey returns the path an entry name refers to, so that names which
+// only differ in form, like "dir/" and "dir" or "a/./b" and "a/b", compare
+// equal.
+func entryKey(name string) string {
+	return filepath.Clean(filepath.FromSlash(name))
+}
+
+func extractZipFile(ctx context.Context, f *zip.File, dst string) error {
+	target, err := targetPath(dst, f.Name)
+	if err != nil {
+		return err
+	}
+	mode := f.Mode()
+	switch {
+	case mode.IsDir():
+		return os.MkdirAll(target, 0o755)
+	case !mode.IsRegular():
+		return ErrUnsupportedEntry
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return writeFile(ctx, target, mode, rc)
+}
+
+// maxBuffered is the largest tar entry handed off to a worker. Larger
+// entries are written by the goroutine reading the archive, since holding
+// them in memory would cost more than the concurrency saves.
+const maxBuffered = 1 << 20
+
+// ExtractTar extracts every entry of the tar stream r into the directory dst.
+// A tar stream can only be read in order, so reading is sequential, but the
+// entries are written across up to workers goroutines. Small entries are
+// read into memory to hand them off, so up to workers of them are buffered
+// at a time; large ones are written as they're read.
+//
+// Entries with the same name are written one after the other, in the order
+// they appear in the stream, so the last one wins, as with tar -x. The
+// reader can't know whether a name comes up again later without reading the
+// rest of the stream, so it holds back an entry whose name it's already seen
+// until the earlier one has been written.
+//
+// r is read as is, so wrap it in a decompressor like gzip.NewReader first
+// if the archive is compressed.
+func ExtractTar(parent context.Context, workers int, r io.Reader, dst string, opts ...Option) error {
+	if r == nil {
+		return ErrNilReader
+	}
+	if workers <= 0 {
+		return &spara.ValidationError{Param: "workers", Value: workers, Err: spara.ErrInvalidWorkers}
+	}
+	c := newConfig(opts)
+
+	type entry struct {
+		hdr  *tar.Header
+		data []byte
+		done chan struct{} // closed once the entry has been written
+	}
+	entries := make(chan entry)
+	// Worker 0 reads the archive, and the rest write the entries it reads.
+	return spara.Do(parent, workers+1, func(ctx context.Context, worker int) error {
+		if worker > 0 {
+			for {
+				select {
+				case e, ok := <-entries:
+					if !ok {
+						return nil
+					}
+					err := writeTarEntry(ctx, e.hdr, dst, bytes.NewReader(e.data))
+					close(e.done)
+					if err := c.fail(e.hdr.Name, err); err != nil {
+						return err
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+
+		defer close(entries)
+		tr := tar.NewReader(r)
+		// The entry handed off most recently for each name, which a later
+		// entry of the same name has to wait for. The reader is the only
+		// goroutine that uses it.
+		pending := make(map[string]chan struct{})
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			key := entryKey(hdr.Name)
+			if done, ok := pending[key]; ok {
+				select {
+				case <-done:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				delete(pending, key)
+			}
+			if hdr.Typeflag != tar.TypeReg || hdr.Size > maxBuffered {
+				err := writeTarEntry(ctx, hdr, dst, tr)
+				if err := c.fail(hdr.Name, err); err != nil {
+					return err
+				}
+				continue
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			done := make(chan struct{})
+			select {
+			case entries <- entry{hdr, data, done}:
+				pending[key] = done
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}, spara.Workers(workers+1), spara.IgnoreDefaults())
+}
+
+func writeTarEntry(ctx context.Context, hdr *tar.Header, dst string, r io.Reader) error {
+	target, err := targetPath(dst, hdr.Name)
+	if err != nil {
+		return err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, 0o755)
+	case tar.TypeReg:
+		return writeFile(ctx, target, hdr.FileInfo().Mode(), r)
+	default:
+		return ErrUnsupportedEntry
+	}
+}
+
+// targetPath returns where the entry name should be extracted to under dst,
+// or ErrUnsafePath if that isn't inside dst.
+func targetPath(dst, name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", ErrUnsafePath
+	}
+	return filepath.Join(dst, local), nil
+}
+
+func writeFile(ctx context.Context, target string, mode fs.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, ctxio.NewReader(ctx, r))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
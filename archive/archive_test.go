@@ -0,0 +1,179 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/heyimalex/spara"
+)
+
+type testEntry struct {
+	name string
+	body string
+	dir  bool
+}
+
+func testEntries() []testEntry {
+	entries := []testEntry{{name: "empty/", dir: true}}
+	for i := 0; i < 20; i++ {
+		entries = append(entries, testEntry{
+			name: fmt.Sprintf("dir%d/file%d.txt", i%3, i),
+			body: fmt.Sprintf("contents of %d", i),
+		})
+	}
+	return entries
+}
+
+func makeZip(t *testing.T, entries []testEntry) *zip.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		w.Write([]byte(e.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return zr
+}
+
+func makeTar(t *testing.T, entries []testEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.dir {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		tw.Write([]byte(e.body))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return &buf
+}
+
+func checkExtracted(t *testing.T, dst string, entries []testEntry) {
+	t.Helper()
+	for _, e := range entries {
+		path := filepath.Join(dst, filepath.FromSlash(e.name))
+		if e.dir {
+			if info, err := os.Stat(path); err != nil || !info.IsDir() {
+				t.Errorf("expected directory %s: %v", e.name, err)
+			}
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != e.body {
+			t.Errorf("unexpected contents for %s: %q %v", e.name, data, err)
+		}
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	entries := testEntries()
+	dst := t.TempDir()
+	if err := ExtractZip(context.Background(), 4, makeZip(t, entries), dst); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	checkExtracted(t, dst, entries)
+}
+
+func TestExtractTar(t *testing.T) {
+	entries := testEntries()
+	dst := t.TempDir()
+	if err := ExtractTar(context.Background(), 4, makeTar(t, entries), dst); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	checkExtracted(t, dst, entries)
+
+	var verr *spara.ValidationError
+	err := ExtractTar(context.Background(), 0, makeTar(t, entries), t.TempDir())
+	if !errors.As(err, &verr) || verr.Err != spara.ErrInvalidWorkers || verr.Param != "workers" {
+		t.Errorf("expected an invalid workers error: %v", err)
+	}
+}
+
+// TestDuplicateEntries checks that when an archive has several entries with
+// the same name, the last one wins, as it would extracting them in order.
+func TestDuplicateEntries(t *testing.T) {
+	var entries []testEntry
+	for i := 0; i < 50; i++ {
+		entries = append(entries,
+			testEntry{name: "same.txt", body: fmt.Sprintf("version %d", i)},
+			testEntry{name: fmt.Sprintf("other%d.txt", i), body: "other"},
+		)
+	}
+	entries = append(entries, testEntry{name: "./same.txt", body: "last"})
+	want := []testEntry{{name: "same.txt", body: "last"}}
+
+	dst := t.TempDir()
+	if err := ExtractTar(context.Background(), 8, makeTar(t, entries), dst); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	checkExtracted(t, dst, want)
+
+	dst = t.TempDir()
+	if err := ExtractZip(context.Background(), 8, makeZip(t, entries), dst); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	checkExtracted(t, dst, want)
+}
+
+func TestPathTraversal(t *testing.T) {
+	entries := []testEntry{
+		{name: "ok.txt", body: "fine"},
+		{name: "../escape.txt", body: "bad"},
+	}
+	dst := filepath.Join(t.TempDir(), "out")
+
+	err := ExtractZip(context.Background(), 2, makeZip(t, entries), dst)
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("expected ErrUnsafePath from zip: %v", err)
+	}
+	err = ExtractTar(context.Background(), 2, makeTar(t, entries), dst)
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("expected ErrUnsafePath from tar: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "..", "escape.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written outside of the destination: %v", err)
+	}
+}
+
+func TestOnEntryError(t *testing.T) {
+	entries := append(testEntries(), testEntry{name: "/abs.txt", body: "bad"})
+	var mu sync.Mutex
+	var failed []string
+	skip := OnEntryError(func(err *EntryError) error {
+		mu.Lock()
+		failed = append(failed, err.Name)
+		mu.Unlock()
+		return nil
+	})
+	dst := t.TempDir()
+	if err := ExtractTar(context.Background(), 3, makeTar(t, entries), dst, skip); err != nil {
+		t.Fatalf("expected the bad entry to be skipped: %v", err)
+	}
+	checkExtracted(t, dst, entries[:len(entries)-1])
+	if len(failed) != 1 || failed[0] != "/abs.txt" {
+		t.Errorf("expected only /abs.txt to fail: %v", failed)
+	}
+}
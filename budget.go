@@ -0,0 +1,131 @@
+package spara
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrInvalidBudget = errors.New("spara: invalid memory budget")
+
+// MemoryBudget limits the total estimated memory of the items in flight at
+// once, on top of the limit on the number of workers. Before calling the
+// mapping function for an index, a worker asks cost how much memory the item
+// is expected to need, and waits until that much of the budget is free. Runs
+// over items of wildly different sizes can then use plenty of workers for the
+// small ones without running out of memory when several huge ones happen to
+// land at the same time.
+//
+// The units are up to the caller, as long as cost and limit agree. Items are
+// admitted in the order they ask, so a huge item isn't starved by a stream of
+// small ones, and an item that costs more than the whole limit is admitted
+// once it can run alone, rather than never. The budget is held for as long as
+// the item runs, including any retries.
+func MemoryBudget(limit int64, cost func(index int) int64) Option {
+	return func(c *config) {
+		c.budget = limit
+		c.budgetSet = true
+		c.cost = cost
+	}
+}
+
+// withBudget returns middleware that makes every call wait for its share of
+// the budget, or nil if there isn't one.
+func (c *config) withBudget() Middleware {
+	if c == nil || !c.budgetSet {
+		return nil
+	}
+	sem := newWeighted(c.budget)
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			n := c.cost(index)
+			if n < 0 {
+				n = 0
+			} else if n > c.budget {
+				n = c.budget
+			}
+			if err := sem.acquire(ctx, n); err != nil {
+				return err
+			}
+			defer sem.release(n)
+			return fn(ctx, index)
+		}
+	}
+}
+
+// weighted is a semaphore whose holders take differing amounts of it. Waiters
+// are served strictly in order: one that doesn't fit holds up everyone
+// behind it, even those that would.
+type weighted struct {
+	size    int64
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+func newWeighted(size int64) *weighted {
+	return &weighted{size: size}
+}
+
+// acquire takes n from the semaphore, waiting until it's available or ctx is
+// done.
+func (s *weighted) acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	w := waiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// Acquired just as the context completed; give it back.
+			s.cur -= n
+		default:
+			s.waiters.Remove(elem)
+		}
+		// Either way, whoever's at the front now might fit.
+		s.notify()
+		return ctx.Err()
+	}
+}
+
+func (s *weighted) release(n int64) {
+	s.mu.Lock()
+	s.cur -= n
+	s.notify()
+	s.mu.Unlock()
+}
+
+// notify admits waiters from the front of the queue for as long as they fit.
+// Must be called with the lock held.
+func (s *weighted) notify() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(waiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,85 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	const limit = 100
+	costs := []int64{60, 60, 10, 10, 10, 10, 150, 0, -5, 40}
+	var inFlight, peak int64
+	var mu sync.Mutex
+	err := Do(context.Background(), len(costs), func(ctx context.Context, i int) error {
+		cost := min(max(costs[i], 0), limit)
+		mu.Lock()
+		inFlight += cost
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight -= cost
+		mu.Unlock()
+		return nil
+	}, Workers(len(costs)), MemoryBudget(limit, func(i int) int64 { return costs[i] }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peak > limit {
+		t.Errorf("in-flight cost exceeded the budget: %d", peak)
+	}
+
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 1, noop, MemoryBudget(0, func(int) int64 { return 1 })); !errors.Is(err, ErrInvalidBudget) {
+		t.Errorf("expected a zero budget to fail: %v", err)
+	}
+	if err := Do(context.Background(), 1, noop, MemoryBudget(10, nil)); !errors.Is(err, ErrInvalidBudget) {
+		t.Errorf("expected a nil cost function to fail: %v", err)
+	}
+}
+
+func TestWeightedCancel(t *testing.T) {
+	s := newWeighted(10)
+	if err := s.acquire(context.Background(), 8); err != nil {
+		t.Fatal(err)
+	}
+	// A big waiter at the front holds up a small one behind it, until it
+	// gives up.
+	ctx, cancel := context.WithCancel(context.Background())
+	var acquired int32
+	done := make(chan error)
+	go func() { done <- s.acquire(ctx, 5) }()
+	for {
+		s.mu.Lock()
+		n := s.waiters.Len()
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	small := make(chan struct{})
+	go func() {
+		s.acquire(context.Background(), 2)
+		atomic.StoreInt32(&acquired, 1)
+		close(small)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt32(&acquired) != 0 {
+		t.Fatalf("expected the small waiter to queue behind the big one")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected the canceled acquire to fail: %v", err)
+	}
+	<-small
+	s.release(8)
+	s.release(2)
+	if s.cur != 0 {
+		t.Errorf("expected everything to be released: %d", s.cur)
+	}
+}
@@ -0,0 +1,99 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrNilKeyFunction = errors.New("spara: key function must not be nil")
+
+// Cache holds results by key, so that work whose key has already been
+// computed, by this run or an earlier one, doesn't have to be done again.
+// It must be safe for concurrent use. NewCache returns a simple one; anything
+// with eviction, like an LRU, or shared between processes, like memcached, can
+// be plugged in instead.
+type Cache[V any] interface {
+	Get(key string) (value V, ok bool)
+	Add(key string, value V)
+}
+
+// NewCache returns a Cache that keeps everything added to it in memory, for
+// as long as it's around.
+func NewCache[V any]() Cache[V] {
+	return &mapCache[V]{m: make(map[string]V)}
+}
+
+type mapCache[V any] struct {
+	mu sync.RWMutex
+	m  map[string]V
+}
+
+func (c *mapCache[V]) Get(key string) (V, bool) {
+	c.mu.RLock()
+	v, ok := c.m[key]
+	c.mu.RUnlock()
+	return v, ok
+}
+
+func (c *mapCache[V]) Add(key string, value V) {
+	c.mu.Lock()
+	c.m[key] = value
+	c.mu.Unlock()
+}
+
+// MapCached is like Map, for inputs with a lot of overlap between them. Each
+// item has a key, and fn is only called for items whose key isn't already in
+// cache, with the result added to it on success. Items with the same key that
+// are in flight at the same time share one call, as with Dedupe, so a key is
+// never computed twice by the same run. A nil cache is replaced by a fresh one
+// for the run, which still saves repeated keys within it; passing the same
+// cache to every run saves them across runs too. Items with an empty key are
+// always computed, and never cached. Errors aren't cached either, so a key
+// that failed is tried again the next time it comes up.
+//
+// The run is configured with opts, as with Do, so retries and the like apply
+// to the calls to fn that do happen.
+func MapCached[T, R any](parent context.Context, items []T, key func(item T) string, cache Cache[R], fn func(ctx context.Context, item T) (R, error), opts ...Option) ([]R, error) {
+	if fn == nil {
+		return nil, ErrNilMappingFunction
+	}
+	if key == nil {
+		return nil, ErrNilKeyFunction
+	}
+	if cache == nil {
+		cache = NewCache[R]()
+	}
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = key(item)
+	}
+	out := make([]R, len(items))
+	opts = append(opts[:len(opts):len(opts)], Dedupe(func(i int) string {
+		return keys[i]
+	}, func(from, to int) {
+		out[to] = out[from]
+	}))
+	err := Do(parent, len(items), func(ctx context.Context, i int) error {
+		k := keys[i]
+		if k != "" {
+			if v, ok := cache.Get(k); ok {
+				out[i] = v
+				return nil
+			}
+		}
+		r, err := fn(ctx, items[i])
+		if err != nil {
+			return err
+		}
+		out[i] = r
+		if k != "" {
+			cache.Add(k, r)
+		}
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
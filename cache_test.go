@@ -0,0 +1,72 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMapCached(t *testing.T) {
+	var calls int32
+	upper := func(ctx context.Context, s string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return strings.ToUpper(s), nil
+	}
+	key := func(s string) string { return s }
+	check := func(items, out []string) {
+		t.Helper()
+		for i, s := range out {
+			if s != strings.ToUpper(items[i]) {
+				t.Errorf("unexpected result for %q: %q", items[i], s)
+			}
+		}
+	}
+
+	// Repeats within a run are only computed once.
+	items := []string{"a", "b", "a", "c", "b", "a"}
+	out, err := MapCached(context.Background(), items, key, nil, upper, Workers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(items, out)
+	if calls != 3 {
+		t.Errorf("expected one call per key: %d", calls)
+	}
+
+	// A shared cache saves them across runs.
+	cache := NewCache[string]()
+	calls = 0
+	if _, err := MapCached(context.Background(), []string{"a", "b"}, key, cache, upper); err != nil {
+		t.Fatal(err)
+	}
+	items = []string{"b", "c", "a", "d"}
+	out, err = MapCached(context.Background(), items, key, cache, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(items, out)
+	if calls != 4 {
+		t.Errorf("expected only new keys to be computed: %d calls", calls)
+	}
+	if v, ok := cache.Get("d"); !ok || v != "D" {
+		t.Errorf("expected the result to be cached: %q, %v", v, ok)
+	}
+
+	// Errors aren't cached.
+	expectedError := errors.New("boom")
+	_, err = MapCached(context.Background(), []string{"x"}, key, cache, func(ctx context.Context, s string) (string, error) {
+		return "", expectedError
+	})
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if _, ok := cache.Get("x"); ok {
+		t.Errorf("expected the failure not to be cached")
+	}
+
+	if _, err := MapCached(context.Background(), items, nil, cache, upper); err != ErrNilKeyFunction {
+		t.Errorf("expected ErrNilKeyFunction: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package spara
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// calibrationSample is the minimum number of indices each trial of
+// CalibrateWorkers runs fn over.
+const calibrationSample = 256
+
+// Calibration is the outcome of CalibrateWorkers.
+type Calibration struct {
+	// Workers is the recommended number of workers.
+	Workers int
+	// Trials holds the measurement for each worker count tried, in
+	// increasing order of workers.
+	Trials []Trial
+}
+
+// Trial is a single measurement taken by CalibrateWorkers.
+type Trial struct {
+	Workers    int
+	Items      int
+	Elapsed    time.Duration
+	Throughput float64 // items per second
+}
+
+// Option returns the Workers option for the recommended number of workers, so
+// a calibration can be passed straight to Do.
+func (c *Calibration) Option() Option {
+	return Workers(c.Workers)
+}
+
+// CalibrateWorkers runs a short probe of fn at several worker counts and
+// recommends the one with the best throughput, so the right number of workers
+// for a workload can be measured on each machine it's deployed to rather than
+// guessed at. Each trial calls fn over a sample of indices spread evenly
+// across [0, iterations), so fn must be safe to call more than once with the
+// same index, and should be representative of the real run.
+//
+// The candidate worker counts default to the powers of two up to four times
+// GOMAXPROCS, plus GOMAXPROCS itself. Since adding workers past the point
+// where they help tends to make throughput plateau rather than drop, the
+// recommendation is the fewest workers within 5% of the best throughput
+// measured, not the best itself. Any error from fn stops the calibration and
+// is returned.
+func CalibrateWorkers(parent context.Context, iterations int, fn MappingFunc, candidates ...int) (*Calibration, error) {
+	if iterations <= 0 {
+		return nil, invalid(ErrInvalidIterations, "iterations", iterations)
+	}
+	if fn == nil {
+		return nil, ErrNilMappingFunction
+	}
+	if parent == nil {
+		return nil, ErrNilContext
+	}
+	if len(candidates) == 0 {
+		candidates = defaultCandidates()
+	}
+	candidates = append([]int(nil), candidates...)
+	sort.Ints(candidates)
+	if candidates[0] <= 0 {
+		return nil, invalid(ErrInvalidWorkers, "candidates", candidates[0])
+	}
+
+	most := candidates[len(candidates)-1]
+	n := min(iterations, max(calibrationSample, 4*most))
+	sample := func(ctx context.Context, k int) error {
+		return fn(ctx, k*iterations/n)
+	}
+
+	c := &Calibration{}
+	for i, workers := range candidates {
+		if i > 0 && workers == candidates[i-1] {
+			continue
+		}
+		start := time.Now()
+		if err := RunWithContext(parent, workers, n, sample); err != nil {
+			return nil, err
+		}
+		elapsed := time.Since(start)
+		c.Trials = append(c.Trials, Trial{
+			Workers:    workers,
+			Items:      n,
+			Elapsed:    elapsed,
+			Throughput: float64(n) / max(elapsed.Seconds(), 1e-9),
+		})
+	}
+
+	var best float64
+	for _, t := range c.Trials {
+		best = max(best, t.Throughput)
+	}
+	for _, t := range c.Trials {
+		if t.Throughput >= 0.95*best {
+			c.Workers = t.Workers
+			break
+		}
+	}
+	return c, nil
+}
+
+func defaultCandidates() []int {
+	procs := runtime.GOMAXPROCS(0)
+	candidates := []int{procs}
+	for n := 1; n <= 4*procs; n *= 2 {
+		candidates = append(candidates, n)
+	}
+	return candidates
+}
@@ -0,0 +1,61 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCalibrateWorkers(t *testing.T) {
+	// Items that just wait scale with the number of workers, so the most
+	// workers should win by a wide margin.
+	c, err := CalibrateWorkers(context.Background(), 1000, func(ctx context.Context, i int) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}, 16, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Trials) != 3 || c.Trials[0].Workers != 1 || c.Trials[2].Workers != 16 {
+		t.Fatalf("unexpected trials: %+v", c.Trials)
+	}
+	if c.Workers != 16 {
+		t.Errorf("expected 16 workers to be recommended: %+v", c)
+	}
+	if cfg := newConfig([]Option{c.Option()}); cfg.workers != c.Workers {
+		t.Errorf("expected the option to set the workers: %d", cfg.workers)
+	}
+}
+
+func TestCalibrateWorkersSample(t *testing.T) {
+	// The sample is spread across the whole range.
+	var seen []int
+	_, err := CalibrateWorkers(context.Background(), 10000, func(ctx context.Context, i int) error {
+		seen = append(seen, i)
+		return nil
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != calibrationSample || seen[0] != 0 || seen[len(seen)-1] < 9900 {
+		t.Errorf("unexpected sample: %d indices from %d to %d", len(seen), seen[0], seen[len(seen)-1])
+	}
+}
+
+func TestCalibrateWorkersErrors(t *testing.T) {
+	noop := func(ctx context.Context, i int) error { return nil }
+	if _, err := CalibrateWorkers(context.Background(), 0, noop); !errors.Is(err, ErrInvalidIterations) {
+		t.Errorf("expected ErrInvalidIterations: %v", err)
+	}
+	if _, err := CalibrateWorkers(context.Background(), 10, noop, 0, 2); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected ErrInvalidWorkers: %v", err)
+	}
+	expectedError := errors.New("boom")
+	_, err := CalibrateWorkers(context.Background(), 10, func(ctx context.Context, i int) error {
+		return expectedError
+	})
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+}
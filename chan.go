@@ -0,0 +1,195 @@
+package spara
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// FanOut splits the items received from in across up to workers goroutines,
+// each calling fn, and merges the results back into the returned channel.
+// The channel is closed once in is closed and every item has been processed,
+// or as soon as processing stops early. Results arrive in completion order,
+// not input order.
+//
+// Error handling matches RunWithContext. The first error returned by fn stops
+// the workers and cancels the context passed to fn. Calling the returned wait
+// function blocks until the output channel is closed, and returns that first
+// error, or the parent context's error if it completed first.
+//
+// The caller must either drain the output channel or cancel the parent
+// context; workers block trying to send results that nobody receives.
+func FanOut[T, R any](parent context.Context, workers int, in <-chan T, fn func(ctx context.Context, item T) (R, error)) (<-chan R, func() error) {
+	if workers <= 0 {
+		return failedChan[R](invalid(ErrInvalidWorkers, "workers", workers))
+	}
+	if fn == nil {
+		return failedChan[R](ErrNilMappingFunction)
+	}
+	if parent == nil {
+		return failedChan[R](ErrNilContext)
+	}
+
+	return emitChan(parent, workers, in, func(ctx context.Context, item T, emit func(R) error) error {
+		result, err := fn(ctx, item)
+		if err != nil {
+			return err
+		}
+		return emit(result)
+	})
+}
+
+// consumeChan calls process for every item received from in across workers
+// goroutines, until in is closed or the first error. Arguments are assumed to
+// have been validated. Items off a channel have no index of their own, so
+// ItemIndex reports them numbered in the order workers took them.
+func consumeChan[T any](parent context.Context, workers int, in <-chan T, process func(ctx context.Context, worker int, item T) error) error {
+	var received int64
+	return runLoops(parent, workers, workers, func(ctx context.Context, worker int) error {
+		w := workerFrom(ctx)
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					return nil
+				}
+				w.begin(int(atomic.AddInt64(&received, 1) - 1))
+				if err := process(ctx, worker, item); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+// emitChan is consumeChan for stages that produce output. Anything passed to
+// emit is sent on the returned channel, which is closed once processing
+// stops, and the returned function waits for that and returns the error.
+func emitChan[T, R any](parent context.Context, workers int, in <-chan T, process func(ctx context.Context, item T, emit func(R) error) error) (<-chan R, func() error) {
+	out := make(chan R)
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		defer close(out)
+		err = consumeChan(parent, workers, in, func(ctx context.Context, _ int, item T) error {
+			return process(ctx, item, func(result R) error {
+				select {
+				case out <- result:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		})
+	}()
+	return out, func() error {
+		<-done
+		return err
+	}
+}
+
+// Generate calls fn with every number in the range [0, n) across up to
+// workers goroutines, like RunWithContext, and sends the results on the
+// returned channel as they're produced. The channel is closed once every
+// result has been sent, or as soon as generation stops early. Results arrive
+// in completion order, not index order.
+//
+// The returned wait function blocks until the channel is closed, and returns
+// the first error from fn, or the parent context's error if it completed
+// first. As with FanOut, the caller must either drain the channel or cancel
+// the parent context.
+func Generate[T any](parent context.Context, workers int, n int, fn func(ctx context.Context, index int) (T, error)) (<-chan T, func() error) {
+	if workers <= 0 {
+		return failedChan[T](invalid(ErrInvalidWorkers, "workers", workers))
+	}
+	if n < 0 {
+		return failedChan[T](invalid(ErrInvalidIterations, "n", n))
+	}
+	if fn == nil {
+		return failedChan[T](ErrNilMappingFunction)
+	}
+	if parent == nil {
+		return failedChan[T](ErrNilContext)
+	}
+
+	out := make(chan T)
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		defer close(out)
+		err = RunWithContext(parent, workers, n, func(ctx context.Context, index int) error {
+			result, err := fn(ctx, index)
+			if err != nil {
+				return err
+			}
+			select {
+			case out <- result:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return out, func() error {
+		<-done
+		return err
+	}
+}
+
+// FanIn merges every item received from chans into the returned channel,
+// which is closed once all of them have been closed, or the context
+// completes.
+func FanIn[T any](ctx context.Context, chans ...<-chan T) <-chan T {
+	out, _ := fanIn(ctx, chans...)
+	return out
+}
+
+// fanIn is FanIn, but also returns a function reporting whether every input
+// was closed, as opposed to forwarding stopping because ctx completed. It's
+// only meaningful once the output channel has been closed.
+func fanIn[T any](ctx context.Context, chans ...<-chan T) (<-chan T, func() bool) {
+	out := make(chan T)
+	var closed int32
+	var wg sync.WaitGroup
+	wg.Add(len(chans))
+	for _, ch := range chans {
+		go func(ch <-chan T) {
+			defer wg.Done()
+			for {
+				select {
+				case item, ok := <-ch:
+					if !ok {
+						atomic.AddInt32(&closed, 1)
+						return
+					}
+					select {
+					case out <- item:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, func() bool {
+		return int(atomic.LoadInt32(&closed)) == len(chans)
+	}
+}
+
+// failedChan returns an already closed channel and a wait function that
+// reports err, for when arguments fail validation.
+func failedChan[R any](err error) (<-chan R, func() error) {
+	out := make(chan R)
+	close(out)
+	return out, func() error { return err }
+}
@@ -0,0 +1,138 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"testing"
+)
+
+func sendAll(items ...int) <-chan int {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for _, item := range items {
+			ch <- item
+		}
+	}()
+	return ch
+}
+
+func TestFanOut(t *testing.T) {
+	in := sendAll(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	var indexes [10]int32
+	out, wait := FanOut(context.Background(), 3, in, func(ctx context.Context, x int) (int, error) {
+		if index, _ := ItemIndex(ctx); index >= 0 && index < len(indexes) {
+			atomic.AddInt32(&indexes[index], 1)
+		}
+		return x * 2, nil
+	})
+	var results []int
+	for x := range out {
+		results = append(results, x)
+	}
+	if err := wait(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Ints(results)
+	for i, x := range results {
+		if x != (i+1)*2 {
+			t.Fatalf("unexpected results: %v", results)
+		}
+	}
+	if len(results) != 10 {
+		t.Errorf("expected 10 results: %v", results)
+	}
+	// Items are numbered in the order they were received.
+	for _, n := range indexes {
+		if n != 1 {
+			t.Errorf("expected each index once: %v", indexes)
+			break
+		}
+	}
+}
+
+func TestFanOutError(t *testing.T) {
+	expectedError := errors.New("")
+	in := make(chan int)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case in <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	out, wait := FanOut(context.Background(), 4, in, func(ctx context.Context, x int) (int, error) {
+		if x == 20 {
+			return 0, expectedError
+		}
+		return x, nil
+	})
+	for range out {
+	}
+	if err := wait(); err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+	if _, wait := FanOut(context.Background(), 0, in, func(ctx context.Context, x int) (int, error) { return x, nil }); !errors.Is(wait(), ErrInvalidWorkers) {
+		t.Errorf("expected calling FanOut with zero workers to fail")
+	}
+}
+
+func TestFanIn(t *testing.T) {
+	out := FanIn(context.Background(), sendAll(1, 2, 3), sendAll(4, 5), sendAll())
+	var results []int
+	for x := range out {
+		results = append(results, x)
+	}
+	sort.Ints(results)
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results: %v", results)
+	}
+	for i, x := range results {
+		if x != i+1 {
+			t.Fatalf("unexpected results: %v", results)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	out, wait := Generate(context.Background(), 4, 20, func(ctx context.Context, i int) (int, error) {
+		return i * i, nil
+	})
+	var results []int
+	for x := range out {
+		results = append(results, x)
+	}
+	if err := wait(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Ints(results)
+	if len(results) != 20 {
+		t.Fatalf("expected 20 results: %v", results)
+	}
+	for i, x := range results {
+		if x != i*i {
+			t.Fatalf("unexpected results: %v", results)
+		}
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	expectedError := errors.New("")
+	out, wait := Generate(context.Background(), 4, 100, func(ctx context.Context, i int) (int, error) {
+		if i == 50 {
+			return 0, expectedError
+		}
+		return i, nil
+	})
+	for range out {
+	}
+	if err := wait(); err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
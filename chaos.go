@@ -0,0 +1,138 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidChaos = errors.New("spara: invalid chaos settings")
+	// ErrChaos is the error injected by WithChaos, both as a synthetic
+	// failure and as the cause of a spurious cancellation.
+	ErrChaos = errors.New("spara: injected by chaos")
+)
+
+// Chaos says how often WithChaos makes each kind of trouble. Rates are the
+// probability, from 0 to 1, of it happening to any one call of the mapping
+// function.
+type Chaos struct {
+	// Seed seeds the choices. Each call's are worked out from the seed,
+	// the item's index and which attempt at it the call is, so a run that
+	// went wrong can be repeated with the same trouble for the same items,
+	// however the items are spread over the workers.
+	Seed int64
+	// DelayRate is how often a call is delayed by up to MaxDelay before it
+	// starts, as if the worker had been descheduled.
+	DelayRate float64
+	MaxDelay  time.Duration
+	// CancelRate is how often a call's context is canceled, with ErrChaos
+	// as the cause, up to MaxDelay after it starts, as if the run had been
+	// stopped just then.
+	CancelRate float64
+	// ErrorRate is how often a call fails with ErrChaos instead of calling
+	// the mapping function at all.
+	ErrorRate float64
+}
+
+// WithChaos injects trouble into the run: random delays, spurious
+// cancellations, and synthetic errors, at the rates set by chaos. It's for
+// testing that a mapping function copes with the adverse conditions it will
+// eventually meet in production, and that the error policy around it, like
+// Retry or CollectErrors, does what's expected when they happen:
+//
+//	err := spara.Do(ctx, n, fn,
+//		spara.Retry(3, spara.ConstantBackoff(0)),
+//		spara.WithChaos(spara.Chaos{ErrorRate: 0.1, CancelRate: 0.05, MaxDelay: time.Millisecond}))
+//
+// The trouble is made right around the mapping function, inside any
+// middleware from Use, so it looks to everything else like the mapping
+// function misbehaving. Delays are measured by the run's Clock. It's only
+// meant for tests; nothing about it is free, so don't leave it on anywhere
+// else.
+func WithChaos(chaos Chaos) Option {
+	return func(c *config) {
+		c.chaos = &chaos
+	}
+}
+
+// validate checks that the rates are probabilities.
+func (chaos *Chaos) validate() error {
+	for _, rate := range []float64{chaos.DelayRate, chaos.CancelRate, chaos.ErrorRate} {
+		if !(rate >= 0 && rate <= 1) {
+			return invalid(ErrInvalidChaos, "rate", rate)
+		}
+	}
+	if chaos.MaxDelay < 0 {
+		return invalid(ErrInvalidChaos, "MaxDelay", chaos.MaxDelay)
+	}
+	return nil
+}
+
+// withChaos returns the middleware for the WithChaos option, or nil. Every
+// call gets a PRNG of its own, seeded from the seed, index and attempt, so
+// its choices don't depend on how many calls came before it.
+func (c *config) withChaos() Middleware {
+	if c == nil || c.chaos == nil {
+		return nil
+	}
+	chaos, clock := *c.chaos, c.getClock()
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			rng := chaosRand(uint64(chaos.Seed) ^ mix(uint64(index)) ^ mix(mix(uint64(Attempt(ctx)))))
+			// roll reports whether something with the given rate
+			// happens, and returns a random delay up to MaxDelay to go
+			// with it.
+			roll := func(rate float64) (bool, time.Duration) {
+				hit := rng.float64() < rate
+				var d time.Duration
+				if chaos.MaxDelay > 0 {
+					d = time.Duration(rng.next() % uint64(chaos.MaxDelay))
+				}
+				return hit, d
+			}
+			if hit, d := roll(chaos.DelayRate); hit && d > 0 {
+				if !sleep(clock, ctx.Done(), d) {
+					return ctx.Err()
+				}
+			}
+			if hit, _ := roll(chaos.ErrorRate); hit {
+				return ErrChaos
+			}
+			if hit, d := roll(chaos.CancelRate); hit {
+				var cancel context.CancelCauseFunc
+				ctx, cancel = context.WithCancelCause(ctx)
+				defer cancel(nil)
+				if d == 0 {
+					cancel(ErrChaos)
+				} else {
+					t := clock.AfterFunc(d, func() { cancel(ErrChaos) })
+					defer t.Stop()
+				}
+			}
+			return fn(ctx, index)
+		}
+	}
+}
+
+// chaosRand is a splitmix64 generator. It's tiny and cheap to seed, unlike
+// math/rand's sources, which matters when every call gets one.
+type chaosRand uint64
+
+func (r *chaosRand) next() uint64 {
+	*r += 0x9e3779b97f4a7c15
+	return mix(uint64(*r))
+}
+
+// float64 returns a number in [0, 1).
+func (r *chaosRand) float64() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
+
+// mix is splitmix64's finalizer, which scrambles x so that nearby inputs,
+// like consecutive indices, give unrelated outputs.
+func mix(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,84 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithChaos(t *testing.T) {
+	// Synthetic errors are retried like any other.
+	var calls int32
+	err := Do(context.Background(), 200, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, Workers(4), Retry(10, ConstantBackoff(0)), WithChaos(Chaos{ErrorRate: 0.3}))
+	if err != nil {
+		t.Fatalf("expected the retries to get past the injected errors: %v", err)
+	}
+	if calls != 200 {
+		t.Errorf("expected every item to be called once: %d", calls)
+	}
+
+	// And collected like any other.
+	err = Do(context.Background(), 200, func(ctx context.Context, i int) error { return nil },
+		Workers(4), CollectErrors(), WithChaos(Chaos{ErrorRate: 0.5}))
+	if !errors.Is(err, ErrChaos) {
+		t.Errorf("expected injected errors: %v", err)
+	}
+
+	// Spurious cancellations show up on the item's context.
+	var canceled int32
+	Do(context.Background(), 100, func(ctx context.Context, i int) error {
+		<-ctx.Done()
+		if context.Cause(ctx) == ErrChaos {
+			atomic.AddInt32(&canceled, 1)
+		}
+		return nil
+	}, Workers(4), WithChaos(Chaos{CancelRate: 1, MaxDelay: time.Millisecond}))
+	if canceled != 100 {
+		t.Errorf("expected every item to be canceled by chaos: %d", canceled)
+	}
+
+	// Delays are measured by the run's clock.
+	start := time.Now()
+	Do(context.Background(), 10, func(ctx context.Context, i int) error { return nil },
+		Workers(1), WithChaos(Chaos{DelayRate: 1, MaxDelay: 5 * time.Millisecond}))
+	if time.Since(start) < 5*time.Millisecond {
+		t.Errorf("expected the items to be delayed")
+	}
+}
+
+func TestWithChaosSeed(t *testing.T) {
+	failures := func(seed int64, workers int) []bool {
+		failed := make([]bool, 100)
+		Do(context.Background(), len(failed), func(ctx context.Context, i int) error { return nil },
+			Workers(workers), WithHooks(Hooks{OnItemDone: func(i int, name string, err error, elapsed time.Duration) {
+				failed[i] = err != nil
+			}}), CollectErrors(), WithChaos(Chaos{Seed: seed, ErrorRate: 0.5}))
+		return failed
+	}
+	// The same items fail for the same seed, however they're scheduled.
+	first, second, other := failures(7, 1), failures(7, 8), failures(8, 1)
+	same := true
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected the same seed to fail the same items")
+		}
+		same = same && first[i] == other[i]
+	}
+	if same {
+		t.Errorf("expected a different seed to fail different items")
+	}
+}
+
+func TestWithChaosValidation(t *testing.T) {
+	noop := func(ctx context.Context, i int) error { return nil }
+	for _, chaos := range []Chaos{{ErrorRate: -0.1}, {DelayRate: 1.5}, {MaxDelay: -1}} {
+		if err := Do(context.Background(), 1, noop, WithChaos(chaos)); !errors.Is(err, ErrInvalidChaos) {
+			t.Errorf("expected %+v to be rejected: %v", chaos, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package spara
+
+import (
+	"context"
+	"time"
+)
+
+// OnCancel registers a cleanup function for items that were still in flight
+// when the run was stopped, whether by an error from another item or by the
+// parent context completing. Once such an item's mapping function returns,
+// cleanup is called with its index and error, and with a context that's
+// derived from the item's context with context.WithoutCancel, so it still
+// carries the item's values, like trace spans and WorkerID, but isn't
+// canceled, and has a deadline of timeout instead. That leaves it free to
+// make the short network calls best-effort cleanup tends to need, like
+// deleting a temporary object or releasing a lease, which would otherwise
+// fail straight away on the canceled context.
+//
+// What counts is whether the run was stopped while the item was in flight,
+// not whether the item's context was canceled, so cleanup still happens with
+// NoCancel, where the context never is. Items stopped while still waiting on
+// a Limiter or WithBudget are cleaned up too, with the wait's error, since
+// they've already been handed their index; items the run never got to
+// aren't.
+//
+// The cleanup runs on the item's worker, before the run returns.
+func OnCancel(timeout time.Duration, cleanup func(ctx context.Context, index int, err error)) Option {
+	return func(c *config) {
+		c.cleanup = cleanup
+		c.cleanupTimeout = timeout
+	}
+}
+
+// withCleanup returns middleware that calls the OnCancel cleanup function
+// after calls that the run was stopped during, or nil. The parent completing
+// is only recorded in state once the run has heard about it, which happens on
+// another goroutine, so a canceled context counts as well.
+func (c *config) withCleanup(state *completion) Middleware {
+	if c == nil || c.cleanup == nil {
+		return nil
+	}
+	cleanup, timeout, clock := c.cleanup, c.cleanupTimeout, c.getClock()
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			err := fn(ctx, index)
+			if state.stopping() || ctx.Err() != nil {
+				dctx, cancel := withClockTimeout(context.WithoutCancel(ctx), clock, timeout)
+				cleanup(dctx, index, err)
+				cancel()
+			}
+			return err
+		}
+	}
+}
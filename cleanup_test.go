@@ -0,0 +1,115 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnCancel(t *testing.T) {
+	expectedError := errors.New("boom")
+	started := make(chan struct{})
+	var (
+		mu      sync.Mutex
+		cleaned []int
+	)
+	err := Do(context.Background(), 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			<-started
+			return expectedError
+		}
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, Workers(2), OnCancel(time.Second, func(ctx context.Context, index int, err error) {
+		if ctx.Err() != nil {
+			t.Errorf("expected the cleanup context to be live: %v", ctx.Err())
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("expected the cleanup context to have a deadline")
+		}
+		if id, ok := WorkerID(ctx); !ok || id != 1 {
+			t.Errorf("expected the item's values: %d, %v", id, ok)
+		}
+		if err != context.Canceled {
+			t.Errorf("expected the item's error: %v", err)
+		}
+		mu.Lock()
+		cleaned = append(cleaned, index)
+		mu.Unlock()
+	}))
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	// Only the item that was canceled needs cleaning up after.
+	if len(cleaned) != 1 || cleaned[0] != 1 {
+		t.Errorf("expected index 1 to be cleaned up: %v", cleaned)
+	}
+
+	noop := func(context.Context, int) error { return nil }
+	if err := Do(context.Background(), 1, noop, OnCancel(0, func(context.Context, int, error) {})); !errors.Is(err, ErrInvalidTimeout) {
+		t.Errorf("expected ErrInvalidTimeout: %v", err)
+	}
+}
+
+// TestOnCancelNoCancel checks that cleanup happens when the run is stopped,
+// even though with NoCancel the item's context never is.
+func TestOnCancelNoCancel(t *testing.T) {
+	expectedError := errors.New("boom")
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	var cleaned []int
+	err := Do(context.Background(), 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			<-started
+			return expectedError
+		}
+		close(started)
+		<-stopped
+		return nil
+	}, Workers(2), NoCancel(), OnStop(func(error) { close(stopped) }), OnCancel(time.Second, func(ctx context.Context, index int, err error) {
+		cleaned = append(cleaned, index)
+	}))
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if len(cleaned) != 1 || cleaned[0] != 1 {
+		t.Errorf("expected index 1 to be cleaned up: %v", cleaned)
+	}
+}
+
+// waitingLimiter never has anything to give out, and says when an item has
+// started waiting on it.
+type waitingLimiter chan struct{}
+
+func (l waitingLimiter) Acquire(ctx context.Context, n int64) error {
+	close(l)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (l waitingLimiter) Release(n int64) {}
+
+// TestOnCancelLimiter checks that an item stopped while waiting on a limiter
+// is cleaned up with the wait's error.
+func TestOnCancelLimiter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := make(waitingLimiter)
+	go func() {
+		<-l
+		cancel()
+	}()
+	cleaned := make(map[int]error)
+	noop := func(context.Context, int) error { return nil }
+	err := Do(ctx, 1, noop, WithLimiter(l), OnCancel(time.Second, func(ctx context.Context, index int, err error) {
+		cleaned[index] = err
+	}))
+	if err != context.Canceled {
+		t.Errorf("expected the parent's error: %v", err)
+	}
+	if err, ok := cleaned[0]; !ok || err != context.Canceled {
+		t.Errorf("expected index 0 to be cleaned up with the wait's error: %v", cleaned)
+	}
+}
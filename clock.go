@@ -0,0 +1,202 @@
+package spara
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for everything a run does that depends on it:
+// Timeout and ItemTimeout, the waits between retries, slow item detection,
+// the timings reported to observers like WithStats and WithHooks, a
+// Tracker's elapsed time and updates, WithWatchdog, the start times of a
+// Pool's jobs, Handle.WaitFor, and the rate limiting of WithProgress. The
+// pipeline package's time based stages can
+// use one too. It's there so that jobs built on spara can be tested with a
+// fake clock that the test advances by hand, rather than by sleeping through
+// real timeouts and backoffs; sparatest.Clock is one. Outside of tests,
+// there's no reason not to use the default, SystemClock.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a Timer that sends the current time on its channel
+	// once d has passed.
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f on a goroutine of its own once d has passed, unless
+	// the returned Timer is stopped first.
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTicker returns a Ticker that sends the current time on its
+	// channel every d, like a time.Ticker.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a single event scheduled by a Clock, like a time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on, or nil for timers created
+	// with AfterFunc.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, reporting false if it already
+	// has, or has already been stopped.
+	Stop() bool
+}
+
+// Ticker is a repeating event scheduled by a Clock, like a time.Ticker. Ticks
+// the receiver isn't ready for are dropped.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the real time, as told by the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time { return t.t.C }
+func (t systemTimer) Stop() bool          { return t.t.Stop() }
+
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.t.C }
+func (t systemTicker) Stop()               { t.t.Stop() }
+
+// WithClock makes the run tell time by clock rather than SystemClock.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// getClock returns the clock the run should use.
+func (c *config) getClock() Clock {
+	if c == nil || c.clock == nil {
+		return SystemClock
+	}
+	return c.clock
+}
+
+// since is time.Since by clock.
+func since(clock Clock, t time.Time) time.Duration {
+	return clock.Now().Sub(t)
+}
+
+// withClockTimeout is context.WithTimeout, with the deadline measured by
+// clock. The returned context reports context.DeadlineExceeded once it's
+// passed, just as one from the context package would. With SystemClock, it
+// simply calls context.WithTimeout.
+func withClockTimeout(parent context.Context, clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	if clock == nil || clock == SystemClock {
+		return context.WithTimeout(parent, d)
+	}
+	ctx := &clockContext{
+		parent:   parent,
+		deadline: clock.Now().Add(d),
+		done:     make(chan struct{}),
+	}
+	// Either of these can fire before it's been recorded, so cancel finds
+	// out about them under the lock, and the registering side stops them if
+	// cancel got there first.
+	stopParent := context.AfterFunc(parent, func() {
+		ctx.cancel(parent.Err())
+	})
+	var timer Timer
+	if d > 0 {
+		timer = clock.AfterFunc(d, func() {
+			ctx.cancel(context.DeadlineExceeded)
+		})
+	}
+	ctx.mu.Lock()
+	ctx.stopParent, ctx.timer = stopParent, timer
+	canceled := ctx.canceling
+	ctx.mu.Unlock()
+	if canceled {
+		ctx.release()
+	}
+	if d <= 0 {
+		ctx.cancel(context.DeadlineExceeded)
+	}
+	return ctx, func() { ctx.cancel(context.Canceled) }
+}
+
+// clockContext is a context with a deadline measured by a Clock. It isn't
+// derived from the parent with the context package, which only knows about
+// real time, so it watches the parent itself.
+type clockContext struct {
+	parent   context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu         sync.Mutex
+	canceling  bool
+	err        error
+	stopParent func() bool
+	timer      Timer
+}
+
+// cancel releases the parent and the clock before closing done, so anyone
+// who sees the context finish also sees its timer stopped.
+func (c *clockContext) cancel(err error) {
+	c.mu.Lock()
+	if c.canceling {
+		c.mu.Unlock()
+		return
+	}
+	c.canceling = true
+	c.mu.Unlock()
+	c.release()
+	c.mu.Lock()
+	c.err = err
+	close(c.done)
+	c.mu.Unlock()
+}
+
+// release stops watching the parent and the clock, once they're no longer
+// needed.
+func (c *clockContext) release() {
+	c.mu.Lock()
+	stopParent, timer := c.stopParent, c.timer
+	c.mu.Unlock()
+	if stopParent != nil {
+		stopParent()
+	}
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// Deadline reports the earlier of the context's own deadline and its
+// parent's.
+func (c *clockContext) Deadline() (time.Time, bool) {
+	if d, ok := c.parent.Deadline(); ok && d.Before(c.deadline) {
+		return d, true
+	}
+	return c.deadline, true
+}
+
+func (c *clockContext) Done() <-chan struct{} { return c.done }
+func (c *clockContext) Value(key any) any     { return c.parent.Value(key) }
+
+func (c *clockContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
@@ -0,0 +1,204 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	ch    chan time.Time
+	f     func()
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	return c.schedule(d, make(chan time.Time, 1), nil)
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.schedule(d, nil, f)
+}
+
+func (c *fakeClock) schedule(d time.Duration, ch chan time.Time, f func()) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), ch: ch, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// waiting reports how many timers are pending.
+func (c *fakeClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// advance moves the clock forward by d, firing any timers that come due.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	now := c.now
+	c.mu.Unlock()
+	for _, t := range due {
+		if t.f != nil {
+			go t.f()
+		} else {
+			t.ch <- now
+		}
+	}
+}
+
+// NewTicker is built on AfterFunc, with the ticker rescheduling itself each
+// time it fires.
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	t := &fakeTicker{ch: make(chan time.Time, 1)}
+	var tick func()
+	tick = func() {
+		select {
+		case t.ch <- c.Now():
+		default:
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if !t.stopped {
+			t.timer = c.AfterFunc(d, tick)
+		}
+	}
+	t.mu.Lock()
+	t.timer = c.AfterFunc(d, tick)
+	t.mu.Unlock()
+	return t
+}
+
+type fakeTicker struct {
+	ch chan time.Time
+
+	mu      sync.Mutex
+	timer   Timer
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	t.timer.Stop()
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// waitFor polls until the clock has n pending timers, so a test knows the
+// run has gotten as far as waiting on it.
+func (c *fakeClock) waitFor(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.waiting() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d timers", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithClockItemTimeout(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	done := make(chan error, 1)
+	go func() {
+		done <- Do(context.Background(), 1, func(ctx context.Context, i int) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, Workers(1), ItemTimeout(time.Hour), WithClock(clock))
+	}()
+	clock.waitFor(t, 1)
+	clock.advance(59 * time.Minute)
+	select {
+	case err := <-done:
+		t.Fatalf("expected the item to still be running: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.advance(time.Minute)
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded: %v", err)
+	}
+}
+
+func TestWithClockRetry(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	expectedError := errors.New("boom")
+	var calls int
+	done := make(chan error, 1)
+	go func() {
+		done <- Do(context.Background(), 1, func(ctx context.Context, i int) error {
+			calls++
+			return expectedError
+		}, Workers(1), Retry(3, ConstantBackoff(time.Minute)), WithClock(clock))
+	}()
+	for i := 0; i < 2; i++ {
+		clock.waitFor(t, 1)
+		clock.advance(time.Minute)
+	}
+	if err := <-done; err != expectedError || calls != 3 {
+		t.Errorf("expected 3 attempts: %v, %d calls", err, calls)
+	}
+}
+
+func TestClockContext(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := withClockTimeout(parent, clock, time.Second)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(time.Unix(1, 0)) {
+		t.Errorf("unexpected deadline: %v", d)
+	}
+	cancelParent()
+	<-ctx.Done()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("expected the parent's error: %v", ctx.Err())
+	}
+	if n := clock.waiting(); n != 0 {
+		t.Errorf("expected the timer to be stopped: %d pending", n)
+	}
+}
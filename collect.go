@@ -0,0 +1,68 @@
+package spara
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// CollectErrors changes what happens when the mapping function returns an
+// error: rather than stopping the run, the error is set aside and the run
+// carries on with the remaining indices. Once every index has been tried,
+// the run returns all of the errors joined with errors.Join, in index order,
+// so errors.Is and errors.As see through to each of them. Cancellation of the
+// parent context still stops the run early, in which case its error is
+// joined on after the others.
+//
+// It's for batch jobs where one bad input shouldn't cost the rest of the
+// batch, and the caller would rather hear about every failure at once than
+// fix them one run at a time.
+func CollectErrors() Option {
+	return func(c *config) {
+		c.collect = true
+	}
+}
+
+// collecting reports whether item errors should be collected rather than
+// stopping the run.
+func (c *config) collecting() bool {
+	return c != nil && c.collect
+}
+
+// errorCollector holds the errors set aside by CollectErrors.
+type errorCollector struct {
+	mu   sync.Mutex
+	errs []indexedError
+}
+
+type indexedError struct {
+	index int
+	err   error
+}
+
+func (e *errorCollector) add(index int, err error) {
+	e.mu.Lock()
+	e.errs = append(e.errs, indexedError{index, err})
+	e.mu.Unlock()
+}
+
+// join combines the collected errors, in index order, with err, which is
+// whatever the run would have returned otherwise.
+func (e *errorCollector) join(err error) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.errs) == 0 {
+		return err
+	}
+	sort.Slice(e.errs, func(a, b int) bool {
+		return e.errs[a].index < e.errs[b].index
+	})
+	errs := make([]error, 0, len(e.errs)+1)
+	for _, ie := range e.errs {
+		errs = append(errs, ie.err)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
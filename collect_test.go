@@ -0,0 +1,85 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCollectErrors(t *testing.T) {
+	var calls int32
+	err := Do(context.Background(), 100, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		if i%10 == 3 {
+			return fmt.Errorf("item %d", i)
+		}
+		return nil
+	}, Workers(4), CollectErrors())
+	if calls != 100 {
+		t.Errorf("expected every index to be tried: %d", calls)
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("expected joined errors: %v", err)
+	}
+	errs := joined.Unwrap()
+	if len(errs) != 10 {
+		t.Fatalf("expected 10 errors: %v", errs)
+	}
+	for k, e := range errs {
+		if e.Error() != fmt.Sprintf("item %d", k*10+3) {
+			t.Errorf("expected errors in index order: %v", errs)
+			break
+		}
+	}
+
+	// Names still apply to each of the collected errors.
+	expectedError := errors.New("boom")
+	err = Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		return expectedError
+	}, Workers(2), CollectErrors(), Names(func(i int) string { return fmt.Sprint("n", i) }))
+	var itemErr *ItemError
+	if !errors.Is(err, expectedError) || !errors.As(err, &itemErr) || itemErr.Name != "n0" {
+		t.Errorf("expected named item errors: %v", err)
+	}
+
+	// No errors, no error.
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 10, noop, CollectErrors()); err != nil {
+		t.Errorf("expected success: %v", err)
+	}
+}
+
+// cancelWatcher closes canceled once the run has noticed that it's been
+// canceled.
+type cancelWatcher struct{ canceled chan struct{} }
+
+func (w *cancelWatcher) runStart(ctx context.Context, total int, start time.Time)     {}
+func (w *cancelWatcher) itemDone(worker, index int, elapsed time.Duration, err error) {}
+func (w *cancelWatcher) runEnd(err error, elapsed time.Duration)                      {}
+func (w *cancelWatcher) runCanceled(at time.Time)                                     { close(w.canceled) }
+
+func TestCollectErrorsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	expectedError := errors.New("boom")
+	watcher := &cancelWatcher{canceled: make(chan struct{})}
+	err := Do(ctx, 1000, func(ctx context.Context, i int) error {
+		if i == 0 {
+			return expectedError
+		}
+		if i == 10 {
+			// Hold the only worker until the run has seen the parent
+			// complete, so it can't finish first.
+			cancel()
+			<-watcher.canceled
+		}
+		return nil
+	}, Workers(1), CollectErrors(), func(c *config) { c.addObserver(watcher) })
+	if !errors.Is(err, expectedError) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected both the item error and the parent's: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package spara
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// completion tracks how a run ends. A run starts out running, and leaves that
+// state exactly once, for one of three reasons:
+//
+//	running ──fail──────────▶ failed       a worker returned an error
+//	running ──parentDone────▶ parentDone   the parent context completed
+//	running ──wait──────────▶ finished     every worker ran out of indices
+//
+// Whichever transition happens first wins, and the others become no-ops, so
+// a run whose parent is canceled just as a worker fails reports exactly one
+// of the two. The winner is what decides what the run returns:
+//
+//	failed      the error passed to fail
+//	parentDone  the parent context's error
+//	finished    nil
+//
+// It also counts the run's workers in and out, since the result can only be
+// read once they've all returned. That's what makes it safe for fail to
+// record the error without any further synchronization: only workers call
+// fail, and wait only reads the error after every worker is done.
+type completion struct {
+	state int32 // one of the run* constants, updated atomically
+	err   error
+	wg    sync.WaitGroup
+}
+
+const (
+	runRunning int32 = iota
+	runFailed
+	runParentDone
+	runFinished
+)
+
+// start registers n workers, each of which must call workerDone when it
+// returns.
+func (c *completion) start(n int) {
+	c.wg.Add(n)
+}
+
+func (c *completion) workerDone() {
+	c.wg.Done()
+}
+
+// fail moves a running run to failed, recording err as its result. It
+// reports whether it won, ie whether the caller is responsible for stopping
+// the run. Only workers may call it.
+func (c *completion) fail(err error) bool {
+	if !atomic.CompareAndSwapInt32(&c.state, runRunning, runFailed) {
+		return false
+	}
+	c.err = err
+	return true
+}
+
+// parentDone moves a running run to parentDone, reporting whether it won.
+func (c *completion) parentDone() bool {
+	return atomic.CompareAndSwapInt32(&c.state, runRunning, runParentDone)
+}
+
+// stopping reports whether the run has left the running state, ie whether
+// workers should stop picking up new work.
+func (c *completion) stopping() bool {
+	return atomic.LoadInt32(&c.state) != runRunning
+}
+
+// wait waits for every worker to return, and then returns the run's result.
+// If nothing stopped the run before then, it finished normally; but the
+// parent can still complete between the last worker returning and now, in
+// which case the run is reported as having been stopped by it, which is
+// indistinguishable to the caller.
+func (c *completion) wait(parent context.Context) error {
+	c.wg.Wait()
+	if atomic.CompareAndSwapInt32(&c.state, runRunning, runFinished) {
+		return nil
+	}
+	switch atomic.LoadInt32(&c.state) {
+	case runFailed:
+		return c.err
+	case runParentDone:
+		// The parent's error is set before it's reported done, which isn't
+		// necessarily true of any context derived from it, so that's the
+		// one to report.
+		return parent.Err()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCompletionFinished(t *testing.T) {
+	var c completion
+	c.start(2)
+	if c.stopping() {
+		t.Errorf("expected a new run not to be stopping")
+	}
+	c.workerDone()
+	c.workerDone()
+	if err := c.wait(context.Background()); err != nil {
+		t.Errorf("expected a finished run to succeed: %v", err)
+	}
+	// Once finished, nothing else can win.
+	if c.fail(errors.New("late")) || c.parentDone() {
+		t.Errorf("expected transitions after finishing to lose")
+	}
+}
+
+func TestCompletionFailed(t *testing.T) {
+	var c completion
+	first, second := errors.New("first"), errors.New("second")
+	c.start(1)
+	if !c.fail(first) {
+		t.Errorf("expected the first failure to win")
+	}
+	if c.fail(second) || c.parentDone() {
+		t.Errorf("expected later transitions to lose")
+	}
+	if !c.stopping() {
+		t.Errorf("expected a failed run to be stopping")
+	}
+	c.workerDone()
+
+	// Even if the parent is done too, the failure is what's reported.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.wait(ctx); err != first {
+		t.Errorf("expected the first error: %v", err)
+	}
+}
+
+func TestCompletionParentDone(t *testing.T) {
+	var c completion
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.start(1)
+	if !c.parentDone() {
+		t.Errorf("expected the parent completing to win")
+	}
+	if c.fail(errors.New("late")) {
+		t.Errorf("expected a failure after the parent completed to lose")
+	}
+	if !c.stopping() {
+		t.Errorf("expected the run to be stopping")
+	}
+	c.workerDone()
+	if err := c.wait(ctx); err != context.Canceled {
+		t.Errorf("expected the parent's error: %v", err)
+	}
+}
+
+func TestCompletionRace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 1000; i++ {
+		var c completion
+		expectedError := errors.New("boom")
+		c.start(1)
+		won := make(chan bool)
+		go func() { won <- c.parentDone() }()
+		failed := c.fail(expectedError)
+		parentWon := <-won
+		if failed == parentWon {
+			t.Fatalf("expected exactly one transition to win: fail=%v parent=%v", failed, parentWon)
+		}
+		c.workerDone()
+		err := c.wait(ctx)
+		if failed && err != expectedError || parentWon && err != context.Canceled {
+			t.Fatalf("unexpected result: %v", err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"time"
+)
+
+var ErrInvalidErrorPolicy = errors.New("spara: invalid error policy")
+
+// ErrorPolicy says what a run does when the mapping function returns an
+// error.
+type ErrorPolicy string
+
+const (
+	// StopOnError stops the run at the first error, and returns it. It's
+	// the default, and what the zero value means.
+	StopOnError ErrorPolicy = "stop"
+	// CollectAllErrors carries on past errors, and returns all of them at
+	// the end. See CollectErrors.
+	CollectAllErrors ErrorPolicy = "collect"
+)
+
+// Config is a declarative alternative to passing options to Do, for programs
+// that load their settings from a file or the environment. Every field's zero
+// value means the same as leaving the corresponding option out, so a Config
+// only needs the fields that differ from the defaults, and the field names
+// and tags are stable, so it can be embedded in a program's own
+// configuration:
+//
+//	{"workers": 16, "error_policy": "collect", "item_timeout": "30s"}
+//
+// Durations are in time.Duration's format when decoded from text, via
+// Duration.
+type Config struct {
+	// Workers is the maximum number of goroutines calling the mapping
+	// function at once. Zero means runtime.GOMAXPROCS(0).
+	Workers int `json:"workers,omitempty"`
+	// ChunkSize hands out indices in contiguous chunks of this many, using
+	// the Chunked scheduler. Zero means one at a time.
+	ChunkSize int `json:"chunk_size,omitempty"`
+	// ErrorPolicy says what to do about errors from the mapping function.
+	// Empty means StopOnError.
+	ErrorPolicy ErrorPolicy `json:"error_policy,omitempty"`
+	// Timeout limits how long the whole run may take. Zero means no limit.
+	Timeout Duration `json:"timeout,omitempty"`
+	// ItemTimeout limits how long each call to the mapping function may
+	// take. Zero means no limit.
+	ItemTimeout Duration `json:"item_timeout,omitempty"`
+}
+
+// Validate reports whether the config is usable, returning the same errors
+// Do would for the equivalent options.
+func (c Config) Validate() error {
+	if c.Workers < 0 {
+		return invalid(ErrInvalidWorkers, "Workers", c.Workers)
+	}
+	if c.ChunkSize < 0 {
+		return invalid(ErrInvalidChunkSize, "ChunkSize", c.ChunkSize)
+	}
+	switch c.ErrorPolicy {
+	case "", StopOnError, CollectAllErrors:
+	default:
+		return invalid(ErrInvalidErrorPolicy, "ErrorPolicy", c.ErrorPolicy)
+	}
+	if c.Timeout < 0 {
+		return invalid(ErrInvalidTimeout, "Timeout", c.Timeout)
+	}
+	if c.ItemTimeout < 0 {
+		return invalid(ErrInvalidTimeout, "ItemTimeout", c.ItemTimeout)
+	}
+	return nil
+}
+
+// Options returns the options equivalent to the config, for combining it with
+// others, eg in New.
+func (c Config) Options() []Option {
+	workers := c.Workers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	opts := []Option{Workers(workers)}
+	if c.ChunkSize > 0 {
+		opts = append(opts, WithScheduler(Chunked(c.ChunkSize)))
+	}
+	if c.ErrorPolicy == CollectAllErrors {
+		opts = append(opts, CollectErrors())
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, Timeout(time.Duration(c.Timeout)))
+	}
+	if c.ItemTimeout > 0 {
+		opts = append(opts, ItemTimeout(time.Duration(c.ItemTimeout)))
+	}
+	return opts
+}
+
+// Run validates the config, and then calls Do with the equivalent options.
+func (c Config) Run(parent context.Context, iterations int, fn MappingFunc) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return Do(parent, iterations, fn, c.Options()...)
+}
+
+// Duration is a time.Duration that reads and writes itself as text in
+// time.Duration's format, eg "1m30s", so that durations in a Config can be
+// written the way people write them.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,107 @@
+package spara
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		config Config
+		err    error
+	}{
+		{Config{}, nil},
+		{Config{Workers: 4, ChunkSize: 8, ErrorPolicy: CollectAllErrors}, nil},
+		{Config{Workers: -1}, ErrInvalidWorkers},
+		{Config{ChunkSize: -1}, ErrInvalidChunkSize},
+		{Config{ErrorPolicy: "ignore"}, ErrInvalidErrorPolicy},
+		{Config{Timeout: -1}, ErrInvalidTimeout},
+		{Config{ItemTimeout: -1}, ErrInvalidTimeout},
+	}
+	for _, tc := range tests {
+		if err := tc.config.Validate(); !errors.Is(err, tc.err) || (tc.err == nil) != (err == nil) {
+			t.Errorf("%+v: expected %v: %v", tc.config, tc.err, err)
+		}
+		if tc.err != nil {
+			noop := func(ctx context.Context, i int) error { return nil }
+			if err := tc.config.Run(context.Background(), 1, noop); !errors.Is(err, tc.err) {
+				t.Errorf("%+v: expected Run to validate: %v", tc.config, err)
+			}
+		}
+	}
+}
+
+func TestConfigZeroValue(t *testing.T) {
+	c := newConfig(Config{}.Options())
+	if c.workers != runtime.GOMAXPROCS(0) || c.scheduler != nil || c.collect || c.timeoutSet || c.itemTimeoutSet {
+		t.Errorf("expected the zero Config to match the defaults: %+v", c)
+	}
+}
+
+func TestConfigJSON(t *testing.T) {
+	var c Config
+	data := `{"workers": 3, "chunk_size": 2, "error_policy": "collect", "timeout": "1m", "item_timeout": "250ms"}`
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatal(err)
+	}
+	expected := Config{3, 2, CollectAllErrors, Duration(time.Minute), Duration(250 * time.Millisecond)}
+	if c != expected {
+		t.Errorf("unexpected config: %+v", c)
+	}
+	out, err := json.Marshal(Config{Workers: 2, Timeout: Duration(time.Second)})
+	if err != nil || string(out) != `{"workers":2,"timeout":"1s"}` {
+		t.Errorf("unexpected encoding: %s, %v", out, err)
+	}
+}
+
+func TestConfigRun(t *testing.T) {
+	c := Config{Workers: 2, ChunkSize: 4, ErrorPolicy: CollectAllErrors}
+	err := c.Run(context.Background(), 20, func(ctx context.Context, i int) error {
+		if i%5 == 0 {
+			return fmt.Errorf("item %d", i)
+		}
+		return nil
+	})
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 4 {
+		t.Errorf("expected four collected errors: %v", err)
+	}
+
+	c = Config{ItemTimeout: Duration(10 * time.Millisecond)}
+	err = c.Run(context.Background(), 1, func(ctx context.Context, i int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the item to time out: %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	start := time.Now()
+	err := Do(context.Background(), 1000, func(ctx context.Context, i int) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	}, Workers(2), Timeout(20*time.Millisecond))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the run to time out: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the run to stop promptly: %v", elapsed)
+	}
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 1, noop, Timeout(0)); !errors.Is(err, ErrInvalidTimeout) {
+		t.Errorf("expected ErrInvalidTimeout: %v", err)
+	}
+	if err := Do(context.Background(), 1, noop, ItemTimeout(-time.Second)); !errors.Is(err, ErrInvalidTimeout) {
+		t.Errorf("expected ErrInvalidTimeout: %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+// Package dag executes a graph of dependent functions concurrently. Every
+// node runs as soon as all of its dependencies have completed, across a
+// bounded number of workers, with the same cancellation on first error as
+// spara.RunWithContext.
+package dag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/heyimalex/spara"
+)
+
+var (
+	ErrDuplicateNode     = errors.New("dag: duplicate node")
+	ErrUnknownDependency = errors.New("dag: unknown dependency")
+	ErrCycle             = errors.New("dag: graph contains a cycle")
+	ErrNilFunction       = errors.New("dag: node function must not be nil")
+)
+
+// Func is the work done by a single node.
+type Func func(ctx context.Context) error
+
+// CycleError is returned when a graph can't be run because some of its nodes
+// depend on each other. Cycle lists the nodes making up one such cycle, where
+// each node depends on the one after it, and the last depends on the first.
+// It matches ErrCycle with errors.Is.
+type CycleError[K comparable] struct {
+	Cycle []K
+}
+
+func (e *CycleError[K]) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrCycle.Error())
+	b.WriteString(": ")
+	for _, k := range e.Cycle {
+		fmt.Fprintf(&b, "%v -> ", k)
+	}
+	if len(e.Cycle) > 0 {
+		fmt.Fprint(&b, e.Cycle[0])
+	}
+	return b.String()
+}
+
+func (e *CycleError[K]) Is(target error) bool {
+	return target == ErrCycle
+}
+
+// Graph is a set of named nodes and the dependencies between them. The zero
+// value is an empty graph ready to use. A Graph must not be modified while it
+// is running, but it can be run any number of times.
+type Graph struct {
+	nodes []node
+	index map[string]int
+}
+
+type node struct {
+	name string
+	fn   Func
+	deps []string
+}
+
+// Add registers a node that runs fn once every node named in deps has
+// completed successfully. Dependencies don't need to have been added yet;
+// they're resolved when the graph is run.
+func (g *Graph) Add(name string, fn Func, deps ...string) error {
+	if fn == nil {
+		return ErrNilFunction
+	}
+	if _, ok := g.index[name]; ok {
+		return fmt.Errorf("%w: %q", ErrDuplicateNode, name)
+	}
+	if g.index == nil {
+		g.index = make(map[string]int)
+	}
+	g.index[name] = len(g.nodes)
+	g.nodes = append(g.nodes, node{
+		name: name,
+		fn:   fn,
+		deps: append([]string(nil), deps...),
+	})
+	return nil
+}
+
+// Run executes every node in the graph across up to workers goroutines. If
+// a node fails, nothing new is started, the context is canceled, and Run
+// returns a *spara.ItemError naming the node once everything in progress
+// completes. Graphs that reference unknown nodes are rejected before anything
+// runs, as are graphs with cycles, with a *CycleError[string].
+func (g *Graph) Run(parent context.Context, workers int) error {
+	p := &plan{
+		names: make([]string, len(g.nodes)),
+		fns:   make([]Func, len(g.nodes)),
+		deps:  make([][]int, len(g.nodes)),
+	}
+	for i, n := range g.nodes {
+		p.names[i] = n.name
+		p.fns[i] = n.fn
+		for _, dep := range n.deps {
+			j, ok := g.index[dep]
+			if !ok {
+				return fmt.Errorf("%w: %q depends on %q", ErrUnknownDependency, n.name, dep)
+			}
+			p.deps[i] = append(p.deps[i], j)
+		}
+	}
+	if cycle := p.findCycle(); cycle != nil {
+		err := &CycleError[string]{Cycle: make([]string, len(cycle))}
+		for i, j := range cycle {
+			err.Cycle[i] = g.nodes[j].name
+		}
+		return err
+	}
+	return p.run(parent, workers)
+}
+
+// Node is a single entry in the map passed to RunKeyed.
+type Node[K comparable] struct {
+	// Deps lists the keys of nodes that must complete before this one runs.
+	Deps []K
+	// Fn is the work done by the node.
+	Fn Func
+}
+
+// RunKeyed is a simpler way to run a graph that's already sitting in a map.
+// Every node runs as soon as its dependencies complete, with as much
+// parallelism as workers allows. The graph is validated first; unknown
+// dependencies and nil functions are reported as errors, and cycles as a
+// *CycleError[K] so callers can show exactly which nodes are involved.
+//
+// Maps have no order of their own, so the nodes are put in order of their
+// keys as printed with fmt.Sprint, and a failing node's *spara.ItemError
+// carries its position in that order as its Index. That keeps the indices,
+// and which problem is reported when a graph has several, the same from one
+// run to the next.
+func RunKeyed[K comparable](parent context.Context, workers int, nodes map[K]Node[K]) error {
+	keys := make([]K, 0, len(nodes))
+	names := make(map[K]string, len(nodes))
+	for k := range nodes {
+		keys = append(keys, k)
+		names[k] = fmt.Sprint(k)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		return names[keys[a]] < names[keys[b]]
+	})
+	index := make(map[K]int, len(keys))
+	for i, k := range keys {
+		index[k] = i
+	}
+	p := &plan{
+		names: make([]string, len(keys)),
+		fns:   make([]Func, len(keys)),
+		deps:  make([][]int, len(keys)),
+	}
+	for i, k := range keys {
+		n := nodes[k]
+		if n.Fn == nil {
+			return fmt.Errorf("%w: %v", ErrNilFunction, k)
+		}
+		p.names[i] = names[k]
+		p.fns[i] = n.Fn
+		for _, dep := range n.Deps {
+			j, ok := index[dep]
+			if !ok {
+				return fmt.Errorf("%w: %v depends on %v", ErrUnknownDependency, k, dep)
+			}
+			p.deps[i] = append(p.deps[i], j)
+		}
+	}
+	if cycle := p.findCycle(); cycle != nil {
+		err := &CycleError[K]{Cycle: make([]K, len(cycle))}
+		for i, j := range cycle {
+			err.Cycle[i] = keys[j]
+		}
+		return err
+	}
+	return p.run(parent, workers)
+}
+
+// plan is a graph resolved down to indices, which is what actually gets run.
+type plan struct {
+	names []string
+	fns   []Func
+	deps  [][]int
+}
+
+// dependents inverts deps, and counts the dependencies of each node.
+func (p *plan) dependents() (dependents [][]int, waiting []int32) {
+	dependents = make([][]int, len(p.deps))
+	waiting = make([]int32, len(p.deps))
+	for i, deps := range p.deps {
+		for _, j := range deps {
+			dependents[j] = append(dependents[j], i)
+			waiting[i]++
+		}
+	}
+	return dependents, waiting
+}
+
+// findCycle returns the indices of one cycle in the graph, or nil if there
+// isn't one.
+func (p *plan) findCycle() []int {
+	// Kahn's algorithm; repeatedly remove nodes with no remaining
+	// dependencies. Whatever can't be removed is either part of a cycle or
+	// depends on one.
+	dependents, remaining := p.dependents()
+	ready := make([]int, 0, len(p.deps))
+	for i := range remaining {
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for k := 0; k < len(ready); k++ {
+		for _, d := range dependents[ready[k]] {
+			remaining[d]--
+			if remaining[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if len(ready) == len(p.deps) {
+		return nil
+	}
+
+	// Every node left over has at least one dependency that's also left
+	// over, so following those from anywhere must eventually loop.
+	start := -1
+	for i := range remaining {
+		if remaining[i] > 0 {
+			start = i
+			break
+		}
+	}
+	seen := make(map[int]int)
+	var path []int
+	for i := start; ; {
+		if at, ok := seen[i]; ok {
+			return path[at:]
+		}
+		seen[i] = len(path)
+		path = append(path, i)
+		for _, j := range p.deps[i] {
+			if remaining[j] > 0 {
+				i = j
+				break
+			}
+		}
+	}
+}
+
+// run executes an already validated plan, starting each node as soon as its
+// last dependency completes.
+func (p *plan) run(parent context.Context, workers int) error {
+	dependents, waiting := p.dependents()
+	// The roots have to be found before any of them start, since as soon as
+	// one finishes it counts down its dependents, and one that reaches zero
+	// would look like a root too and be scheduled twice.
+	var roots []int
+	for i := range waiting {
+		if waiting[i] == 0 {
+			roots = append(roots, i)
+		}
+	}
+	return spara.RunDynamic(parent, workers, func(ctx context.Context, add spara.AddFunc) error {
+		var schedule func(i int)
+		schedule = func(i int) {
+			add(func(ctx context.Context) error {
+				if err := p.fns[i](ctx); err != nil {
+					return &spara.ItemError{Index: i, Name: p.names[i], Err: err}
+				}
+				for _, d := range dependents[i] {
+					if atomic.AddInt32(&waiting[d], -1) == 0 {
+						schedule(d)
+					}
+				}
+				return nil
+			})
+		}
+		for _, i := range roots {
+			schedule(i)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,196 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/heyimalex/spara"
+)
+
+func TestGraphRunOrder(t *testing.T) {
+	var mu sync.Mutex
+	finished := make(map[string]bool)
+	var g Graph
+	add := func(name string, deps ...string) {
+		err := g.Add(name, func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, dep := range deps {
+				if !finished[dep] {
+					t.Errorf("%s ran before its dependency %s", name, dep)
+				}
+			}
+			finished[name] = true
+			return nil
+		}, deps...)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	add("link", "compile-a", "compile-b")
+	add("compile-a", "generate")
+	add("compile-b", "generate")
+	add("generate")
+	add("test", "link")
+	add("docs")
+
+	if err := g.Run(context.Background(), 3); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(finished) != 6 {
+		t.Errorf("not every node ran: %v", finished)
+	}
+}
+
+func TestGraphRunOnce(t *testing.T) {
+	// Roots first and their dependents after, so the early roots are likely
+	// to have finished, and made their dependents ready, before the graph
+	// has finished looking for roots.
+	const n = 10000
+	var g Graph
+	runs := make([]int32, 2*n)
+	count := func(i int) Func {
+		return func(ctx context.Context) error {
+			atomic.AddInt32(&runs[i], 1)
+			return nil
+		}
+	}
+	for i := 0; i < n; i++ {
+		g.Add(fmt.Sprint("root", i), count(i))
+	}
+	for i := 0; i < n; i++ {
+		g.Add(fmt.Sprint("dependent", i), count(n+i), fmt.Sprint("root", i))
+	}
+	if err := g.Run(context.Background(), 8); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := range runs {
+		if runs[i] != 1 {
+			t.Fatalf("node %d ran %d times", i, runs[i])
+		}
+	}
+}
+
+func TestGraphRunError(t *testing.T) {
+	expectedError := errors.New("")
+	var g Graph
+	g.Add("a", func(ctx context.Context) error { return expectedError })
+	g.Add("b", func(ctx context.Context) error {
+		t.Error("b ran after its dependency failed")
+		return nil
+	}, "a")
+
+	err := g.Run(context.Background(), 2)
+	var itemErr *spara.ItemError
+	if !errors.As(err, &itemErr) || itemErr.Name != "a" {
+		t.Fatalf("expected an *ItemError naming a: %v", err)
+	}
+	if !errors.Is(err, expectedError) {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
+
+func TestGraphValidation(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+
+	var g Graph
+	g.Add("a", noop)
+	if err := g.Add("a", noop); !errors.Is(err, ErrDuplicateNode) {
+		t.Errorf("expected a duplicate node error: %v", err)
+	}
+	if err := g.Add("b", nil); err != ErrNilFunction {
+		t.Errorf("expected a nil function error: %v", err)
+	}
+
+	g.Add("c", noop, "missing")
+	if err := g.Run(context.Background(), 1); !errors.Is(err, ErrUnknownDependency) {
+		t.Errorf("expected an unknown dependency error: %v", err)
+	}
+
+	var cyclic Graph
+	cyclic.Add("a", noop, "c")
+	cyclic.Add("b", noop, "a")
+	cyclic.Add("c", noop, "b")
+	cyclic.Add("d", noop)
+	err := cyclic.Run(context.Background(), 1)
+	var cycleErr *CycleError[string]
+	if !errors.As(err, &cycleErr) || !errors.Is(err, ErrCycle) {
+		t.Fatalf("expected a cycle error: %v", err)
+	}
+	if len(cycleErr.Cycle) != 3 {
+		t.Errorf("unexpected cycle: %v", cycleErr.Cycle)
+	}
+}
+
+func TestRunKeyed(t *testing.T) {
+	var order []int
+	var mu sync.Mutex
+	record := func(k int) Func {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, k)
+			mu.Unlock()
+			return nil
+		}
+	}
+	nodes := map[int]Node[int]{
+		1: {Fn: record(1)},
+		2: {Deps: []int{1}, Fn: record(2)},
+		3: {Deps: []int{2}, Fn: record(3)},
+	}
+	if err := RunKeyed(context.Background(), 4, nodes); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 3 {
+		t.Errorf("nodes ran out of order: %v", order)
+	}
+}
+
+func TestRunKeyedCycle(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+	nodes := map[string]Node[string]{
+		"root": {Fn: noop},
+		"a":    {Deps: []string{"root", "b"}, Fn: noop},
+		"b":    {Deps: []string{"a"}, Fn: noop},
+		"leaf": {Deps: []string{"b"}, Fn: noop},
+	}
+	err := RunKeyed(context.Background(), 2, nodes)
+	var cycleErr *CycleError[string]
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("expected a cycle error: %v", err)
+	}
+	cycle := cycleErr.Cycle
+	if len(cycle) != 2 || !(cycle[0] == "a" && cycle[1] == "b" || cycle[0] == "b" && cycle[1] == "a") {
+		t.Errorf("unexpected cycle: %v", cycle)
+	}
+	t.Log(err)
+
+	nodes["c"] = Node[string]{Deps: []string{"missing"}, Fn: noop}
+	delete(nodes, "b")
+	if err := RunKeyed(context.Background(), 2, nodes); !errors.Is(err, ErrUnknownDependency) {
+		t.Errorf("expected an unknown dependency error: %v", err)
+	}
+}
+
+func TestRunKeyedIndex(t *testing.T) {
+	expectedError := errors.New("boom")
+	noop := func(ctx context.Context) error { return nil }
+	nodes := map[string]Node[string]{
+		"c": {Fn: noop},
+		"a": {Fn: noop},
+		"b": {Fn: func(ctx context.Context) error { return expectedError }},
+	}
+	// The index comes from the sorted keys, not the map's order, so it's
+	// the same every time.
+	for i := 0; i < 10; i++ {
+		err := RunKeyed(context.Background(), 1, nodes)
+		var itemErr *spara.ItemError
+		if !errors.As(err, &itemErr) || itemErr.Index != 1 || itemErr.Name != "b" {
+			t.Fatalf("expected node b at index 1: %v", err)
+		}
+	}
+}
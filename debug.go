@@ -0,0 +1,27 @@
+package spara
+
+// WithDebugLog logs the run's scheduling decisions with logf, which has the
+// signature of log.Printf and testing.T.Logf: which worker each index is
+// dispatched to, workers starting and exiting, and the order in which errors
+// and cancellation stop the run, including which error won and which were
+// ignored because the run was already stopping. It's for answering "why did
+// my run stop early" or "why did it run in this order" without a debugger,
+// and it's far too noisy to leave on in production.
+func WithDebugLog(logf func(format string, args ...any)) Option {
+	return func(c *config) {
+		c.debug = logf
+	}
+}
+
+func (c *config) debugging() bool {
+	return c != nil && c.debug != nil
+}
+
+// debugf logs a scheduling decision. Callers check debugging first, so the
+// arguments aren't even built when debug logging is off.
+func (c *config) debugf(format string, args ...any) {
+	if c.runName != "" {
+		format = c.runName + ": " + format
+	}
+	c.debug("spara: "+format, args...)
+}
@@ -0,0 +1,46 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithDebugLog(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+	logf := func(format string, args ...any) {
+		mu.Lock()
+		lines = append(lines, fmt.Sprintf(format, args...))
+		mu.Unlock()
+	}
+
+	err := Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		if i == 3 {
+			return errors.New("boom")
+		}
+		return nil
+	}, Workers(1), WithDebugLog(logf))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	log := strings.Join(lines, "\n")
+	for _, expected := range []string{
+		"spara: run: starting 1 workers for 5 iterations",
+		"spara: worker 0: started",
+		"spara: worker 0: dispatching index 3",
+		"spara: worker 0: index 3 failed, exiting: boom",
+		"spara: kill: first error, stopping iteration and canceling: boom",
+	} {
+		if !strings.Contains(log, expected) {
+			t.Errorf("expected log to contain %q:\n%s", expected, log)
+		}
+	}
+	if strings.Contains(log, "dispatching index 4") {
+		t.Errorf("expected nothing to be dispatched after the error:\n%s", log)
+	}
+}
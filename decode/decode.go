@@ -0,0 +1,280 @@
+// Package decode parses JSON-lines and CSV input concurrently. Splitting the
+// input into records is inherently sequential, but turning records into
+// values usually isn't, and for large inputs that's where the time goes. The
+// input is read in chunks of records, the chunks are decoded across workers,
+// and the results are put back together in input order.
+//
+// By default the first record that fails to decode stops everything, like
+// spara.RunWithContext. With the CollectErrors option, bad records are
+// skipped instead, and reported together once the whole input is read.
+package decode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/heyimalex/spara"
+)
+
+var (
+	ErrInvalidChunkSize = errors.New("decode: invalid chunk size")
+	ErrNilReader        = errors.New("decode: reader must not be nil")
+)
+
+// Option configures JSONLines and CSV.
+type Option func(*config)
+
+type config struct {
+	collect    bool
+	chunkSize  int
+	skipHeader bool
+}
+
+// CollectErrors skips records that fail to decode rather than stopping, and
+// returns them all as Errors alongside the records that did decode. Errors
+// reading the input itself, like malformed CSV quoting, still stop
+// everything, since nothing after them can be trusted.
+func CollectErrors() Option {
+	return func(c *config) {
+		c.collect = true
+	}
+}
+
+// ChunkSize sets how many records are handed to a worker at a time. Larger
+// chunks mean less overhead per record, and smaller ones spread uneven
+// inputs more evenly. The default is 256.
+func ChunkSize(n int) Option {
+	return func(c *config) {
+		c.chunkSize = n
+	}
+}
+
+// SkipHeader skips the first record of a CSV input. It has no effect on
+// JSONLines.
+func SkipHeader() Option {
+	return func(c *config) {
+		c.skipHeader = true
+	}
+}
+
+// RecordError is the error for a single record that failed to decode.
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("decode: line %d: %v", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is every record error collected under the CollectErrors option, in
+// input order.
+type Errors []*RecordError
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", e[0], len(e)-1)
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// JSONLines decodes every line of r into a T with encoding/json, across up to
+// workers goroutines, and returns the values in input order. Blank lines are
+// skipped.
+func JSONLines[T any](parent context.Context, workers int, r io.Reader, opts ...Option) ([]T, error) {
+	if r == nil {
+		return nil, ErrNilReader
+	}
+	br := bufio.NewReader(r)
+	line := 0
+	read := func() (int, []byte, error) {
+		for {
+			b, err := br.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return 0, nil, err
+			}
+			if len(b) == 0 && err == io.EOF {
+				return 0, nil, io.EOF
+			}
+			line++
+			if b = bytes.TrimSpace(b); len(b) > 0 {
+				return line, b, nil
+			}
+		}
+	}
+	return decodeAll(parent, workers, opts, read, func(raw []byte) (T, error) {
+		var v T
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	})
+}
+
+// CSV reads the records of r with encoding/csv and converts each one into a T
+// with parse, across up to workers goroutines, returning the values in input
+// order. Use SkipHeader if the input starts with a header row.
+func CSV[T any](parent context.Context, workers int, r io.Reader, parse func(record []string) (T, error), opts ...Option) ([]T, error) {
+	if r == nil {
+		return nil, ErrNilReader
+	}
+	if parse == nil {
+		return nil, spara.ErrNilMappingFunction
+	}
+	cr := csv.NewReader(r)
+	skip := newConfig(opts).skipHeader
+	read := func() (int, []string, error) {
+		for {
+			record, err := cr.Read()
+			if err != nil {
+				return 0, nil, err
+			}
+			if skip {
+				skip = false
+				continue
+			}
+			line, _ := cr.FieldPos(0)
+			return line, record, nil
+		}
+	}
+	return decodeAll(parent, workers, opts, read, parse)
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{chunkSize: 256}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type record[S any] struct {
+	line int
+	raw  S
+}
+
+type chunk[S any] struct {
+	seq     int
+	records []record[S]
+}
+
+type decoded[T any] struct {
+	seq    int
+	values []T
+	errs   Errors
+}
+
+// decodeAll is the shared driver. read returns the raw records one at a time
+// until io.EOF, and decode turns them into values.
+func decodeAll[S, T any](parent context.Context, workers int, opts []Option, read func() (int, S, error), decode func(S) (T, error)) ([]T, error) {
+	c := newConfig(opts)
+	if c.chunkSize <= 0 {
+		return nil, &spara.ValidationError{Param: "chunkSize", Value: c.chunkSize, Err: ErrInvalidChunkSize}
+	}
+	if parent == nil {
+		return nil, spara.ErrNilContext
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	// Reading is sequential, so it gets a goroutine of its own feeding chunks
+	// to the workers.
+	chunks := make(chan chunk[S])
+	readDone := make(chan struct{})
+	var readErr error
+	go func() {
+		defer close(readDone)
+		defer close(chunks)
+		for seq := 0; ; seq++ {
+			records := make([]record[S], 0, c.chunkSize)
+			for len(records) < c.chunkSize {
+				line, raw, err := read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					readErr = err
+					return
+				}
+				records = append(records, record[S]{line, raw})
+			}
+			if len(records) == 0 {
+				return
+			}
+			select {
+			case chunks <- chunk[S]{seq, records}:
+			case <-ctx.Done():
+				return
+			}
+			if len(records) < c.chunkSize {
+				return
+			}
+		}
+	}()
+
+	out, wait := spara.FanOut(ctx, workers, chunks, func(ctx context.Context, ch chunk[S]) (decoded[T], error) {
+		d := decoded[T]{seq: ch.seq, values: make([]T, 0, len(ch.records))}
+		for _, rec := range ch.records {
+			v, err := decode(rec.raw)
+			if err != nil {
+				err := &RecordError{Line: rec.line, Err: err}
+				if !c.collect {
+					return d, err
+				}
+				d.errs = append(d.errs, err)
+				continue
+			}
+			d.values = append(d.values, v)
+		}
+		return d, nil
+	})
+
+	var parts []decoded[T]
+	for d := range out {
+		for len(parts) <= d.seq {
+			parts = append(parts, decoded[T]{})
+		}
+		parts[d.seq] = d
+	}
+	err := wait()
+	// Make sure the reader is done with the input before handing control
+	// back to the caller, who may close it.
+	cancel()
+	<-readDone
+	if err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var values []T
+	var errs Errors
+	for _, d := range parts {
+		values = append(values, d.values...)
+		errs = append(errs, d.errs...)
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return values, errs
+	}
+	return values, nil
+}
@@ -0,0 +1,103 @@
+package decode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func jsonInput(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "{\"X\": %d, \"Y\": %d}\n", i, i*2)
+		if i%10 == 0 {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func TestJSONLines(t *testing.T) {
+	points, err := JSONLines[point](context.Background(), 4, strings.NewReader(jsonInput(1000)), ChunkSize(7))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(points) != 1000 {
+		t.Fatalf("expected 1000 points, got %d", len(points))
+	}
+	for i, p := range points {
+		if p.X != i || p.Y != i*2 {
+			t.Fatalf("points out of order at %d: %+v", i, p)
+		}
+	}
+}
+
+func TestJSONLinesErrors(t *testing.T) {
+	input := "{\"X\": 1}\nnot json\n{\"X\": 3}\n{\"X\": \"bad\"}\n{\"X\": 5}"
+
+	_, err := JSONLines[point](context.Background(), 2, strings.NewReader(input), ChunkSize(1))
+	var recErr *RecordError
+	if !errors.As(err, &recErr) || (recErr.Line != 2 && recErr.Line != 4) {
+		t.Errorf("expected a RecordError for a bad line: %v", err)
+	}
+
+	points, err := JSONLines[point](context.Background(), 2, strings.NewReader(input), ChunkSize(1), CollectErrors())
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Line != 2 || errs[1].Line != 4 {
+		t.Errorf("expected errors for lines 2 and 4: %v", err)
+	}
+	if len(points) != 3 || points[0].X != 1 || points[1].X != 3 || points[2].X != 5 {
+		t.Errorf("expected the good records in order: %+v", points)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("x,y\n")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&sb, "%d,%d\n", i, i*2)
+	}
+	sb.WriteString("oops,1\n")
+	parse := func(record []string) (point, error) {
+		x, err := strconv.Atoi(record[0])
+		if err != nil {
+			return point{}, err
+		}
+		y, err := strconv.Atoi(record[1])
+		return point{x, y}, err
+	}
+
+	points, err := CSV(context.Background(), 3, strings.NewReader(sb.String()), parse, SkipHeader(), ChunkSize(16), CollectErrors())
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Line != 502 {
+		t.Errorf("expected a single error on line 502: %v", err)
+	}
+	if len(points) != 500 {
+		t.Fatalf("expected 500 points, got %d", len(points))
+	}
+	for i, p := range points {
+		if p.X != i || p.Y != i*2 {
+			t.Fatalf("points out of order at %d: %+v", i, p)
+		}
+	}
+}
+
+func TestDecodeValidation(t *testing.T) {
+	if _, err := JSONLines[point](context.Background(), 1, strings.NewReader(""), ChunkSize(0)); !errors.Is(err, ErrInvalidChunkSize) {
+		t.Errorf("expected ErrInvalidChunkSize: %v", err)
+	}
+	if _, err := JSONLines[point](context.Background(), 1, nil); err != ErrNilReader {
+		t.Errorf("expected ErrNilReader: %v", err)
+	}
+	points, err := JSONLines[point](context.Background(), 1, strings.NewReader(""))
+	if err != nil || len(points) != 0 {
+		t.Errorf("expected empty input to decode to nothing: %v %v", points, err)
+	}
+}
@@ -0,0 +1,85 @@
+package spara
+
+import (
+	"context"
+	"sync"
+)
+
+// Dedupe makes items with the same key share a single call to the mapping
+// function while it's in flight, in the manner of singleflight: when an
+// item's key matches one that's already running, the item waits for that
+// call instead of making its own, and gets its error. Once the call is over,
+// the key is forgotten, and a later item with the same key runs as usual.
+// Items with an empty key are never deduplicated.
+//
+// Since the mapping function stores its results by index, the items that
+// waited can't have had theirs stored for them. If share isn't nil, it's
+// called with the index that ran and the index that waited, once the call
+// succeeds, to copy the result across:
+//
+//	spara.Dedupe(func(i int) string { return urls[i] }, func(from, to int) {
+//		pages[to] = pages[from]
+//	})
+//
+// The deduplication happens around everything else, so the shared call
+// includes any retries, and the items that wait don't take a share of a
+// MemoryBudget or Limiter while they do. A waiting item still occupies its
+// worker, and stops waiting if the run is stopped.
+func Dedupe(key func(index int) string, share func(from, to int)) Option {
+	return func(c *config) {
+		c.dedupeKey = key
+		c.dedupeShare = share
+	}
+}
+
+// flight is an in-progress call that other items with the same key are
+// waiting on.
+type flight struct {
+	index int
+	done  chan struct{}
+	err   error
+}
+
+// withDedupe returns middleware that makes calls with the same key share a
+// single call, or nil if Dedupe wasn't given.
+func (c *config) withDedupe() Middleware {
+	if c == nil || c.dedupeKey == nil {
+		return nil
+	}
+	key, share := c.dedupeKey, c.dedupeShare
+	var (
+		mu      sync.Mutex
+		flights = make(map[string]*flight)
+	)
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			k := key(index)
+			if k == "" {
+				return fn(ctx, index)
+			}
+			mu.Lock()
+			if f, ok := flights[k]; ok {
+				mu.Unlock()
+				select {
+				case <-f.done:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				if f.err == nil && share != nil {
+					share(f.index, index)
+				}
+				return f.err
+			}
+			f := &flight{index: index, done: make(chan struct{})}
+			flights[k] = f
+			mu.Unlock()
+
+			f.err = fn(ctx, index)
+			mu.Lock()
+			delete(flights, k)
+			mu.Unlock()
+			close(f.done)
+			return f.err
+		}
+	}
+}
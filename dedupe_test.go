@@ -0,0 +1,80 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDedupe(t *testing.T) {
+	// Four keys, each repeated across the items, with every call held open
+	// long enough that the duplicates are sure to arrive while it runs.
+	const items = 16
+	key := func(i int) string { return strconv.Itoa(i % 4) }
+	results := make([]string, items)
+	var calls int32
+	release := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	err := Do(context.Background(), items, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		results[i] = "result " + key(i)
+		return nil
+	}, Workers(items), Dedupe(key, func(from, to int) {
+		results[to] = results[from]
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 {
+		t.Errorf("expected one call per key: %d", calls)
+	}
+	for i, r := range results {
+		if r != "result "+key(i) {
+			t.Errorf("expected index %d to have its key's result: %q", i, r)
+		}
+	}
+
+	// Everyone waiting gets the error.
+	expectedError := errors.New("boom")
+	var failed int32
+	release = make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	err = Do(context.Background(), 4, func(ctx context.Context, i int) error {
+		<-release
+		return expectedError
+	}, Workers(4), CollectErrors(), Dedupe(func(int) string { return "same" }, nil), WithHooks(Hooks{
+		OnItemDone: func(index int, name string, err error, elapsed time.Duration) {
+			if err == expectedError {
+				atomic.AddInt32(&failed, 1)
+			}
+		},
+	}))
+	if !errors.Is(err, expectedError) || failed != 4 {
+		t.Errorf("expected all four items to fail: %v, %d failed", err, failed)
+	}
+
+	// Empty keys and finished calls aren't shared.
+	calls = 0
+	err = Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, Workers(1), Dedupe(func(i int) string {
+		if i%2 == 0 {
+			return ""
+		}
+		return "odd"
+	}, nil))
+	if err != nil || calls != 10 {
+		t.Errorf("expected every item to run: %v, %d calls", err, calls)
+	}
+}
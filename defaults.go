@@ -0,0 +1,102 @@
+package spara
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+var defaultOptions atomic.Pointer[[]Option]
+
+// SetDefaults sets options that every run in the process starts from, before
+// any options of its own. It's meant for the process's main, or whatever
+// platform code sets up logging and metrics, so that instrumentation like
+// WithHooks or WithMetrics is applied everywhere without every call site
+// having to remember it:
+//
+//	spara.SetDefaults(spara.Workers(32), spara.WithMetrics(recorder))
+//
+// The defaults apply to Do and Runners, where a Workers default replaces
+// runtime.GOMAXPROCS(0), and to Run and RunWithContext, which keep the worker
+// count they were passed but pick up everything else. Run normally skips all of the option machinery; with
+// defaults set it runs as if it had been passed a background context.
+//
+// Helpers that hand each of their workers a loop over many items, rather than
+// calling the mapping function once per item, don't pick up the defaults,
+// since per-item options like Retry or ItemTimeout would apply to the whole
+// loop. That covers Map, Run2D, RunWithScratch and RunWithState, the helpers
+// that read from a channel like FanOut, and the stages in the pipeline and
+// archive packages; use Do with the options spelled out where they're
+// needed.
+//
+// The options are checked up front, and the current defaults are left alone
+// if any are invalid. Trackers can only be used by a single run, so they're
+// rejected with ErrTrackerReused. Calling SetDefaults with no options clears
+// the defaults.
+func SetDefaults(opts ...Option) error {
+	if len(opts) == 0 {
+		defaultOptions.Store(nil)
+		return nil
+	}
+	opts = append([]Option(nil), opts...)
+	c := newConfig(opts)
+	if err := c.check(); err != nil {
+		return err
+	}
+	for _, o := range c.observers {
+		if _, ok := o.(*Tracker); ok {
+			return ErrTrackerReused
+		}
+	}
+	defaultOptions.Store(&opts)
+	return nil
+}
+
+// getDefaults returns the options set with SetDefaults, or nil.
+func getDefaults() []Option {
+	if opts := defaultOptions.Load(); opts != nil {
+		return *opts
+	}
+	return nil
+}
+
+// defaultConfig returns a config with just the defaults applied, or nil if
+// there aren't any, for the entry points that don't take options.
+func defaultConfig() *config {
+	if getDefaults() == nil {
+		return nil
+	}
+	return newConfig(nil)
+}
+
+// runDefault is Run for when defaults have been set.
+func runDefault(c *config, workers int, iterations int, fn func(index int) error) error {
+	return run(context.Background(), workers, iterations, func(_ context.Context, index int) error {
+		return fn(index)
+	}, c)
+}
+
+// IgnoreDefaults makes the run ignore the options set with SetDefaults, and
+// only use the ones it's passed. It's for code that builds its own
+// scheduling on top of a run, where each call to the mapping function is a
+// worker that loops over items of its own: options meant for single items
+// would apply to the whole loop, and a Retry, say, would have the retried
+// worker carry on with the next item and quietly leave the failed one
+// behind.
+func IgnoreDefaults() Option {
+	return func(c *config) {
+		c.ignoreDefaults = true
+	}
+}
+
+// runLoops is RunWithContext for the helpers in this package whose mapping
+// function is a worker loop, or a block of items, rather than a single item.
+// It never applies the defaults, for the reasons given on IgnoreDefaults.
+func runLoops(parent context.Context, workers int, iterations int, fn MappingFunc) error {
+	if workers <= 0 {
+		return invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	if parent == nil {
+		return ErrNilContext
+	}
+	return run(parent, workers, iterations, fn, nil)
+}
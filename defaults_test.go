@@ -0,0 +1,102 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetDefaults(t *testing.T) {
+	var runs, items int32
+	hooks := Hooks{
+		OnRunStart: func(ctx context.Context, total int) { atomic.AddInt32(&runs, 1) },
+		OnItemDone: func(int, string, error, time.Duration) { atomic.AddInt32(&items, 1) },
+	}
+	if err := SetDefaults(Workers(3), WithHooks(hooks)); err != nil {
+		t.Fatal(err)
+	}
+	defer SetDefaults()
+
+	noop := func(context.Context, int) error { return nil }
+	if err := Run(2, 10, func(int) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunWithContext(context.Background(), 2, 10, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := Do(context.Background(), 10, noop); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 3 || items != 30 {
+		t.Errorf("expected the hooks to see every run: %d runs, %d items", runs, items)
+	}
+	// Map's run is made of worker loops, not items, so it doesn't see the
+	// defaults, and neither does a run that asks to ignore them.
+	if _, err := Map(context.Background(), 2, make([]int, 10), func(ctx context.Context, i int) (int, error) { return i, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := Do(context.Background(), 10, noop, IgnoreDefaults()); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 3 {
+		t.Errorf("expected the hooks to only see the plain runs: %d runs", runs)
+	}
+	if c := newConfig([]Option{IgnoreDefaults()}); c.workers != runtime.GOMAXPROCS(0) || c.observed() {
+		t.Errorf("expected the defaults to be ignored: %d workers", c.workers)
+	}
+
+	// The default worker count applies to Do, and can still be overridden.
+	if c := newConfig(nil); c.workers != 3 {
+		t.Errorf("expected 3 default workers: %d", c.workers)
+	}
+	if c := newConfig([]Option{Workers(5)}); c.workers != 5 {
+		t.Errorf("expected the option to win: %d", c.workers)
+	}
+
+	// Invalid defaults are rejected, and leave the old ones in place.
+	if err := SetDefaults(Workers(0)); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected ErrInvalidWorkers: %v", err)
+	}
+	if err := SetDefaults(Track(NewTracker(time.Second))); err != ErrTrackerReused {
+		t.Errorf("expected ErrTrackerReused: %v", err)
+	}
+	if c := newConfig(nil); c.workers != 3 {
+		t.Errorf("expected the defaults to be unchanged: %d", c.workers)
+	}
+
+	SetDefaults()
+	if c := newConfig(nil); c.workers != runtime.GOMAXPROCS(0) {
+		t.Errorf("expected the defaults to be cleared: %d", c.workers)
+	}
+	if defaultConfig() != nil {
+		t.Errorf("expected no default config")
+	}
+}
+
+// TestSetDefaultsMapRetry checks that a default Retry doesn't apply to Map's
+// worker loops, where retrying a worker would have it move on to the next
+// block and leave the failed one unfilled.
+func TestSetDefaultsMapRetry(t *testing.T) {
+	if err := SetDefaults(Retry(2, nil)); err != nil {
+		t.Fatal(err)
+	}
+	defer SetDefaults()
+
+	expectedError := errors.New("boom")
+	items := make([]int, 200)
+	for i := range items {
+		items[i] = i + 1
+	}
+	out, err := Map(context.Background(), 4, items, func(ctx context.Context, item int) (int, error) {
+		if item == 100 {
+			return 0, expectedError
+		}
+		return item, nil
+	})
+	if err != expectedError || out != nil {
+		t.Errorf("expected the item's error: %v, %d results", err, len(out))
+	}
+}
@@ -0,0 +1,148 @@
+package spara
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+)
+
+type deterministicScheduler struct {
+	seed int64
+}
+
+// Deterministic is a scheduler for tests, which makes a run's every
+// scheduling decision with a PRNG seeded with seed, so that a concurrency bug
+// that only shows up with one particular interleaving of items can be
+// reproduced at will. Indices are handed out in an order shuffled by the
+// PRNG, and only one worker runs at a time: at each yield point, the worker
+// running stops, and the PRNG picks which one runs next. Every boundary
+// between items is a yield point, and the mapping function can add its own
+// with Yield, eg between reading and writing some shared state, to let the
+// scheduler try out what happens when another item gets in between.
+//
+// A failing test can then log its seed, and be rerun with it to get exactly
+// the same interleaving again:
+//
+//	seed := time.Now().UnixNano()
+//	t.Logf("seed: %d", seed)
+//	err := spara.Do(ctx, n, fn, spara.WithScheduler(spara.Deterministic(seed)))
+//
+// The interleaving is only reproducible as far as the run can control it.
+// Anything that happens on other goroutines, like timers firing or the
+// parent context being canceled, happens when it happens. Since only one
+// worker runs at a time, items must not wait on each other, other than by
+// calling Yield in a loop, or the run will deadlock.
+func Deterministic(seed int64) Scheduler {
+	return deterministicScheduler{seed: seed}
+}
+
+func (s deterministicScheduler) newSchedule(workers, iterations int) schedule {
+	rng := rand.New(rand.NewSource(s.seed))
+	d := &deterministicSchedule{
+		rng:     rng,
+		order:   rng.Perm(iterations),
+		live:    workers,
+		running: -1,
+		parked:  make([]bool, workers),
+		turn:    make([]chan struct{}, workers),
+	}
+	for i := range d.turn {
+		d.turn[i] = make(chan struct{}, 1)
+	}
+	return d
+}
+
+// deterministicSchedule passes a single turn around the workers of a run.
+// Decisions are only ever made once every worker still in the run is parked
+// waiting for its turn, so nothing about them depends on how the goroutines
+// happened to be scheduled.
+type deterministicSchedule struct {
+	mu      sync.Mutex
+	rng     *rand.Rand
+	order   []int
+	next    int
+	stopped bool
+	live    int    // workers that haven't exited
+	waiting int    // workers parked
+	running int    // the worker whose turn it is, or -1
+	parked  []bool // by worker
+	turn    []chan struct{}
+}
+
+// yield gives up worker's turn, and waits for it to get another.
+func (s *deterministicSchedule) yield(worker int) {
+	s.mu.Lock()
+	s.parked[worker] = true
+	s.waiting++
+	if s.running == worker {
+		s.running = -1
+	}
+	s.dispatch()
+	s.mu.Unlock()
+	<-s.turn[worker]
+}
+
+// dispatch picks the next worker to run, if it's time to.
+func (s *deterministicSchedule) dispatch() {
+	if s.running != -1 || s.waiting == 0 || s.waiting < s.live {
+		return
+	}
+	k := s.rng.Intn(s.waiting)
+	for w, parked := range s.parked {
+		if !parked {
+			continue
+		}
+		if k == 0 {
+			s.parked[w] = false
+			s.waiting--
+			s.running = w
+			s.turn[w] <- struct{}{}
+			return
+		}
+		k--
+	}
+}
+
+func (s *deterministicSchedule) claim(worker int) (lo, hi int, ok bool) {
+	s.yield(worker)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped || s.next == len(s.order) {
+		return 0, 0, false
+	}
+	i := s.order[s.next]
+	s.next++
+	return i, i + 1, true
+}
+
+// exit removes worker from the run, passing its turn on.
+func (s *deterministicSchedule) exit(worker int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.live--
+	if s.running == worker {
+		s.running = -1
+	}
+	s.dispatch()
+}
+
+func (s *deterministicSchedule) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+}
+
+// Yield is a yield point for the Deterministic scheduler: it gives up the
+// calling worker's turn, and returns once the scheduler gives it another,
+// which may be straight away. ctx must be the context the mapping function
+// was called with, and Yield must be called from the goroutine it was called
+// on. With any other scheduler, or outside of a run, it does nothing.
+func Yield(ctx context.Context) {
+	w := workerFrom(ctx)
+	if w == nil {
+		return
+	}
+	if s, ok := w.sched.(*deterministicSchedule); ok {
+		s.yield(w.id)
+	}
+}
@@ -0,0 +1,93 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// interleaving runs a small job with the Deterministic scheduler, and
+// returns the order things happened in.
+func interleaving(t *testing.T, seed int64) []string {
+	t.Helper()
+	var mu sync.Mutex
+	var events []string
+	record := func(format string, args ...any) {
+		mu.Lock()
+		events = append(events, fmt.Sprintf(format, args...))
+		mu.Unlock()
+	}
+	err := Do(context.Background(), 20, func(ctx context.Context, i int) error {
+		id, _ := WorkerID(ctx)
+		record("%d start %d", id, i)
+		Yield(ctx)
+		record("%d end %d", id, i)
+		return nil
+	}, Workers(4), WithScheduler(Deterministic(seed)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return events
+}
+
+func TestDeterministic(t *testing.T) {
+	first := interleaving(t, 42)
+	if len(first) != 40 {
+		t.Fatalf("expected every item to start and end: %v", first)
+	}
+	for i := 0; i < 10; i++ {
+		if again := interleaving(t, 42); !reflect.DeepEqual(first, again) {
+			t.Fatalf("expected the same seed to give the same interleaving:\n%v\n%v", first, again)
+		}
+	}
+	var differs bool
+	for seed := int64(0); seed < 10 && !differs; seed++ {
+		differs = !reflect.DeepEqual(first, interleaving(t, seed))
+	}
+	if !differs {
+		t.Errorf("expected other seeds to give other interleavings")
+	}
+}
+
+func TestDeterministicLostUpdate(t *testing.T) {
+	// A read-modify-write with a yield in the middle loses updates under
+	// some interleavings, and the same ones every time for a given seed.
+	run := func(seed int64) int {
+		var counter int
+		Do(context.Background(), 10, func(ctx context.Context, i int) error {
+			v := counter
+			Yield(ctx)
+			counter = v + 1
+			return nil
+		}, Workers(3), WithScheduler(Deterministic(seed)))
+		return counter
+	}
+	var lost bool
+	for seed := int64(0); seed < 10; seed++ {
+		counter := run(seed)
+		if counter != run(seed) {
+			t.Fatalf("seed %d: expected the same result every time", seed)
+		}
+		lost = lost || counter < 10
+	}
+	if !lost {
+		t.Errorf("expected some interleaving to lose an update")
+	}
+}
+
+func TestDeterministicError(t *testing.T) {
+	expectedError := errors.New("boom")
+	err := Do(context.Background(), 100, func(ctx context.Context, i int) error {
+		if i == 50 {
+			return expectedError
+		}
+		Yield(ctx)
+		return nil
+	}, Workers(4), WithScheduler(Deterministic(1)))
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package spara
+
+import (
+	"context"
+	"sync"
+)
+
+// AddFunc adds another unit of work to the run it was handed out by. It's safe
+// to call concurrently, and from any work in the run, not just the function
+// it was passed to.
+type AddFunc func(work func(ctx context.Context) error)
+
+// RunDynamic is like RunWithContext, but for work that isn't known up front.
+// It calls fn once, passing it an AddFunc. Work added through it is queued and
+// executed across up to workers goroutines, and may itself add more work; the
+// run only finishes once fn and everything added has returned. Think of
+// walking a directory tree, where every directory listed discovers more
+// directories that need listing.
+//
+// The first error stops the run in the same way it does for RunWithContext:
+// queued work is dropped, the context is canceled, and the error is returned
+// once everything in progress returns. Calls to add after that point are
+// silently ignored.
+func RunDynamic(parent context.Context, workers int, fn func(ctx context.Context, add AddFunc) error) error {
+	if workers <= 0 {
+		return invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	if parent == nil {
+		return ErrNilContext
+	}
+
+	// Eagerly check whether the parent context is already done.
+	select {
+	case <-parent.Done():
+		return parent.Err()
+	default:
+		break
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	f := &frontier{cancel: cancel}
+	f.cond.L = &f.mu
+	f.add(func(ctx context.Context) error {
+		return fn(ctx, f.add)
+	})
+
+	// Same as RunWithContext; only watch the parent if it can actually
+	// complete, and with context.AfterFunc, so that nothing waits around
+	// for a parent that outlives the run.
+	if parent.Done() != nil {
+		stop := context.AfterFunc(parent, func() {
+			f.stop(nil)
+		})
+		defer stop()
+	}
+
+	pool := getDefaultPool()
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		pool.spawn(func() {
+			defer wg.Done()
+			f.work(ctx)
+		})
+	}
+	wg.Wait()
+
+	if f.firsterr != nil {
+		return f.firsterr
+	}
+	if f.canceled {
+		return parent.Err()
+	}
+	return nil
+}
+
+// frontier is the queue of pending work behind RunDynamic.
+type frontier struct {
+	mu     sync.Mutex
+	cond   sync.Cond
+	cancel context.CancelFunc
+
+	queue []func(ctx context.Context) error
+	// pending counts work that is either queued or running. The run is over
+	// when it reaches zero.
+	pending  int
+	stopped  bool
+	canceled bool
+	firsterr error
+}
+
+func (f *frontier) add(work func(ctx context.Context) error) {
+	if work == nil {
+		return
+	}
+	f.mu.Lock()
+	if !f.stopped {
+		f.queue = append(f.queue, work)
+		f.pending++
+		f.cond.Signal()
+	}
+	f.mu.Unlock()
+}
+
+// stop ends the run early. A nil err means the parent context completed,
+// which only counts as a cancellation if there was still work to do.
+func (f *frontier) stop(err error) {
+	f.mu.Lock()
+	if !f.stopped {
+		f.stopped = true
+		f.queue = nil
+		if err != nil {
+			f.firsterr = err
+		} else if f.pending > 0 {
+			f.canceled = true
+		}
+		f.cancel()
+		f.cond.Broadcast()
+	}
+	f.mu.Unlock()
+}
+
+// work is the loop each worker goroutine runs until the frontier is empty or
+// the run is stopped.
+func (f *frontier) work(ctx context.Context) {
+	for {
+		f.mu.Lock()
+		for len(f.queue) == 0 && f.pending > 0 && !f.stopped {
+			f.cond.Wait()
+		}
+		if f.stopped || len(f.queue) == 0 {
+			f.mu.Unlock()
+			return
+		}
+		work := f.queue[len(f.queue)-1]
+		f.queue[len(f.queue)-1] = nil
+		f.queue = f.queue[:len(f.queue)-1]
+		f.mu.Unlock()
+
+		err := work(ctx)
+
+		if err != nil {
+			f.stop(err)
+		}
+		f.mu.Lock()
+		f.pending--
+		if f.pending == 0 {
+			f.cond.Broadcast()
+		}
+		f.mu.Unlock()
+	}
+}
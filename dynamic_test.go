@@ -0,0 +1,74 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Builds a binary tree of work by having every node add its two children.
+func TestRunDynamic(t *testing.T) {
+	const depth = 10
+	var calls int32
+	var node func(level int) func(ctx context.Context) error
+	err := RunDynamic(context.Background(), 4, func(ctx context.Context, add AddFunc) error {
+		node = func(level int) func(ctx context.Context) error {
+			return func(ctx context.Context) error {
+				atomic.AddInt32(&calls, 1)
+				if level < depth {
+					add(node(level + 1))
+					add(node(level + 1))
+				}
+				return nil
+			}
+		}
+		add(node(0))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if expected := int32(1<<(depth+1) - 1); calls != expected {
+		t.Errorf("number of nodes: %d != number of times called: %d", expected, calls)
+	}
+}
+
+func TestRunDynamicError(t *testing.T) {
+	expectedError := errors.New("")
+	var calls int32
+	err := RunDynamic(context.Background(), 2, func(ctx context.Context, add AddFunc) error {
+		var loop func(ctx context.Context) error
+		loop = func(ctx context.Context) error {
+			if atomic.AddInt32(&calls, 1) == 50 {
+				return expectedError
+			}
+			add(loop)
+			return nil
+		}
+		add(loop)
+		return nil
+	})
+	if err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
+
+func TestRunDynamicParentCanceled(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	err := RunDynamic(parent, 2, func(ctx context.Context, add AddFunc) error {
+		var loop func(ctx context.Context) error
+		loop = func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			add(loop)
+			return nil
+		}
+		add(loop)
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected err returned: %#v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package spara
+
+import "context"
+
+// EarlyReturn makes the run return as soon as it's stopped, whether by an
+// error or by the parent context completing, rather than once every call to
+// the mapping function that's in flight at the time has returned. The calls
+// still in flight are left to finish on their own, on their workers, with
+// their contexts canceled. It's for callers on a latency budget, who would
+// rather answer now and let the stragglers wind down in the background, at
+// the cost of those goroutines, and whatever they're holding on to, living on
+// past the return.
+//
+// done, if not nil, is called once the run is completely over, every worker
+// included, with the error the run would have returned without EarlyReturn,
+// so it sees the whole story, eg every error when combined with
+// CollectErrors. It's called whether or not the run returned early, on a
+// goroutine of its own. Observers, like WithStats and WithHooks, also only
+// see the run end at that point, so their results aren't ready when an early
+// return happens.
+//
+// Since the mapping function can still be running after the run returns, it
+// mustn't touch anything the caller is going to reuse or free as soon as the
+// run is over.
+func EarlyReturn(done func(err error)) Option {
+	return func(c *config) {
+		c.earlyReturn = true
+		c.earlyDone = done
+	}
+}
+
+// runConfigured runs with a config built from options, which may ask for an
+// early return.
+func runConfigured(parent context.Context, iterations int, fn MappingFunc, c *config) error {
+	if !c.earlyReturn {
+		return run(parent, c.workers, iterations, fn, c)
+	}
+	// The whole run happens in the background, and this waits for it to
+	// either finish or be stopped, whichever comes first. stopped only ever
+	// gets one value, since only the first stop is reported.
+	stopped := make(chan error, 1)
+	c.earlyStop = func(err error) { stopped <- err }
+	result := make(chan error, 1)
+	go func() {
+		err := run(parent, c.workers, iterations, fn, c)
+		result <- err
+		if c.earlyDone != nil {
+			c.earlyDone(err)
+		}
+	}()
+	select {
+	case err := <-result:
+		return err
+	case err := <-stopped:
+		return err
+	}
+}
@@ -0,0 +1,65 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEarlyReturn(t *testing.T) {
+	expectedError := errors.New("boom")
+	release := make(chan struct{})
+	finished := make(chan error, 1)
+	started := time.Now()
+	err := Do(context.Background(), 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			time.Sleep(10 * time.Millisecond)
+			return expectedError
+		}
+		// A straggler that ignores its context.
+		<-release
+		return nil
+	}, Workers(2), EarlyReturn(func(err error) { finished <- err }))
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if time.Since(started) > time.Second {
+		t.Errorf("expected the run to return without waiting for the straggler")
+	}
+	select {
+	case <-finished:
+		t.Fatalf("expected the completion callback to wait for the straggler")
+	default:
+	}
+	close(release)
+	if err := <-finished; err != expectedError {
+		t.Errorf("expected the callback to get the run's error: %v", err)
+	}
+
+	// A run that isn't stopped returns as usual.
+	finished = make(chan error, 1)
+	err = Do(context.Background(), 10, func(ctx context.Context, i int) error { return nil }, EarlyReturn(func(err error) { finished <- err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-finished; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// As does one whose parent completes.
+	ctx, cancel := context.WithCancel(context.Background())
+	release = make(chan struct{})
+	defer close(release)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = Do(ctx, 1, func(ctx context.Context, i int) error {
+		<-release
+		return nil
+	}, EarlyReturn(nil))
+	if err != context.Canceled {
+		t.Errorf("expected the parent's error: %v", err)
+	}
+}
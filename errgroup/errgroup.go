@@ -0,0 +1,80 @@
+// Package errgroup bridges spara and golang.org/x/sync/errgroup, for code
+// that's moving from one to the other a piece at a time. Go runs a spara run
+// as a member of an existing errgroup.Group, and Group is a drop-in for
+// errgroup.Group whose goroutines come from a spara.Pool. (For x/sync's other
+// half, a *semaphore.Weighted is already a spara.Limiter.)
+package errgroup
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/heyimalex/spara"
+)
+
+// Go runs spara.Do as one of g's goroutines. Pass the context returned by
+// errgroup.WithContext as ctx, and the run and the rest of the group share
+// its error semantics: the run's first error is returned to the group and
+// cancels the others, and any other member failing cancels the run.
+func Go(g *errgroup.Group, ctx context.Context, iterations int, fn spara.MappingFunc, opts ...spara.Option) {
+	g.Go(func() error {
+		return spara.Do(ctx, iterations, fn, opts...)
+	})
+}
+
+// Group is a collection of goroutines working on subtasks of the same task,
+// with the same API and semantics as errgroup.Group, except that the
+// goroutines run on a spara.Pool. That lets code written against errgroup
+// share a pool with spara runs without being rewritten first. A zero Group,
+// or one with a nil pool, uses fresh goroutines, just like errgroup.
+type Group struct {
+	pool   *spara.Pool
+	cancel context.CancelCauseFunc
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// New returns a Group whose goroutines run on pool.
+func New(pool *spara.Pool) *Group {
+	return &Group{pool: pool}
+}
+
+// WithContext returns a Group whose goroutines run on pool, and a context
+// derived from ctx that's canceled the first time a function passed to Go
+// returns an error, or the first time Wait returns, whichever happens first.
+func WithContext(ctx context.Context, pool *spara.Pool) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{pool: pool, cancel: cancel}, ctx
+}
+
+// Go calls f on one of the pool's goroutines. The first call to return an
+// error cancels the group's context, if it has one, and its error is the one
+// Wait returns.
+func (g *Group) Go(f func() error) {
+	g.wg.Add(1)
+	g.pool.Go(func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			})
+		}
+	})
+}
+
+// Wait blocks until every call to Go has returned, and then returns the
+// first error any of them returned, if any.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
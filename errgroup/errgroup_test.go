@@ -0,0 +1,109 @@
+package errgroup
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/heyimalex/spara"
+)
+
+func TestGo(t *testing.T) {
+	expectedError := errors.New("boom")
+
+	// A failing member cancels the run.
+	g, ctx := errgroup.WithContext(context.Background())
+	g.Go(func() error { return expectedError })
+	Go(g, ctx, 10, func(ctx context.Context, i int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, spara.Workers(2))
+	if err := g.Wait(); err != expectedError {
+		t.Errorf("expected the member's error: %v", err)
+	}
+
+	// A failing run cancels the other members.
+	g, ctx = errgroup.WithContext(context.Background())
+	g.Go(func() error {
+		<-ctx.Done()
+		return nil
+	})
+	Go(g, ctx, 10, func(ctx context.Context, i int) error {
+		if i == 3 {
+			return expectedError
+		}
+		return nil
+	}, spara.Workers(2))
+	if err := g.Wait(); err != expectedError {
+		t.Errorf("expected the run's error: %v", err)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	pool, err := spara.NewPool(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	var calls int32
+	g := New(pool)
+	for i := 0; i < 20; i++ {
+		g.Go(func() error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil || calls != 20 {
+		t.Errorf("expected every function to run: %v, %d calls", err, calls)
+	}
+
+	expectedError := errors.New("boom")
+	g, ctx := WithContext(context.Background(), pool)
+	g.Go(func() error { return expectedError })
+	g.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := g.Wait(); err != expectedError {
+		t.Errorf("expected the first error: %v", err)
+	}
+	if context.Cause(ctx) != expectedError {
+		t.Errorf("expected the error as the context's cause: %v", context.Cause(ctx))
+	}
+
+	// The zero Group works like errgroup's.
+	var zero Group
+	zero.Go(func() error { return nil })
+	if err := zero.Wait(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// The adapter for x/sync's semaphore is the semaphore itself, so check here,
+// where the dependency already is, that it keeps satisfying spara.Limiter.
+func TestSemaphoreLimiter(t *testing.T) {
+	sem := semaphore.NewWeighted(2)
+	var inFlight, peak int32
+	err := spara.Do(context.Background(), 20, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	}, spara.Workers(4), spara.WithLimiter(sem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("expected at most 2 items in flight: %d", peak)
+	}
+}
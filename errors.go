@@ -0,0 +1,64 @@
+package spara
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ItemError is returned in place of the mapping function's error when the
+// item that failed has a name, either from the Names option or from a task
+// implementing NamedTask, or when the run itself was given a name with the
+// Named option. The original error is available through Unwrap, so
+// errors.Is and errors.As continue to work.
+type ItemError struct {
+	// Run is the name of the run, or empty.
+	Run   string
+	Index int
+	// Name is the name of the item, or empty.
+	Name string
+	Err  error
+}
+
+func (e *ItemError) Error() string {
+	msg := "spara: "
+	if e.Run != "" {
+		msg += e.Run + ": "
+	}
+	if e.Name != "" {
+		msg += e.Name + ": "
+	} else {
+		msg += "index " + strconv.Itoa(e.Index) + ": "
+	}
+	return msg + e.Err.Error()
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned when an argument or option is invalid. It
+// carries the offending value, and which parameter it was passed as, so that
+// a bad value from a config file says what it was rather than just that it
+// was wrong. It unwraps to one of the package's sentinel errors, so
+// errors.Is(err, ErrInvalidWorkers) and the like still work.
+type ValidationError struct {
+	// Param is the name of the parameter, option argument, or Config field
+	// the value was passed as.
+	Param string
+	Value any
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Err, e.Value)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalid reports value, passed as param, as invalid for the reason given by
+// the sentinel err.
+func invalid(err error, param string, value any) error {
+	return &ValidationError{Param: param, Value: value, Err: err}
+}
@@ -0,0 +1,33 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidationError(t *testing.T) {
+	err := Run(-3, 10, noopMappingFunc)
+	if !errors.Is(err, ErrInvalidWorkers) {
+		t.Fatalf("expected ErrInvalidWorkers: %v", err)
+	}
+	if err.Error() != "spara: invalid number of workers: -3" {
+		t.Errorf("expected the value in the message: %q", err)
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Param != "workers" || verr.Value != -3 {
+		t.Errorf("unexpected validation error: %+v", verr)
+	}
+
+	// Options and Config fields report under their own names.
+	noop := func(ctx context.Context, i int) error { return nil }
+	err = Do(context.Background(), 1, noop, ItemTimeout(-time.Second))
+	if !errors.As(err, &verr) || verr.Param != "itemTimeout" || err.Error() != "spara: invalid timeout: -1s" {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+	err = Config{ErrorPolicy: "ignore"}.Validate()
+	if !errors.As(err, &verr) || verr.Param != "ErrorPolicy" || err.Error() != "spara: invalid error policy: ignore" {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
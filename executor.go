@@ -0,0 +1,73 @@
+package spara
+
+import "context"
+
+// Executor keeps a fixed set of workers parked between runs, for code that
+// calls Run in a loop over small batches:
+//
+//	e, _ := spara.NewExecutor(8)
+//	defer e.Close()
+//	for _, batch := range batches {
+//		if err := e.Run(len(batch), process(batch)); err != nil {
+//			return err
+//		}
+//	}
+//
+// Each call to Run then hands its work to goroutines that are already
+// running, rather than paying to create and schedule fresh ones, which for
+// batches that only take microseconds can be most of the cost. It's a Pool
+// with the number of workers built in; like a Pool, it never limits
+// concurrency, so runs that overlap or nest still work, they just fall back
+// to fresh goroutines for whatever the executor can't cover.
+type Executor struct {
+	workers int
+	pool    *Pool
+}
+
+// NewExecutor starts an Executor whose runs use up to workers goroutines. The
+// calling goroutine always does its share of the work, so only workers-1 are
+// kept parked. The options configure the pool those are parked in, eg Spin.
+func NewExecutor(workers int, opts ...PoolOption) (*Executor, error) {
+	if workers <= 0 {
+		return nil, invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	e := &Executor{workers: workers}
+	if workers > 1 {
+		e.pool, _ = NewPool(workers-1, opts...)
+	}
+	return e, nil
+}
+
+// Run is like the package-level Run, with the executor's workers.
+func (e *Executor) Run(iterations int, fn func(index int) error) error {
+	if iterations < 0 {
+		return invalid(ErrInvalidIterations, "iterations", iterations)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	return runPlain(e.pool, e.workers, iterations, fn)
+}
+
+// RunWithContext is like the package-level RunWithContext, with the
+// executor's workers.
+func (e *Executor) RunWithContext(parent context.Context, iterations int, fn MappingFunc) error {
+	if iterations < 0 {
+		return invalid(ErrInvalidIterations, "iterations", iterations)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	if parent == nil {
+		return ErrNilContext
+	}
+	return run(parent, e.workers, iterations, fn, &config{pool: e.pool, poolSet: true})
+}
+
+// Close stops the parked workers, and waits for them to exit. Runs on the
+// executor after it's closed still work, on fresh goroutines.
+func (e *Executor) Close() {
+	if e.pool != nil {
+		e.pool.Close()
+	}
+}
@@ -0,0 +1,86 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExecutor(t *testing.T) {
+	if _, err := NewExecutor(0); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected ErrInvalidWorkers: %v", err)
+	}
+
+	for _, workers := range []int{1, 4} {
+		e, err := NewExecutor(workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for batch := 0; batch < 50; batch++ {
+			counts := make([]int32, 20)
+			err := e.Run(len(counts), func(i int) error {
+				atomic.AddInt32(&counts[i], 1)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = e.RunWithContext(context.Background(), len(counts), func(ctx context.Context, i int) error {
+				atomic.AddInt32(&counts[i], 1)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, count := range counts {
+				if count != 2 {
+					t.Fatalf("batch %d: index %d called %d times", batch, i, count)
+				}
+			}
+		}
+
+		expectedError := errors.New("boom")
+		if err := e.Run(10, func(i int) error { return expectedError }); err != expectedError {
+			t.Errorf("expected the error: %v", err)
+		}
+		if err := e.Run(-1, noopMappingFunc); !errors.Is(err, ErrInvalidIterations) {
+			t.Errorf("expected ErrInvalidIterations: %v", err)
+		}
+
+		e.Close()
+		if err := e.Run(10, noopMappingFunc); err != nil {
+			t.Errorf("expected runs after Close to work: %v", err)
+		}
+	}
+}
+
+func BenchmarkExecutor(b *testing.B) {
+	values := make([]int, 64)
+	fn := func(i int) error {
+		values[i]++
+		return nil
+	}
+	b.Run("Run", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Run(8, len(values), fn)
+		}
+	})
+	b.Run("Executor", func(b *testing.B) {
+		e, _ := NewExecutor(8)
+		defer e.Close()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			e.Run(len(values), fn)
+		}
+	})
+	b.Run("ExecutorSpin", func(b *testing.B) {
+		e, _ := NewExecutor(8, Spin(64))
+		defer e.Close()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			e.Run(len(values), fn)
+		}
+	})
+}
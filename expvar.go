@@ -0,0 +1,130 @@
+package spara
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats is a snapshot of the work a Pool has done.
+type PoolStats struct {
+	// Workers is the number of run workers currently executing on the pool.
+	Workers int `json:"workers"`
+	// InFlight is the number of items currently being processed.
+	InFlight int `json:"in_flight"`
+	// Processed and Failed count the items processed by runs on the pool
+	// since it was created, and how many of those returned an error.
+	Processed int64 `json:"processed"`
+	Failed    int64 `json:"failed"`
+}
+
+// Stats returns a snapshot of the pool's counters.
+func (p *Pool) Stats() PoolStats {
+	s := PoolStats{
+		Processed: atomic.LoadInt64(&p.processed),
+		Failed:    atomic.LoadInt64(&p.failed),
+	}
+	p.mu.Lock()
+	s.Workers = len(p.jobs)
+	for j := range p.jobs {
+		j.mu.Lock()
+		if j.busy {
+			s.InFlight++
+		}
+		j.mu.Unlock()
+	}
+	p.mu.Unlock()
+	return s
+}
+
+// Var returns an expvar.Var reporting the pool's Stats as JSON, for
+// publishing with expvar.Publish:
+//
+//	expvar.Publish("workers", pool.Var())
+func (p *Pool) Var() expvar.Var {
+	return expvar.Func(func() any { return p.Stats() })
+}
+
+// Counters is a set of live counters for one or more runs, which can be
+// published with expvar.Publish since it implements expvar.Var. A single
+// Counters can be passed to many runs, eg every run from the same call site,
+// to get totals for all of them.
+type Counters struct {
+	processed int64
+	failed    int64
+	inFlight  int64
+	queued    int64
+}
+
+// CountersSnapshot is a point in time copy of a Counters.
+type CountersSnapshot struct {
+	// Processed and Failed count the items processed, and how many of those
+	// returned an error.
+	Processed int64 `json:"processed"`
+	Failed    int64 `json:"failed"`
+	// InFlight is the number of items currently being processed.
+	InFlight int64 `json:"in_flight"`
+	// Queued is the number of items in running runs that haven't been
+	// started yet.
+	Queued int64 `json:"queued"`
+}
+
+// WithCounters updates c as the run progresses.
+func WithCounters(c *Counters) Option {
+	return func(cfg *config) {
+		if c != nil {
+			cfg.addObserver(&countersObserver{c: c})
+		}
+	}
+}
+
+// Snapshot returns the current value of every counter.
+func (c *Counters) Snapshot() CountersSnapshot {
+	return CountersSnapshot{
+		Processed: atomic.LoadInt64(&c.processed),
+		Failed:    atomic.LoadInt64(&c.failed),
+		InFlight:  atomic.LoadInt64(&c.inFlight),
+		Queued:    atomic.LoadInt64(&c.queued),
+	}
+}
+
+// String returns the counters as JSON, which makes Counters an expvar.Var.
+func (c *Counters) String() string {
+	b, _ := json.Marshal(c.Snapshot())
+	return string(b)
+}
+
+// countersObserver feeds a run's events into a Counters. It's separate from
+// Counters itself since every run needs to remember how many of its own
+// items it has yet to start, so it can take them back out of Queued.
+type countersObserver struct {
+	c       *Counters
+	pending int64
+}
+
+func (o *countersObserver) runStart(ctx context.Context, total int, start time.Time) {
+	atomic.StoreInt64(&o.pending, int64(total))
+	atomic.AddInt64(&o.c.queued, int64(total))
+}
+
+func (o *countersObserver) itemStart(worker, index int) {
+	atomic.AddInt64(&o.pending, -1)
+	atomic.AddInt64(&o.c.queued, -1)
+	atomic.AddInt64(&o.c.inFlight, 1)
+}
+
+func (o *countersObserver) itemDone(worker, index int, elapsed time.Duration, err error) {
+	atomic.AddInt64(&o.c.inFlight, -1)
+	atomic.AddInt64(&o.c.processed, 1)
+	if err != nil {
+		atomic.AddInt64(&o.c.failed, 1)
+	}
+}
+
+func (o *countersObserver) runEnd(err error, elapsed time.Duration) {
+	// Items that never started because the run stopped early are no longer
+	// queued.
+	atomic.AddInt64(&o.c.queued, -atomic.LoadInt64(&o.pending))
+}
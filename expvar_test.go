@@ -0,0 +1,77 @@
+package spara
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+	var counters Counters
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Do(context.Background(), 10, func(ctx context.Context, i int) error {
+			if i == 0 {
+				close(started)
+				<-release
+			}
+			return nil
+		}, Workers(1), WithCounters(&counters))
+	}()
+
+	<-started
+	s := counters.Snapshot()
+	if s.InFlight != 1 || s.Queued != 9 || s.Processed != 0 {
+		t.Errorf("unexpected counters mid-run: %+v", s)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	err := Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		if i == 4 {
+			return errors.New("boom")
+		}
+		return nil
+	}, Workers(1), WithCounters(&counters))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	s = counters.Snapshot()
+	if s.Processed != 15 || s.Failed != 1 || s.InFlight != 0 || s.Queued != 0 {
+		t.Errorf("unexpected counters after both runs: %+v", s)
+	}
+
+	var decoded CountersSnapshot
+	if err := json.Unmarshal([]byte(counters.String()), &decoded); err != nil || decoded != s {
+		t.Errorf("expected String to be the snapshot as JSON: %s %v", counters.String(), err)
+	}
+}
+
+func TestPoolVar(t *testing.T) {
+	p, err := NewPool(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer p.Close()
+	err = Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		if i == 9 {
+			return errors.New("boom")
+		}
+		return nil
+	}, Workers(1), WithPool(p))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	var stats PoolStats
+	if err := json.Unmarshal([]byte(p.Var().String()), &stats); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if stats.Processed != 10 || stats.Failed != 1 || stats.InFlight != 0 {
+		t.Errorf("unexpected pool stats: %+v", stats)
+	}
+}
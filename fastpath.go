@@ -0,0 +1,140 @@
+package spara
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// plainRun is the state shared by the workers of a Run call. Run has no
+// context to watch and no options to honor, so none of the machinery in run
+// is needed: a single allocation holds everything, and the workers are
+// started with a method value rather than a closure capturing a handful of
+// separately escaping locals.
+type plainRun struct {
+	index      indexCounter
+	iterations int
+	fn         func(index int) error
+	do         func(index int) // set instead of fn by RunNoErr
+
+	started int32 // the last start index handed to a pooled worker
+	failed  int32
+	err     error
+	wg      sync.WaitGroup
+}
+
+// runPlain is the fast path behind Run. Arguments are assumed to have been
+// validated. The calling goroutine acts as worker 0, so Run with a single
+// worker doesn't allocate at all, and with more only allocates the shared
+// state and the goroutines themselves. With a pool, the other workers run on
+// its goroutines where possible.
+func runPlain(pool *Pool, workers, iterations int, fn func(index int) error) error {
+	if iterations == 0 {
+		return nil
+	}
+	if workers > iterations {
+		workers = iterations
+	}
+	if forceSequential {
+		workers = 1
+	}
+	if workers == 1 {
+		for i := 0; i < iterations; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	r := &plainRun{iterations: iterations, fn: fn}
+	r.launch(pool, workers)
+	return r.err
+}
+
+// launch starts workers 1 through workers-1, runs worker 0 on the calling
+// goroutine, and waits for all of them to return.
+func (r *plainRun) launch(pool *Pool, workers int) {
+	r.index.n = int32(workers - 1)
+	r.wg.Add(workers - 1)
+	if pool == nil {
+		for i := 1; i < workers; i++ {
+			go r.worker(i)
+		}
+	} else {
+		// A single method value can be spawned for every worker, where a
+		// closure per worker would be an allocation each.
+		f := r.pooledWorker
+		for i := 1; i < workers; i++ {
+			pool.spawn(f)
+		}
+	}
+	r.work(0)
+	r.wg.Wait()
+}
+
+func (r *plainRun) worker(start int) {
+	defer r.wg.Done()
+	r.work(start)
+}
+
+// pooledWorker is worker for goroutines that can't be passed their start
+// index, which they take from a counter instead.
+func (r *plainRun) pooledWorker() {
+	r.worker(int(atomic.AddInt32(&r.started, 1)))
+}
+
+func (r *plainRun) work(start int) {
+	if r.do != nil {
+		for j := start; j < r.iterations; j = r.index.next() {
+			r.do(j)
+		}
+		return
+	}
+	for j := start; j < r.iterations; j = r.index.next() {
+		if err := r.fn(j); err != nil {
+			// The first error wins, and stops everyone else from claiming
+			// any more indices. r.err is only read after wg.Wait.
+			if atomic.CompareAndSwapInt32(&r.failed, 0, 1) {
+				r.err = err
+				r.index.stop(r.iterations)
+			}
+			return
+		}
+	}
+}
+
+// RunNoErr calls fn with every number in the range [0, iterations) across up
+// to workers goroutines, like Run, but for work that can't fail, like
+// transforming a big slice in place. With no errors to collect and nothing to
+// stop early for, it's about as little overhead as a parallel loop can have.
+//
+// Since there's no error to report them in, invalid arguments cause a panic
+// with the same errors Run would return.
+func RunNoErr(workers int, iterations int, fn func(index int)) {
+	mustCheck(workers, iterations, fn == nil)
+	if forceVerify {
+		runDefault(nil, workers, iterations, func(index int) error {
+			fn(index)
+			return nil
+		})
+		return
+	}
+	if iterations == 0 {
+		return
+	}
+	if workers > iterations {
+		workers = iterations
+	}
+	if forceSequential {
+		workers = 1
+	}
+	if workers == 1 {
+		for i := 0; i < iterations; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	r := &plainRun{iterations: iterations, do: fn}
+	r.launch(nil, workers)
+}
@@ -0,0 +1,93 @@
+package spara
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunPlain(t *testing.T) {
+	for _, workers := range []int{1, 4, 64} {
+		counts := make([]int32, 100)
+		err := Run(workers, len(counts), func(i int) error {
+			atomic.AddInt32(&counts[i], 1)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for i, count := range counts {
+			if count != 1 {
+				t.Fatalf("%d workers: index %d called %d times", workers, i, count)
+			}
+		}
+	}
+
+	expectedError := errors.New("boom")
+	var calls int32
+	err := Run(4, 1000, func(i int) error {
+		atomic.AddInt32(&calls, 1)
+		if i == 10 {
+			return expectedError
+		}
+		return nil
+	})
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if calls == 1000 {
+		t.Errorf("expected iteration to stop early")
+	}
+}
+
+func TestRunAllocations(t *testing.T) {
+	if testing.Short() || forceVerify {
+		// Verification allocates its counters, and takes the slow path.
+		t.Skip()
+	}
+	noop := func(int) error { return nil }
+	if allocs := testing.AllocsPerRun(100, func() { Run(1, 1000, noop) }); allocs != 0 {
+		t.Errorf("expected Run with one worker not to allocate: %v", allocs)
+	}
+	// The shared state, plus whatever starting each extra goroutine costs.
+	const workers = 4
+	allocs := testing.AllocsPerRun(100, func() { Run(workers, 1000, noop) })
+	if allocs > 1+2*(workers-1) {
+		t.Errorf("unexpected allocations for %d workers: %v", workers, allocs)
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	noop := func(int) error { return nil }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Run(4, 1000, noop)
+	}
+}
+
+func TestRunNoErr(t *testing.T) {
+	for _, workers := range []int{1, 4, 200} {
+		values := make([]int, 100)
+		RunNoErr(workers, len(values), func(i int) {
+			values[i] += i
+		})
+		for i, v := range values {
+			if v != i {
+				t.Fatalf("%d workers: index %d has %d", workers, i, v)
+			}
+		}
+	}
+	RunNoErr(1, 0, func(int) { t.Error("unexpected call") })
+
+	expectPanic(t, ErrInvalidWorkers, func() { RunNoErr(0, 10, func(int) {}) })
+	expectPanic(t, ErrInvalidIterations, func() { RunNoErr(1, -1, func(int) {}) })
+	expectPanic(t, ErrNilMappingFunction, func() { RunNoErr(1, 10, nil) })
+}
+
+func BenchmarkRunNoErr(b *testing.B) {
+	values := make([]int, 1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RunNoErr(4, len(values), func(j int) { values[j]++ })
+	}
+}
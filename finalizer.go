@@ -0,0 +1,74 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrItemPanicked = errors.New("spara: mapping function panicked")
+	ErrItemExited   = errors.New("spara: mapping function called runtime.Goexit")
+)
+
+// WithFinalizer registers a function that's called after every item, once
+// the mapping function is done with it, whether it succeeded, failed, or
+// panicked. It's the place to release anything the item acquired that has
+// to be released no matter what, where a defer in the mapping function isn't
+// an option, eg because the acquiring happens in a wrapper the mapping
+// function doesn't know about.
+//
+// finalize is passed the item's final error, after any retries, or an error
+// wrapping ErrItemPanicked and the panic's value if it panicked, in which case
+// the panic carries on once finalize returns. A mapping function that calls
+// runtime.Goexit, as t.FailNow does in tests, is finalized with
+// ErrItemExited, and its goroutine then goes on exiting. Items skipped
+// because the run stopped before they started aren't finalized, since
+// nothing was done for them.
+func WithFinalizer(finalize func(ctx context.Context, index int, err error)) Option {
+	return func(c *config) {
+		c.finalize = finalize
+	}
+}
+
+// withFinalizer returns middleware that calls the finalizer after every
+// call, or nil.
+//
+// recover's value can't tell a panic from runtime.Goexit, which it also
+// returns nil for, so the call happens in a closure of its own: after a panic
+// the closure's deferred recover stops it, and the code after the closure
+// runs, while Goexit carries on straight past it.
+func (c *config) withFinalizer() Middleware {
+	if c == nil || c.finalize == nil {
+		return nil
+	}
+	finalize := c.finalize
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) (err error) {
+			returned, recovered := false, false
+			var r any
+			defer func() {
+				switch {
+				case returned:
+					finalize(ctx, index, err)
+				case recovered:
+					finalize(ctx, index, fmt.Errorf("%w: %v", ErrItemPanicked, r))
+					panic(r)
+				default:
+					finalize(ctx, index, ErrItemExited)
+				}
+			}()
+			func() {
+				defer func() {
+					if !returned {
+						r = recover()
+					}
+				}()
+				err = fn(ctx, index)
+				returned = true
+			}()
+			recovered = !returned
+			return err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestWithFinalizer(t *testing.T) {
+	expectedError := errors.New("boom")
+	var (
+		mu        sync.Mutex
+		finalized = make(map[int]error)
+	)
+	finalize := func(ctx context.Context, index int, err error) {
+		mu.Lock()
+		finalized[index] = err
+		mu.Unlock()
+	}
+
+	err := Do(context.Background(), 3, func(ctx context.Context, i int) error {
+		if i == 2 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), WithFinalizer(finalize))
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if len(finalized) != 3 || finalized[0] != nil || finalized[1] != nil || finalized[2] != expectedError {
+		t.Errorf("expected every item to be finalized with its error: %v", finalized)
+	}
+
+	// A panic is finalized and then carries on. The mapping function is
+	// called directly, since a panic on a worker can't be recovered here.
+	finalized = make(map[int]error)
+	c := newConfig([]Option{WithFinalizer(finalize)})
+	fn := c.withFinalizer()(func(ctx context.Context, i int) error {
+		panic("oops")
+	})
+	func() {
+		defer func() {
+			if r := recover(); r != "oops" {
+				t.Errorf("expected the panic to carry on: %v", r)
+			}
+		}()
+		fn(context.Background(), 7)
+	}()
+	if err := finalized[7]; !errors.Is(err, ErrItemPanicked) {
+		t.Errorf("expected ErrItemPanicked: %v", err)
+	}
+}
+
+// TestWithFinalizerGoexit checks that runtime.Goexit is finalized as such, and
+// isn't turned into a panic.
+func TestWithFinalizerGoexit(t *testing.T) {
+	var finalized error
+	c := newConfig([]Option{WithFinalizer(func(ctx context.Context, index int, err error) {
+		finalized = err
+	})})
+	fn := c.withFinalizer()(func(ctx context.Context, i int) error {
+		runtime.Goexit()
+		return nil
+	})
+	var r any
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() { r = recover() }()
+		fn(context.Background(), 0)
+	}()
+	<-done
+	if r != nil {
+		t.Errorf("expected no panic: %v", r)
+	}
+	if finalized != ErrItemExited {
+		t.Errorf("expected ErrItemExited: %v", finalized)
+	}
+}
@@ -0,0 +1,253 @@
+package spara
+
+import (
+	"context"
+	"sync"
+)
+
+// ForkJoinFunc is a task in a ForkJoin computation. It can fork subtasks
+// through s, and wait on them with s.Join.
+type ForkJoinFunc func(ctx context.Context, s *Scope) error
+
+// ForkJoin runs fn as the root of a fork/join computation. Tasks can fork any
+// number of subtasks, which share the same budget of workers goroutines, and
+// then wait for them to complete. This is the shape of most divide and conquer
+// algorithms: split the input, fork a task for each half, join, and combine
+// the results.
+//
+// The naive way to build this on top of a bounded set of workers deadlocks as
+// soon as every worker is blocked waiting on subtasks that have no worker
+// left to run them. ForkJoin avoids this by never letting a worker sit idle
+// in Join while there is queued work; it runs its own unstarted subtasks
+// inline first, and then helps with anything else that's waiting.
+//
+// Error handling follows RunWithContext. The first task to return an error
+// cancels the context, tasks that haven't started yet are skipped, and
+// ForkJoin returns that first error once everything in progress completes.
+func ForkJoin(parent context.Context, workers int, fn ForkJoinFunc) error {
+	if workers <= 0 {
+		return invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	if parent == nil {
+		return ErrNilContext
+	}
+
+	// Eagerly check whether the parent context is already done.
+	select {
+	case <-parent.Done():
+		return parent.Err()
+	default:
+		break
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	fj := &forkJoin{ctx: ctx, cancel: cancel}
+	fj.cond.L = &fj.mu
+	fj.queue = append(fj.queue, &fjTask{fn: fn})
+
+	// Watch the parent the same way RunDynamic does.
+	if parent.Done() != nil {
+		stop := context.AfterFunc(parent, func() {
+			fj.mu.Lock()
+			if !fj.stopped && !fj.finished {
+				fj.stopped = true
+				fj.canceled = true
+				fj.cond.Broadcast()
+			}
+			fj.mu.Unlock()
+		})
+		defer stop()
+	}
+
+	pool := getDefaultPool()
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		pool.spawn(func() {
+			defer wg.Done()
+			fj.work()
+		})
+	}
+	wg.Wait()
+
+	if fj.firsterr != nil {
+		return fj.firsterr
+	}
+	if fj.canceled {
+		return parent.Err()
+	}
+	return nil
+}
+
+// Scope is handed to every task in a ForkJoin computation, and is how it
+// forks and joins subtasks. A Scope is only valid until the task it was
+// passed to returns.
+type Scope struct {
+	fj *forkJoin
+
+	// Both protected by fj.mu. children holds subtasks which may not have
+	// been claimed yet; claimed ones are trimmed lazily from the end.
+	children    []*fjTask
+	outstanding int
+	err         error
+}
+
+// Fork schedules fn to run as a subtask of the current task. It never blocks.
+// Forking after the computation has been stopped does nothing, though Join
+// will still report the error.
+func (s *Scope) Fork(fn ForkJoinFunc) {
+	if fn == nil {
+		return
+	}
+	fj := s.fj
+	fj.mu.Lock()
+	if !fj.stopped {
+		t := &fjTask{fn: fn, parent: s}
+		fj.queue = append(fj.queue, t)
+		s.children = append(s.children, t)
+		s.outstanding++
+		fj.cond.Broadcast()
+	}
+	fj.mu.Unlock()
+}
+
+// Join waits for every subtask forked from this scope so far to complete, and
+// returns the first error any of them returned. If the computation was
+// stopped before some of them could run, Join returns the context's error
+// instead, since their results are missing.
+//
+// Rather than blocking, Join runs the scope's own unstarted subtasks on the
+// calling goroutine, and after that, any other queued work. Tasks don't need
+// to call Join before returning; it's called implicitly, so a task is never
+// considered complete while its subtasks are still running.
+func (s *Scope) Join() error {
+	fj := s.fj
+	fj.mu.Lock()
+	for s.outstanding > 0 {
+		t := s.claimChildLocked()
+		if t == nil {
+			t = fj.claimLocked()
+		}
+		if t == nil {
+			fj.cond.Wait()
+			continue
+		}
+		fj.mu.Unlock()
+		fj.run(t)
+		fj.mu.Lock()
+	}
+	s.children = nil
+	err := s.err
+	s.err = nil
+	fj.mu.Unlock()
+	return err
+}
+
+// claimChildLocked claims the most recently forked child that no worker has
+// started yet, or returns nil.
+func (s *Scope) claimChildLocked() *fjTask {
+	for len(s.children) > 0 {
+		t := s.children[len(s.children)-1]
+		s.children[len(s.children)-1] = nil
+		s.children = s.children[:len(s.children)-1]
+		if !t.claimed {
+			t.claimed = true
+			return t
+		}
+	}
+	return nil
+}
+
+type fjTask struct {
+	fn      ForkJoinFunc
+	parent  *Scope // nil for the root
+	claimed bool   // protected by forkJoin.mu
+}
+
+// forkJoin is the shared state behind a ForkJoin call. Everything is guarded
+// by a single mutex; fork/join tasks are expected to be coarse enough that
+// this isn't the bottleneck.
+type forkJoin struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	cond     sync.Cond
+	queue    []*fjTask
+	stopped  bool
+	canceled bool // stopped because the parent context completed
+	finished bool // the root task completed
+	firsterr error
+}
+
+// claimLocked pops and claims the newest queued task, skipping any that were
+// already claimed inline by Join.
+func (fj *forkJoin) claimLocked() *fjTask {
+	for len(fj.queue) > 0 {
+		t := fj.queue[len(fj.queue)-1]
+		fj.queue[len(fj.queue)-1] = nil
+		fj.queue = fj.queue[:len(fj.queue)-1]
+		if !t.claimed {
+			t.claimed = true
+			return t
+		}
+	}
+	return nil
+}
+
+// work is the loop each worker goroutine runs until the root task completes.
+func (fj *forkJoin) work() {
+	fj.mu.Lock()
+	for !fj.finished {
+		t := fj.claimLocked()
+		if t == nil {
+			fj.cond.Wait()
+			continue
+		}
+		fj.mu.Unlock()
+		fj.run(t)
+		fj.mu.Lock()
+	}
+	fj.mu.Unlock()
+}
+
+// run executes a claimed task, implicitly joins its subtasks, and reports its
+// completion to its parent scope.
+func (fj *forkJoin) run(t *fjTask) {
+	fj.mu.Lock()
+	stopped := fj.stopped
+	fj.mu.Unlock()
+
+	var err error
+	if stopped {
+		err = fj.ctx.Err()
+	} else {
+		s := &Scope{fj: fj}
+		err = t.fn(fj.ctx, s)
+		if joinErr := s.Join(); err == nil {
+			err = joinErr
+		}
+	}
+
+	fj.mu.Lock()
+	if err != nil && !fj.stopped {
+		fj.stopped = true
+		fj.firsterr = err
+		fj.cancel()
+	}
+	if t.parent != nil {
+		if err != nil && t.parent.err == nil {
+			t.parent.err = err
+		}
+		t.parent.outstanding--
+	} else {
+		fj.finished = true
+	}
+	fj.cond.Broadcast()
+	fj.mu.Unlock()
+}
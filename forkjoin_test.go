@@ -0,0 +1,79 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Sums a slice by recursively splitting it in half. With only two workers and
+// a deep tree, this deadlocks unless Join helps with queued work.
+func TestForkJoinSum(t *testing.T) {
+	input := make([]int, 10000)
+	expected := 0
+	for i := range input {
+		input[i] = i
+		expected += i
+	}
+
+	var sum func(xs []int, out *int) ForkJoinFunc
+	sum = func(xs []int, out *int) ForkJoinFunc {
+		return func(ctx context.Context, s *Scope) error {
+			if len(xs) <= 16 {
+				for _, x := range xs {
+					*out += x
+				}
+				return nil
+			}
+			var left, right int
+			s.Fork(sum(xs[:len(xs)/2], &left))
+			s.Fork(sum(xs[len(xs)/2:], &right))
+			if err := s.Join(); err != nil {
+				return err
+			}
+			*out = left + right
+			return nil
+		}
+	}
+
+	var total int
+	if err := ForkJoin(context.Background(), 2, sum(input, &total)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if total != expected {
+		t.Errorf("sum: %d != expected: %d", total, expected)
+	}
+}
+
+func TestForkJoinError(t *testing.T) {
+	expectedError := errors.New("")
+	var tree func(depth int) ForkJoinFunc
+	tree = func(depth int) ForkJoinFunc {
+		return func(ctx context.Context, s *Scope) error {
+			if depth == 0 {
+				return expectedError
+			}
+			s.Fork(tree(depth - 1))
+			s.Fork(tree(depth - 1))
+			return s.Join()
+		}
+	}
+	if err := ForkJoin(context.Background(), 4, tree(8)); err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
+
+func TestForkJoinParentCanceled(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	var loop ForkJoinFunc
+	loop = func(ctx context.Context, s *Scope) error {
+		time.Sleep(time.Millisecond)
+		s.Fork(loop)
+		return nil
+	}
+	if err := ForkJoin(parent, 2, loop); err != context.DeadlineExceeded {
+		t.Errorf("unexpected err returned: %#v", err)
+	}
+}
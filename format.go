@@ -0,0 +1,53 @@
+package spara
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// formatCount formats a non-negative n compactly for people, eg 1234 as "1.2k", keeping at
+// most one decimal place.
+func formatCount(n float64) string {
+	const units = "kMGT"
+	if n < 999.5 {
+		return strconv.FormatFloat(n, 'f', 0, 64)
+	}
+	for i := 0; i < len(units); i++ {
+		n /= 1000
+		// Pick the unit by what the value rounds to, so 999,960 comes out
+		// as "1M" rather than "1000k".
+		if r := roundTenths(n); r < 999.95 || i == len(units)-1 {
+			return strconv.FormatFloat(r, 'f', -1, 64) + units[i:i+1]
+		}
+	}
+	panic("unreachable")
+}
+
+func roundTenths(n float64) float64 {
+	return math.Round(n*10) / 10
+}
+
+// formatRate formats a per second rate compactly, eg "410/s" or "2.5/s".
+func formatRate(rate float64) string {
+	if rate < 10 {
+		return strconv.FormatFloat(roundTenths(rate), 'f', -1, 64) + "/s"
+	}
+	return formatCount(rate) + "/s"
+}
+
+// formatDuration rounds d to a precision that suits its size, since nobody
+// reading a status line cares about the nanoseconds in an ETA of 21s.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 10*time.Second:
+		d = d.Round(time.Second)
+	case d >= time.Second:
+		d = d.Round(100 * time.Millisecond)
+	case d >= 10*time.Millisecond:
+		d = d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		d = d.Round(100 * time.Microsecond)
+	}
+	return d.String()
+}
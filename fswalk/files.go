@@ -0,0 +1,158 @@
+package fswalk
+
+import (
+	"context"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/heyimalex/spara"
+	"github.com/heyimalex/spara/internal/ctxio"
+)
+
+// FileResult is the outcome of processing a single file with HashFiles or
+// CopyFiles. Failures are reported per file in Err, rather than stopping
+// everything else.
+type FileResult struct {
+	Path string
+	// Size is the number of bytes read from the file.
+	Size int64
+	// Sum is the file's hash, for HashFiles.
+	Sum []byte
+	Err error
+}
+
+// FileOption configures HashFiles and CopyFiles.
+type FileOption func(*fileConfig)
+
+type fileConfig struct {
+	progress func(result FileResult, done, total int)
+}
+
+// Progress registers fn to be called as each file finishes, with its result
+// and the number of files finished so far. Calls are serialized, so fn
+// doesn't need to worry about concurrency, but it does hold up the next
+// report until it returns.
+func Progress(fn func(result FileResult, done, total int)) FileOption {
+	return func(c *fileConfig) {
+		c.progress = fn
+	}
+}
+
+// Files returns the path of every regular file in the tree rooted at root,
+// in no particular order, listing directories concurrently with WalkDir. It's
+// the usual way to get the input for HashFiles and CopyFiles.
+func Files(parent context.Context, workers int, fsys fs.FS, root string) ([]string, error) {
+	var mu sync.Mutex
+	var paths []string
+	err := WalkDir(parent, workers, fsys, root, func(ctx context.Context, path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			mu.Lock()
+			paths = append(paths, path)
+			mu.Unlock()
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// HashFiles hashes every file in paths with a fresh hash from newHash, across
+// up to workers goroutines. The results are in the same order as paths.
+// Errors hashing individual files are reported in their results; the
+// returned error is only for the run as a whole, eg the parent context being
+// canceled, in which case files that never started report it too.
+func HashFiles(parent context.Context, workers int, fsys fs.FS, paths []string, newHash func() hash.Hash, opts ...FileOption) ([]FileResult, error) {
+	if newHash == nil {
+		return nil, spara.ErrNilMappingFunction
+	}
+	return eachFile(parent, workers, paths, opts, func(ctx context.Context, r *FileResult) error {
+		f, err := fsys.Open(r.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := newHash()
+		r.Size, err = io.Copy(h, ctxio.NewReader(ctx, f))
+		if err != nil {
+			return err
+		}
+		r.Sum = h.Sum(nil)
+		return nil
+	})
+}
+
+// CopyFiles copies every file in paths from fsys to the same relative path
+// under the directory dst on the local filesystem, creating directories as
+// needed, across up to workers goroutines. Permission bits are copied from
+// the source. Results and errors are reported the same way as HashFiles.
+func CopyFiles(parent context.Context, workers int, fsys fs.FS, paths []string, dst string, opts ...FileOption) ([]FileResult, error) {
+	return eachFile(parent, workers, paths, opts, func(ctx context.Context, r *FileResult) error {
+		src, err := fsys.Open(r.Path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		info, err := src.Stat()
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(r.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		r.Size, err = io.Copy(out, ctxio.NewReader(ctx, src))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
+
+// eachFile is the shared driver for the per-file helpers. process fills in
+// the result for one file, and its error becomes the result's Err rather
+// than stopping the run.
+func eachFile(parent context.Context, workers int, paths []string, opts []FileOption, process func(ctx context.Context, r *FileResult) error) ([]FileResult, error) {
+	var c fileConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	results := make([]FileResult, len(paths))
+	started := make([]bool, len(paths))
+	for i, path := range paths {
+		results[i].Path = path
+	}
+
+	var mu sync.Mutex
+	done := 0
+	err := spara.RunWithContext(parent, workers, len(paths), func(ctx context.Context, i int) error {
+		started[i] = true
+		r := &results[i]
+		r.Err = process(ctx, r)
+		if c.progress != nil {
+			mu.Lock()
+			done++
+			c.progress(*r, done, len(paths))
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		for i := range results {
+			if !started[i] {
+				results[i].Err = err
+			}
+		}
+	}
+	return results, err
+}
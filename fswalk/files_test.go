@@ -0,0 +1,83 @@
+package fswalk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func contentFS() fstest.MapFS {
+	return fstest.MapFS{
+		"a.txt":         {Data: []byte("alpha"), Mode: 0o644},
+		"dir/b.txt":     {Data: []byte("bravo"), Mode: 0o600},
+		"dir/sub/c.txt": {Data: []byte("charlie"), Mode: 0o644},
+	}
+}
+
+func TestHashFiles(t *testing.T) {
+	fsys := contentFS()
+	paths, err := Files(context.Background(), 2, fsys, ".")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Strings(paths)
+	paths = append(paths, "missing.txt")
+
+	var reports int
+	results, err := HashFiles(context.Background(), 2, fsys, paths, sha256.New, Progress(func(r FileResult, done, total int) {
+		reports++
+		if total != len(paths) || done != reports {
+			t.Errorf("unexpected progress: done=%d total=%d", done, total)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if reports != len(paths) {
+		t.Errorf("expected a progress report per file: %d", reports)
+	}
+	for i, r := range results[:3] {
+		expected := sha256.Sum256(fsys[paths[i]].Data)
+		if r.Err != nil || !bytes.Equal(r.Sum, expected[:]) {
+			t.Errorf("unexpected result for %s: %+v", paths[i], r)
+		}
+	}
+	if missing := results[3]; missing.Err == nil {
+		t.Errorf("expected an error hashing a missing file: %+v", missing)
+	}
+}
+
+func TestCopyFiles(t *testing.T) {
+	fsys := contentFS()
+	dst := t.TempDir()
+	paths := []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"}
+	results, err := CopyFiles(context.Background(), 2, fsys, paths, dst)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("failed to copy %s: %v", r.Path, r.Err)
+		}
+		data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(r.Path)))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !bytes.Equal(data, fsys[r.Path].Data) || r.Size != int64(len(data)) {
+			t.Errorf("copy of %s doesn't match: %q", r.Path, data)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dst, "dir", "b.txt"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info.Mode().Perm() != fs.FileMode(0o600) {
+		t.Errorf("permissions were not copied: %v", info.Mode())
+	}
+}
@@ -0,0 +1,103 @@
+// Package fswalk walks file trees concurrently. Directories are listed in
+// parallel, and every directory discovered feeds back into the same bounded
+// set of workers, which makes a large difference for deep trees on storage
+// with any real latency.
+package fswalk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+
+	"github.com/heyimalex/spara"
+)
+
+// WalkDirFunc is the type of function called for each file or directory
+// visited by WalkDir. It's the same as fs.WalkDirFunc, with the addition of
+// a context that is canceled when the walk stops early.
+type WalkDirFunc func(ctx context.Context, path string, d fs.DirEntry, err error) error
+
+// errSkipAll is returned inside the run when fn asks to stop with
+// fs.SkipAll. The run treats it like any other error, which stops everything,
+// and WalkDir translates it back to nil on the way out.
+var errSkipAll = errors.New("fswalk: skip all")
+
+// WalkDir walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root, across up to workers goroutines.
+// It follows the rules of fs.WalkDir wherever they make sense concurrently:
+//
+//   - fn is called for a directory before it's read, and returning
+//     fs.SkipDir skips its contents.
+//   - If reading a directory fails, fn is called a second time for it with
+//     the error, and may return nil or fs.SkipDir to carry on.
+//   - Returning fs.SkipAll stops the walk, and WalkDir returns nil.
+//   - Any other error stops the walk, and is returned.
+//
+// The differences come from there being no single order to the walk. fn is
+// called concurrently, and in no particular order, other than a directory
+// always being visited before its contents. Returning fs.SkipDir for a file
+// has no effect, since the rest of its directory may already be underway.
+// Stopping early cancels the context passed to fn, and WalkDir waits for
+// calls in progress to return, as with spara.RunWithContext.
+func WalkDir(parent context.Context, workers int, fsys fs.FS, root string, fn WalkDirFunc) error {
+	if fn == nil {
+		return spara.ErrNilMappingFunction
+	}
+	err := spara.RunDynamic(parent, workers, func(ctx context.Context, add spara.AddFunc) error {
+		info, err := fs.Stat(fsys, root)
+		if err != nil {
+			err = fn(ctx, root, nil, err)
+		} else {
+			err = visit(ctx, add, fsys, root, fs.FileInfoToDirEntry(info), fn)
+		}
+		return translate(err)
+	})
+	if err == errSkipAll {
+		return nil
+	}
+	return err
+}
+
+// visit calls fn for a single entry, and if it's a directory, lists it and
+// adds each of its entries to the walk.
+func visit(ctx context.Context, add spara.AddFunc, fsys fs.FS, name string, d fs.DirEntry, fn WalkDirFunc) error {
+	if err := fn(ctx, name, d, nil); err != nil {
+		if err == fs.SkipDir && d.IsDir() {
+			return nil
+		}
+		return translate(err)
+	}
+	if !d.IsDir() {
+		return nil
+	}
+
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		if err := fn(ctx, name, d, err); err != nil {
+			return translate(err)
+		}
+		// fs.ReadDir may have returned some entries alongside the error;
+		// keep going with those, as fs.WalkDir does.
+	}
+	for _, entry := range entries {
+		child := path.Join(name, entry.Name())
+		entry := entry
+		add(func(ctx context.Context) error {
+			return visit(ctx, add, fsys, child, entry, fn)
+		})
+	}
+	return nil
+}
+
+// translate maps the errors fn may return onto what the run should do with
+// them.
+func translate(err error) error {
+	switch err {
+	case fs.SkipDir:
+		return nil
+	case fs.SkipAll:
+		return errSkipAll
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package fswalk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"a/1.txt":        {},
+		"a/2.txt":        {},
+		"a/b/3.txt":      {},
+		"a/b/c/4.txt":    {},
+		"a/skip/5.txt":   {},
+		"a/skip/d/6.txt": {},
+		"e/7.txt":        {},
+	}
+}
+
+func walk(t *testing.T, fsys fs.FS, fn func(path string, d fs.DirEntry) error) ([]string, error) {
+	var mu sync.Mutex
+	var visited []string
+	err := WalkDir(context.Background(), 4, fsys, ".", func(ctx context.Context, path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		visited = append(visited, path)
+		mu.Unlock()
+		if fn != nil {
+			return fn(path, d)
+		}
+		return nil
+	})
+	sort.Strings(visited)
+	return visited, err
+}
+
+func TestWalkDir(t *testing.T) {
+	fsys := testFS()
+	var expected []string
+	fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		expected = append(expected, path)
+		return nil
+	})
+	sort.Strings(expected)
+
+	visited, err := walk(t, fsys, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if strings.Join(visited, ",") != strings.Join(expected, ",") {
+		t.Errorf("visited: %v != expected: %v", visited, expected)
+	}
+}
+
+func TestWalkDirSkipDir(t *testing.T) {
+	visited, err := walk(t, testFS(), func(path string, d fs.DirEntry) error {
+		if path == "a/skip" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, path := range visited {
+		if strings.HasPrefix(path, "a/skip/") {
+			t.Errorf("visited %s inside a skipped directory", path)
+		}
+	}
+}
+
+func TestWalkDirSkipAll(t *testing.T) {
+	_, err := walk(t, testFS(), func(path string, d fs.DirEntry) error {
+		if path == "a/b" {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected SkipAll to stop the walk without an error: %v", err)
+	}
+}
+
+func TestWalkDirError(t *testing.T) {
+	expectedError := errors.New("")
+	_, err := walk(t, testFS(), func(path string, d fs.DirEntry) error {
+		if path == "a/b/c/4.txt" {
+			return expectedError
+		}
+		return nil
+	})
+	if err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+
+	err = WalkDir(context.Background(), 2, testFS(), "missing", func(ctx context.Context, path string, d fs.DirEntry, err error) error {
+		return err
+	})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a not exist error for a missing root: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRun2D(t *testing.T) {
+	for _, tc := range []struct{ rows, cols, tile int }{
+		{0, 0, 4},
+		{1, 1, 4},
+		{10, 10, 1},
+		{10, 10, 3},
+		{17, 5, 4},
+		{5, 17, 100},
+	} {
+		counts := make([]int32, tc.rows*tc.cols)
+		err := Run2D(context.Background(), 4, tc.rows, tc.cols, tc.tile, func(ctx context.Context, row, col int) error {
+			if index, _ := ItemIndex(ctx); index != row*tc.cols+col {
+				t.Errorf("unexpected index for (%d, %d): %d", row, col, index)
+			}
+			atomic.AddInt32(&counts[row*tc.cols+col], 1)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%+v: %v", tc, err)
+		}
+		for i, count := range counts {
+			if count != 1 {
+				t.Fatalf("%+v: cell (%d, %d) visited %d times", tc, i/tc.cols, i%tc.cols, count)
+			}
+		}
+	}
+}
+
+func TestRun2DTiles(t *testing.T) {
+	// With one worker, the cells of each tile are visited together.
+	var order [][2]int
+	err := Run2D(context.Background(), 1, 4, 4, 2, func(ctx context.Context, row, col int) error {
+		order = append(order, [2]int{row, col})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]int{
+		{0, 0}, {0, 1}, {1, 0}, {1, 1},
+		{0, 2}, {0, 3}, {1, 2}, {1, 3},
+		{2, 0}, {2, 1}, {3, 0}, {3, 1},
+		{2, 2}, {2, 3}, {3, 2}, {3, 3},
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("unexpected order: %v", order)
+		}
+	}
+}
+
+func TestRun2DErrors(t *testing.T) {
+	noop := func(ctx context.Context, row, col int) error { return nil }
+	if err := Run2D(context.Background(), 1, -1, 1, 1, noop); !errors.Is(err, ErrInvalidIterations) {
+		t.Errorf("expected ErrInvalidIterations: %v", err)
+	}
+	if err := Run2D(context.Background(), 1, 1, 1, 0, noop); !errors.Is(err, ErrInvalidTileSize) {
+		t.Errorf("expected ErrInvalidTileSize: %v", err)
+	}
+	expectedError := errors.New("boom")
+	err := Run2D(context.Background(), 2, 10, 10, 3, func(ctx context.Context, row, col int) error {
+		if row == 5 && col == 5 {
+			return expectedError
+		}
+		return nil
+	})
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+}
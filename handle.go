@@ -0,0 +1,108 @@
+package spara
+
+import (
+	"context"
+	"time"
+)
+
+// Handle is a run started in the background by Start. It's for callers that
+// want to do something other than block while the run goes on, like showing
+// its progress, or canceling it from elsewhere, without wrapping the run in
+// goroutines and channels of their own.
+type Handle struct {
+	done    chan struct{}
+	err     error
+	cancel  context.CancelCauseFunc
+	tracker *Tracker
+	clock   Clock
+}
+
+// Start is like Do, but returns as soon as the run has started, with a
+// Handle for following it, rather than blocking until it's over. The run
+// uses workers goroutines, overriding any Workers option. Invalid arguments
+// aren't reported until Wait, which returns them straight away.
+//
+// The handle tracks the run's progress with a Tracker of its own, so don't
+// pass Start one with Track.
+func Start(parent context.Context, workers int, iterations int, fn MappingFunc, opts ...Option) *Handle {
+	h := &Handle{
+		done:    make(chan struct{}),
+		tracker: NewTracker(0),
+		clock:   newConfig(opts).getClock(),
+	}
+	if parent == nil {
+		// Leave it to Do to reject.
+		h.cancel = func(error) {}
+	} else {
+		parent, h.cancel = context.WithCancelCause(parent)
+	}
+	opts = append(opts[:len(opts):len(opts)], Workers(workers), Track(h.tracker))
+	go func() {
+		defer close(h.done)
+		defer h.cancel(nil)
+		h.err = Do(parent, iterations, fn, opts...)
+	}()
+	return h
+}
+
+// Done returns a channel that's closed once the run is over.
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err returns what the run returned, or nil if it's still going.
+func (h *Handle) Err() error {
+	select {
+	case <-h.done:
+		return h.err
+	default:
+		return nil
+	}
+}
+
+// Progress returns how far along the run is. See Tracker.Progress.
+func (h *Handle) Progress() Progress {
+	return h.tracker.Progress()
+}
+
+// Cancel stops the run, as if its parent context had been canceled: Wait
+// returns context.Canceled, and cause is what context.Cause reports for the
+// contexts passed to the mapping function. Cancel doesn't wait for the run to
+// stop, and does nothing once it has.
+func (h *Handle) Cancel(cause error) {
+	h.cancel(cause)
+}
+
+// Wait blocks until the run is over, and returns what Do would have.
+func (h *Handle) Wait() error {
+	<-h.done
+	return h.err
+}
+
+// WaitFor blocks until the run is over or d has passed, whichever comes
+// first, and reports whether the run is over. The run carries on either way;
+// call Wait once WaitFor returns true for its result. d is measured by the
+// run's Clock.
+func (h *Handle) WaitFor(d time.Duration) (done bool) {
+	t := h.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-h.done:
+		return true
+	case <-t.C():
+		return false
+	}
+}
+
+// WaitContext is like Wait, but gives up waiting once ctx is done, returning
+// ctx.Err(). Giving up doesn't stop the run, which has a context of its own;
+// it's for callers that can only afford to wait so long, and will check back
+// on the run later.
+func (h *Handle) WaitContext(ctx context.Context) error {
+	select {
+	case <-h.done:
+		return h.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
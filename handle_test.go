@@ -0,0 +1,102 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHandleWait(t *testing.T) {
+	release := make(chan struct{})
+	h := Start(context.Background(), 2, 4, func(ctx context.Context, i int) error {
+		<-release
+		return nil
+	})
+	if h.WaitFor(10 * time.Millisecond) {
+		t.Errorf("expected the run to still be going")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected waiting to give up: %v", err)
+	}
+	close(release)
+	if !h.WaitFor(time.Second) {
+		t.Errorf("expected the run to be over")
+	}
+	if err := h.Wait(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := h.WaitContext(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expectedError := errors.New("boom")
+	h = Start(context.Background(), 2, 4, func(ctx context.Context, i int) error { return expectedError })
+	if err := h.Wait(); err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	h = Start(context.Background(), 0, 4, func(ctx context.Context, i int) error { return nil })
+	if err := h.Wait(); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected ErrInvalidWorkers: %v", err)
+	}
+}
+
+func TestHandleCancel(t *testing.T) {
+	cause := errors.New("shutting down")
+	gotCause := make(chan error, 1)
+	release := make(chan struct{})
+	h := Start(context.Background(), 1, 10, func(ctx context.Context, i int) error {
+		if i < 3 {
+			return nil
+		}
+		close(release)
+		<-ctx.Done()
+		gotCause <- context.Cause(ctx)
+		return ctx.Err()
+	})
+	<-release
+	if p := h.Progress(); p.Total != 10 || p.Done != 3 {
+		t.Errorf("unexpected progress: %+v", p)
+	}
+	if h.Err() != nil {
+		t.Errorf("expected no error while the run is going: %v", h.Err())
+	}
+	select {
+	case <-h.Done():
+		t.Fatalf("expected the run to still be going")
+	default:
+	}
+
+	h.Cancel(cause)
+	<-h.Done()
+	if err := h.Err(); err != context.Canceled {
+		t.Errorf("expected context.Canceled: %v", err)
+	}
+	if err := <-gotCause; err != cause {
+		t.Errorf("expected the cause: %v", err)
+	}
+	h.Cancel(errors.New("again"))
+}
+
+func TestHandleWaitForClock(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	release := make(chan struct{})
+	h := Start(context.Background(), 1, 1, func(ctx context.Context, i int) error {
+		<-release
+		return nil
+	}, WithClock(clock))
+	waited := make(chan bool, 1)
+	go func() { waited <- h.WaitFor(time.Hour) }()
+	// One timer for the handle's tracker, and one for WaitFor.
+	clock.waitFor(t, 2)
+	clock.advance(time.Hour)
+	if <-waited {
+		t.Errorf("expected the run to still be going")
+	}
+	close(release)
+	if err := h.Wait(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidBuckets = errors.New("spara: histogram buckets must be positive and increasing")
+
+// DefaultBuckets are the histogram bucket bounds used when NewHistogram is
+// given none, spanning from a millisecond to a minute.
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// Histogram counts item durations into buckets. Unlike Stats, which keeps
+// every duration until the end of a run, a histogram is a fixed size, so it
+// can safely be shared across many runs, or a single huge one.
+type Histogram struct {
+	bounds []time.Duration
+	counts []int64 // len(bounds)+1, the last for everything over the top bound
+	sum    int64
+}
+
+// Bucket is a single histogram bucket. It counts the durations greater than
+// the previous bucket's bound and less than or equal to UpperBound. The last
+// bucket's UpperBound is the largest possible duration.
+type Bucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds, which
+// must be positive and strictly increasing. With no bounds, DefaultBuckets
+// are used.
+func NewHistogram(bounds ...time.Duration) (*Histogram, error) {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+	for i, b := range bounds {
+		if b <= 0 || (i > 0 && b <= bounds[i-1]) {
+			return nil, invalid(ErrInvalidBuckets, "bounds", bounds)
+		}
+	}
+	return &Histogram{
+		bounds: append([]time.Duration(nil), bounds...),
+		counts: make([]int64, len(bounds)+1),
+	}, nil
+}
+
+// WithHistogram records the duration of every item into h.
+func WithHistogram(h *Histogram) Option {
+	return func(c *config) {
+		if h != nil {
+			c.addObserver(h)
+		}
+	}
+}
+
+// Observe records a single duration. It's safe to call concurrently, which
+// also makes it usable for timing things other than spara items.
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// Buckets returns a snapshot of the histogram's buckets. Counts aren't
+// cumulative; each bucket only counts the durations that fell into it.
+func (h *Histogram) Buckets() []Bucket {
+	buckets := make([]Bucket, len(h.counts))
+	for i := range buckets {
+		buckets[i].Count = atomic.LoadInt64(&h.counts[i])
+		if i < len(h.bounds) {
+			buckets[i].UpperBound = h.bounds[i]
+		} else {
+			buckets[i].UpperBound = time.Duration(1<<63 - 1)
+		}
+	}
+	return buckets
+}
+
+// Count returns the number of durations recorded.
+func (h *Histogram) Count() int64 {
+	var n int64
+	for i := range h.counts {
+		n += atomic.LoadInt64(&h.counts[i])
+	}
+	return n
+}
+
+// Sum returns the total of every duration recorded.
+func (h *Histogram) Sum() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.sum))
+}
+
+func (h *Histogram) runStart(ctx context.Context, total int, start time.Time) {}
+
+func (h *Histogram) itemDone(worker, index int, elapsed time.Duration, err error) {
+	h.Observe(elapsed)
+}
+
+func (h *Histogram) runEnd(err error, elapsed time.Duration) {}
@@ -0,0 +1,46 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h, err := NewHistogram(10, 20, 30)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, d := range []time.Duration{1, 10, 11, 25, 30, 31, 100} {
+		h.Observe(d)
+	}
+	expected := []int64{2, 1, 2, 2}
+	for i, b := range h.Buckets() {
+		if b.Count != expected[i] {
+			t.Errorf("unexpected count in bucket %d: %+v", i, h.Buckets())
+		}
+	}
+	if h.Count() != 7 || h.Sum() != 208 {
+		t.Errorf("unexpected count or sum: %d %v", h.Count(), h.Sum())
+	}
+
+	for _, bounds := range [][]time.Duration{{0}, {10, 10}, {20, 10}} {
+		if _, err := NewHistogram(bounds...); !errors.Is(err, ErrInvalidBuckets) {
+			t.Errorf("expected ErrInvalidBuckets for %v: %v", bounds, err)
+		}
+	}
+}
+
+func TestWithHistogram(t *testing.T) {
+	h, _ := NewHistogram()
+	noop := func(ctx context.Context, i int) error { return nil }
+	for i := 0; i < 3; i++ {
+		if err := Do(context.Background(), 10, noop, WithHistogram(h)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if h.Count() != 30 {
+		t.Errorf("expected the histogram to accumulate across runs: %d", h.Count())
+	}
+}
@@ -0,0 +1,93 @@
+package spara
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks are callbacks for the events in a run's life, for plugging in
+// metrics, logging, and tracing without wrapping the mapping function. Any of
+// them can be nil. Item hooks are called from the worker goroutines, so they
+// must be safe to call concurrently, and they add directly to each item's
+// latency, so they should be quick.
+type Hooks struct {
+	// OnRunStart is called before any items are started, with the number
+	// of iterations.
+	OnRunStart func(ctx context.Context, total int)
+	// OnItemStart is called just before the mapping function, with the
+	// item's name from the Names option, or "" without one.
+	OnItemStart func(index int, name string)
+	// OnItemDone is called just after the mapping function returns, with
+	// the item's name, its error and how long it took. With the Retry
+	// option, that covers every attempt.
+	OnItemDone func(index int, name string, err error, elapsed time.Duration)
+	// OnRunEnd is called once the run is over, with the error it's about to
+	// return and its Stats. Providing it has the same memory cost as the
+	// WithStats option.
+	OnRunEnd func(err error, stats Stats)
+}
+
+// WithHooks registers a set of hooks with the run. It can be passed more
+// than once, eg once for metrics and once for logging, and the hooks are
+// called in the order they were given.
+func WithHooks(h Hooks) Option {
+	return func(c *config) {
+		o := &hooksObserver{hooks: h, c: c}
+		if h.OnRunEnd != nil {
+			o.stats = &statsObserver{stats: new(Stats), c: c}
+		}
+		c.addObserver(o)
+	}
+}
+
+type hooksObserver struct {
+	hooks Hooks
+	c     *config
+	stats *statsObserver
+}
+
+func (o *hooksObserver) runStart(ctx context.Context, total int, start time.Time) {
+	if o.stats != nil {
+		o.stats.runStart(ctx, total, start)
+	}
+	if o.hooks.OnRunStart != nil {
+		o.hooks.OnRunStart(ctx, total)
+	}
+}
+
+func (o *hooksObserver) itemStart(worker, index int) {
+	if o.hooks.OnItemStart != nil {
+		o.hooks.OnItemStart(index, o.c.itemName(index))
+	}
+}
+
+func (o *hooksObserver) workerStart(worker int) {
+	if o.stats != nil {
+		o.stats.workerStart(worker)
+	}
+}
+
+func (o *hooksObserver) workerEnd(worker int) {}
+
+func (o *hooksObserver) itemDone(worker, index int, elapsed time.Duration, err error) {
+	if o.stats != nil {
+		o.stats.itemDone(worker, index, elapsed, err)
+	}
+	if o.hooks.OnItemDone != nil {
+		o.hooks.OnItemDone(index, o.c.itemName(index), err, elapsed)
+	}
+}
+
+func (o *hooksObserver) runEnd(err error, elapsed time.Duration) {
+	if o.stats == nil {
+		return
+	}
+	o.stats.runEnd(err, elapsed)
+	o.hooks.OnRunEnd(err, *o.stats.stats)
+}
+
+func (o *hooksObserver) runCanceled(at time.Time) {
+	if o.stats != nil {
+		o.stats.runCanceled(at)
+	}
+}
@@ -0,0 +1,96 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithHooks(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+
+	expectedError := errors.New("boom")
+	var final Stats
+	var finalErr error
+	err := Do(context.Background(), 3, func(ctx context.Context, i int) error {
+		if i == 2 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), WithHooks(Hooks{
+		OnRunStart:  func(ctx context.Context, total int) { record("start") },
+		OnItemStart: func(index int, name string) { record("item") },
+		OnItemDone: func(index int, name string, err error, elapsed time.Duration) {
+			if err != nil {
+				record("failed")
+			} else {
+				record("done")
+			}
+		},
+		OnRunEnd: func(err error, stats Stats) {
+			record("end")
+			final, finalErr = stats, err
+		},
+	}), WithHooks(Hooks{
+		OnRunStart: func(ctx context.Context, total int) { record("second") },
+	}))
+	if err != expectedError {
+		t.Fatalf("expected the error: %v", err)
+	}
+
+	expected := []string{"start", "second", "item", "done", "item", "done", "item", "failed", "end"}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("unexpected events: %v", events)
+		}
+	}
+	if finalErr != expectedError || final.Items != 3 || final.Failed != 1 {
+		t.Errorf("unexpected OnRunEnd arguments: %v %+v", finalErr, final)
+	}
+}
+
+func TestWithHooksNames(t *testing.T) {
+	var mu sync.Mutex
+	started := make(map[int]string)
+	done := make(map[int]string)
+	Do(context.Background(), 3, func(ctx context.Context, i int) error { return nil },
+		Workers(2), Names(func(i int) string { return fmt.Sprintf("thumbnail:user-%d", i) }), WithHooks(Hooks{
+			OnItemStart: func(index int, name string) {
+				mu.Lock()
+				started[index] = name
+				mu.Unlock()
+			},
+			OnItemDone: func(index int, name string, err error, elapsed time.Duration) {
+				mu.Lock()
+				done[index] = name
+				mu.Unlock()
+			},
+		}))
+	for i := 0; i < 3; i++ {
+		expected := fmt.Sprintf("thumbnail:user-%d", i)
+		if started[i] != expected || done[i] != expected {
+			t.Errorf("unexpected names for item %d: %q %q", i, started[i], done[i])
+		}
+	}
+
+	// Without the Names option, items are nameless.
+	Do(context.Background(), 1, func(ctx context.Context, i int) error { return nil }, WithHooks(Hooks{
+		OnItemDone: func(index int, name string, err error, elapsed time.Duration) {
+			if name != "" {
+				t.Errorf("unexpected name: %q", name)
+			}
+		},
+	}))
+}
@@ -0,0 +1,108 @@
+// Package httpfan fetches a batch of HTTP requests concurrently, with the same
+// early cancellation as spara.RunWithContext. This is the example from the
+// spara docs made real: once one request fails, the requests still in
+// progress are canceled rather than left to finish downloading responses
+// nobody cares about anymore.
+package httpfan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/heyimalex/spara"
+)
+
+var ErrNilRequest = errors.New("httpfan: request must not be nil")
+
+// HandleFunc is called with the response for the request at index i. The
+// response body is closed after it returns, so it must finish reading the
+// body before returning. Returning an error fails the request, which with
+// the spara.Retry option means the request is sent again.
+type HandleFunc func(ctx context.Context, i int, resp *http.Response) error
+
+// FetchAll sends every request in reqs with http.DefaultClient, across up to
+// workers goroutines, and calls handle with each response. Each request is
+// sent with the context passed to handle, so the first failure cancels any
+// requests still in flight, and FetchAll returns that first error.
+//
+// Any spara options, like spara.Retry or spara.Names, apply on top. Requests
+// with a body can only be retried if they have a GetBody function, which
+// http.NewRequest sets up for the common body types.
+func FetchAll(ctx context.Context, workers int, reqs []*http.Request, handle HandleFunc, opts ...spara.Option) error {
+	return FetchAllClient(ctx, http.DefaultClient, workers, reqs, handle, opts...)
+}
+
+// FetchAllClient is like FetchAll, but sends the requests with client.
+func FetchAllClient(ctx context.Context, client *http.Client, workers int, reqs []*http.Request, handle HandleFunc, opts ...spara.Option) error {
+	if handle == nil {
+		return spara.ErrNilMappingFunction
+	}
+	for _, req := range reqs {
+		if req == nil {
+			return ErrNilRequest
+		}
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	opts = append([]spara.Option{spara.Workers(workers)}, opts...)
+	return spara.Do(ctx, len(reqs), func(ctx context.Context, i int) error {
+		req := reqs[i].WithContext(ctx)
+		// Every attempt needs a fresh body, since the last one consumed it.
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			req.Body = body
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// Draining what's left lets the connection be reused.
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+			resp.Body.Close()
+		}()
+		return handle(ctx, i, resp)
+	}, opts...)
+}
+
+// maxDrain is how much of an unread body is worth reading to keep the
+// connection alive; past that, it's cheaper to just close it.
+const maxDrain = 64 << 10
+
+// StatusError is returned by CheckStatus for unsuccessful responses.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpfan: unexpected status %s", e.Status)
+}
+
+// CheckStatus returns a *StatusError if resp doesn't have a 2xx status code.
+// It's meant to be called at the top of a HandleFunc.
+func CheckStatus(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+// Retryable reports whether err is worth retrying: anything other than a
+// *StatusError, or a StatusError for a 429 or 5xx response. It's meant to
+// be passed to spara.RetryIf.
+func Retryable(err error) bool {
+	var status *StatusError
+	if !errors.As(err, &status) {
+		return true
+	}
+	return status.StatusCode == http.StatusTooManyRequests || status.StatusCode >= 500
+}
@@ -0,0 +1,113 @@
+package httpfan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/heyimalex/spara"
+)
+
+func TestFetchAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	reqs := make([]*http.Request, 10)
+	for i := range reqs {
+		reqs[i], _ = http.NewRequest("GET", srv.URL+"/"+string(rune('a'+i)), nil)
+	}
+	bodies := make([]string, len(reqs))
+	err := FetchAll(context.Background(), 3, reqs, func(ctx context.Context, i int, resp *http.Response) error {
+		if err := CheckStatus(resp); err != nil {
+			return err
+		}
+		b, err := io.ReadAll(resp.Body)
+		bodies[i] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i, body := range bodies {
+		if body != "/"+string(rune('a'+i)) {
+			t.Errorf("unexpected body for request %d: %q", i, body)
+		}
+	}
+}
+
+func TestFetchAllCancelsInFlight(t *testing.T) {
+	var once sync.Once
+	blocked := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			<-blocked
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		once.Do(func() { close(blocked) })
+		// Hang until the client gives up on the request.
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	fail, _ := http.NewRequest("GET", srv.URL+"/fail", nil)
+	slow, _ := http.NewRequest("GET", srv.URL+"/slow", nil)
+	err := FetchAll(context.Background(), 2, []*http.Request{fail, slow}, func(ctx context.Context, i int, resp *http.Response) error {
+		return CheckStatus(resp)
+	})
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected a 500 StatusError: %v", err)
+	}
+}
+
+func TestFetchAllRetry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader("payload"))
+	handle := func(ctx context.Context, i int, resp *http.Response) error {
+		return CheckStatus(resp)
+	}
+	err := FetchAll(context.Background(), 1, []*http.Request{req}, handle, spara.Retry(3, nil), spara.RetryIf(Retryable))
+	if err != nil {
+		t.Errorf("expected the request to succeed on the third attempt: %v", err)
+	}
+
+	// Client errors aren't retried.
+	bad, _ := http.NewRequest("POST", srv.URL, nil)
+	atomic.StoreInt32(&calls, 0)
+	err = FetchAll(context.Background(), 1, []*http.Request{bad}, handle, spara.Retry(3, nil), spara.RetryIf(Retryable))
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a 400 StatusError: %v", err)
+	}
+}
+
+func TestFetchAllValidation(t *testing.T) {
+	handle := func(ctx context.Context, i int, resp *http.Response) error { return nil }
+	if err := FetchAll(context.Background(), 1, []*http.Request{nil}, handle); err != ErrNilRequest {
+		t.Errorf("expected ErrNilRequest: %v", err)
+	}
+	if err := FetchAll(context.Background(), 1, nil, nil); err != spara.ErrNilMappingFunction {
+		t.Errorf("expected ErrNilMappingFunction: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package spara
+
+import "sync/atomic"
+
+// cacheLineSize is a conservative guess at the size of a cache line. It's 64
+// bytes on most amd64 and arm64 hardware, but Apple's arm64 chips and some
+// others prefetch in pairs of lines, so padding to 128 is what actually keeps
+// neighbours out.
+const cacheLineSize = 128
+
+// indexCounter hands out the indices of a run. Every worker hits it after
+// every item, so when items are short it's the hottest word in the whole run,
+// and anything else that happens to share its cache line, like the kill state
+// or the first error, gets dragged back and forth between cores with it. The
+// padding on either side gives it a line of its own wherever it's allocated.
+type indexCounter struct {
+	_ [cacheLineSize]byte
+	n int32
+	_ [cacheLineSize - 4]byte
+}
+
+// newIndexCounter returns a counter whose next call returns start+1, since
+// workers are passed their first index directly.
+func newIndexCounter(start int) *indexCounter {
+	return &indexCounter{n: int32(start)}
+}
+
+func (c *indexCounter) next() int {
+	return int(atomic.AddInt32(&c.n, 1))
+}
+
+// add advances the counter by delta, returning the new value.
+func (c *indexCounter) add(delta int) int {
+	return int(atomic.AddInt32(&c.n, int32(delta)))
+}
+
+// stop makes every subsequent call to next return at least iterations.
+func (c *indexCounter) stop(iterations int) {
+	atomic.StoreInt32(&c.n, int32(iterations))
+}
@@ -0,0 +1,41 @@
+package spara
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestIndexCounterPadding(t *testing.T) {
+	var c indexCounter
+	if off := unsafe.Offsetof(c.n); off < cacheLineSize {
+		t.Errorf("expected a full cache line before the counter: %d", off)
+	}
+	if after := unsafe.Sizeof(c) - unsafe.Offsetof(c.n); after < cacheLineSize {
+		t.Errorf("expected a full cache line from the counter on: %d", after)
+	}
+}
+
+func TestIndexCounter(t *testing.T) {
+	c := newIndexCounter(3)
+	if i := c.next(); i != 4 {
+		t.Errorf("expected 4: %d", i)
+	}
+	c.stop(10)
+	if i := c.next(); i < 10 {
+		t.Errorf("expected at least 10 after stop: %d", i)
+	}
+}
+
+// Many workers on tiny items is the case where contention on the counter's
+// cache line dominates.
+func BenchmarkRunShortItems(b *testing.B) {
+	const workers = 32
+	sink := make([]int, 10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Run(workers, len(sink), func(j int) error {
+			sink[j]++
+			return nil
+		})
+	}
+}
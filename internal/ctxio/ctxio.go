@@ -0,0 +1,25 @@
+// Package ctxio has the io helpers shared by spara's subpackages.
+package ctxio
+
+import (
+	"context"
+	"io"
+)
+
+// NewReader returns a reader that stops a copy from r early once ctx is
+// done, so large files don't keep going after the run has been canceled.
+func NewReader(ctx context.Context, r io.Reader) io.Reader {
+	return reader{ctx, r}
+}
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r reader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,22 @@
+package ctxio
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNewReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewReader(ctx, strings.NewReader("hello"))
+	p := make([]byte, 2)
+	if n, err := r.Read(p); n != 2 || err != nil {
+		t.Fatalf("expected a read before cancellation: %d %v", n, err)
+	}
+	cancel()
+	if _, err := io.ReadAll(r); err != context.Canceled {
+		t.Errorf("expected the context's error: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package spara
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The stats and progress types marshal to JSON with stable snake_case field
+// names, and with durations as fractional milliseconds rather than
+// time.Duration's nanoseconds, which nobody reading a status endpoint wants
+// to divide by a million. They unmarshal from the same format, so they can be
+// stored and loaded back, eg as part of a job history record. Derived values
+// like throughput are included for convenience, and ignored on the way back
+// in.
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func fromMillis(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+type statsJSON struct {
+	Items        int           `json:"items"`
+	Failed       int           `json:"failed"`
+	Wall         float64       `json:"wall_ms"`
+	Busy         float64       `json:"busy_ms"`
+	Min          float64       `json:"min_ms"`
+	Median       float64       `json:"median_ms"`
+	P95          float64       `json:"p95_ms"`
+	Max          float64       `json:"max_ms"`
+	Throughput   float64       `json:"throughput"`
+	Utilization  float64       `json:"utilization"`
+	Workers      []WorkerStats `json:"workers,omitempty"`
+	Completions  []Completion  `json:"completions,omitempty"`
+	Cancellation *CancelStats  `json:"cancellation,omitempty"`
+}
+
+func (s Stats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statsJSON{
+		Items:        s.Items,
+		Failed:       s.Failed,
+		Wall:         millis(s.Wall),
+		Busy:         millis(s.Busy),
+		Min:          millis(s.Min),
+		Median:       millis(s.Median),
+		P95:          millis(s.P95),
+		Max:          millis(s.Max),
+		Throughput:   s.Throughput(),
+		Utilization:  s.Utilization(),
+		Workers:      s.Workers,
+		Completions:  s.Completions,
+		Cancellation: s.Cancellation,
+	})
+}
+
+func (s *Stats) UnmarshalJSON(data []byte) error {
+	var j statsJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*s = Stats{
+		Items:        j.Items,
+		Failed:       j.Failed,
+		Wall:         fromMillis(j.Wall),
+		Busy:         fromMillis(j.Busy),
+		Min:          fromMillis(j.Min),
+		Median:       fromMillis(j.Median),
+		P95:          fromMillis(j.P95),
+		Max:          fromMillis(j.Max),
+		Workers:      j.Workers,
+		Completions:  j.Completions,
+		Cancellation: j.Cancellation,
+	}
+	return nil
+}
+
+type workerStatsJSON struct {
+	Items int     `json:"items"`
+	Busy  float64 `json:"busy_ms"`
+	Idle  float64 `json:"idle_ms"`
+}
+
+func (w WorkerStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(workerStatsJSON{
+		Items: w.Items,
+		Busy:  millis(w.Busy),
+		Idle:  millis(w.Idle),
+	})
+}
+
+func (w *WorkerStats) UnmarshalJSON(data []byte) error {
+	var j workerStatsJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*w = WorkerStats{Items: j.Items, Busy: fromMillis(j.Busy), Idle: fromMillis(j.Idle)}
+	return nil
+}
+
+type completionJSON struct {
+	Index    int     `json:"index"`
+	Worker   int     `json:"worker"`
+	Started  float64 `json:"started_ms"`
+	Finished float64 `json:"finished_ms"`
+	Failed   bool    `json:"failed,omitempty"`
+}
+
+func (c Completion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(completionJSON{
+		Index:    c.Index,
+		Worker:   c.Worker,
+		Started:  millis(c.Started),
+		Finished: millis(c.Finished),
+		Failed:   c.Failed,
+	})
+}
+
+func (c *Completion) UnmarshalJSON(data []byte) error {
+	var j completionJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*c = Completion{
+		Index:    j.Index,
+		Worker:   j.Worker,
+		Started:  fromMillis(j.Started),
+		Finished: fromMillis(j.Finished),
+		Failed:   j.Failed,
+	}
+	return nil
+}
+
+type progressJSON struct {
+	Done    int      `json:"done"`
+	Total   int      `json:"total"`
+	Failed  int      `json:"failed"`
+	Rate    float64  `json:"rate"`
+	Elapsed float64  `json:"elapsed_ms"`
+	ETA     *float64 `json:"eta_ms,omitempty"`
+}
+
+func (p Progress) MarshalJSON() ([]byte, error) {
+	j := progressJSON{
+		Done:    p.Done,
+		Total:   p.Total,
+		Failed:  p.Failed,
+		Rate:    p.Rate,
+		Elapsed: millis(p.Elapsed),
+	}
+	if eta, ok := p.ETA(); ok {
+		ms := millis(eta)
+		j.ETA = &ms
+	}
+	return json.Marshal(j)
+}
+
+func (p *Progress) UnmarshalJSON(data []byte) error {
+	var j progressJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*p = Progress{
+		Done:    j.Done,
+		Total:   j.Total,
+		Failed:  j.Failed,
+		Rate:    j.Rate,
+		Elapsed: fromMillis(j.Elapsed),
+	}
+	return nil
+}
+
+type cancelStatsJSON struct {
+	Items   int     `json:"items"`
+	Latency float64 `json:"latency_ms"`
+	Slowest int     `json:"slowest"`
+}
+
+func (c CancelStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cancelStatsJSON{
+		Items:   c.Items,
+		Latency: millis(c.Latency),
+		Slowest: c.Slowest,
+	})
+}
+
+func (c *CancelStats) UnmarshalJSON(data []byte) error {
+	var j cancelStatsJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*c = CancelStats{Items: j.Items, Latency: fromMillis(j.Latency), Slowest: j.Slowest}
+	return nil
+}
@@ -0,0 +1,62 @@
+package spara
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsJSON(t *testing.T) {
+	stats := Stats{
+		Items:  2,
+		Failed: 1,
+		Wall:   1500 * time.Microsecond,
+		Busy:   2 * time.Millisecond,
+		Min:    500 * time.Microsecond,
+		Median: time.Millisecond,
+		P95:    1500 * time.Microsecond,
+		Max:    1500 * time.Microsecond,
+		Workers: []WorkerStats{
+			{Items: 2, Busy: 2 * time.Millisecond},
+		},
+		Completions: []Completion{
+			{Index: 0, Started: 0, Finished: 500 * time.Microsecond},
+			{Index: 1, Started: 500 * time.Microsecond, Finished: 1500 * time.Microsecond, Failed: true},
+		},
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, expected := range []string{`"items":2`, `"wall_ms":1.5`, `"median_ms":1`, `"busy_ms":2`, `"finished_ms":1.5,"failed":true`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected %s in %s", expected, data)
+		}
+	}
+
+	var decoded Stats
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, stats) {
+		t.Errorf("round trip changed stats:\n%+v\n%+v", stats, decoded)
+	}
+}
+
+func TestProgressJSON(t *testing.T) {
+	p := Progress{Done: 5, Total: 15, Rate: 2, Elapsed: 2500 * time.Millisecond}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := `{"done":5,"total":15,"failed":0,"rate":2,"elapsed_ms":2500,"eta_ms":5000}`
+	if string(data) != expected {
+		t.Errorf("unexpected JSON:\n%s\n%s", data, expected)
+	}
+	var decoded Progress
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != p {
+		t.Errorf("round trip changed progress: %+v %v", decoded, err)
+	}
+}
@@ -0,0 +1,73 @@
+package spara
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInvalidLimit = errors.New("spara: invalid limiter size")
+
+// Limiter admits items into a run. Every item acquires one unit from it
+// before the mapping function is called, and releases it once the function
+// returns, so sharing a Limiter between runs, or between runs and code that
+// has nothing to do with spara, caps how many things are in flight across all
+// of them put together, rather than per run.
+//
+// The method set is the same as golang.org/x/sync/semaphore's Weighted, so
+// a *semaphore.Weighted can be passed to WithLimiter as is, and shared with
+// code that already uses one.
+type Limiter interface {
+	// Acquire takes n units, blocking until they're available or ctx is
+	// done, in which case it returns ctx.Err() and takes nothing.
+	Acquire(ctx context.Context, n int64) error
+	// Release gives back n units taken by Acquire.
+	Release(n int64)
+}
+
+// NewLimiter returns a Limiter with n units. Waiters are admitted in the
+// order they asked, the same as with MemoryBudget.
+func NewLimiter(n int64) (Limiter, error) {
+	if n <= 0 {
+		return nil, invalid(ErrInvalidLimit, "n", n)
+	}
+	return limiter{newWeighted(n)}, nil
+}
+
+type limiter struct {
+	w *weighted
+}
+
+func (l limiter) Acquire(ctx context.Context, n int64) error {
+	return l.w.acquire(ctx, n)
+}
+
+func (l limiter) Release(n int64) {
+	l.w.release(n)
+}
+
+// WithLimiter makes every item wait for a unit of l before it runs, on top of
+// the limit on the number of workers. The unit is held for as long as the
+// item runs, including any retries. Passing nil removes the limiter.
+func WithLimiter(l Limiter) Option {
+	return func(c *config) {
+		c.limiter = l
+	}
+}
+
+// withLimiter returns middleware that makes every call wait for a unit of the
+// limiter, or nil if there isn't one.
+func (c *config) withLimiter() Middleware {
+	if c == nil || c.limiter == nil {
+		return nil
+	}
+	l := c.limiter
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			if err := l.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer l.Release(1)
+			return fn(ctx, index)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l, err := NewLimiter(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two runs sharing the limiter stay under it together.
+	var inFlight, peak int32
+	fn := func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	}
+	var wg sync.WaitGroup
+	for r := 0; r < 2; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Do(context.Background(), 20, fn, Workers(4), WithLimiter(l)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak > 3 {
+		t.Errorf("expected at most 3 items in flight: %d", peak)
+	}
+
+	// A run that can't get a unit gives up when its context is done.
+	if err := l.Acquire(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = Do(ctx, 5, func(ctx context.Context, i int) error {
+		t.Errorf("unexpected call for %d", i)
+		return nil
+	}, Workers(2), WithLimiter(l))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline: %v", err)
+	}
+	l.Release(3)
+
+	if _, err := NewLimiter(0); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("expected ErrInvalidLimit: %v", err)
+	}
+}
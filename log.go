@@ -0,0 +1,84 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// WithLogger logs what happens during the run to logger, with structured
+// fields rather than messages the caller would have to parse:
+//
+//   - the run starting and finishing, at debug level
+//   - retries, at info level
+//   - items failing, at warn level
+//   - the run failing, at error level, or being canceled by its parent
+//     context, at info level along with the cancellation's cause
+//
+// Which of those actually get written is up to the logger's handler, so the
+// usual setup is to pass the service's logger and let its level decide.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		if logger != nil {
+			c.addObserver(&logObserver{baseLogger: logger, c: c})
+		}
+	}
+}
+
+type logObserver struct {
+	baseLogger *slog.Logger
+	logger     *slog.Logger
+	c          *config
+	parent     context.Context
+}
+
+// attrs returns the fields identifying an item.
+func (o *logObserver) attrs(index int) []any {
+	attrs := []any{slog.Int("index", index)}
+	if o.c.name != nil {
+		if name := o.c.name(index); name != "" {
+			attrs = append(attrs, slog.String("name", name))
+		}
+	}
+	return attrs
+}
+
+func (o *logObserver) runStart(ctx context.Context, total int, start time.Time) {
+	o.parent = ctx
+	o.logger = o.baseLogger
+	if o.c.runName != "" {
+		o.logger = o.logger.With(slog.String("run", o.c.runName))
+	}
+	o.logger.LogAttrs(ctx, slog.LevelDebug, "spara: run started", slog.Int("iterations", total))
+}
+
+func (o *logObserver) itemDone(worker, index int, elapsed time.Duration, err error) {
+	if err == nil || errors.Is(err, context.Canceled) || o.parent.Err() != nil && errors.Is(err, o.parent.Err()) {
+		// Items returning the context's error after the run is canceled
+		// are just following orders; whatever caused it is logged
+		// separately.
+		return
+	}
+	attrs := append(o.attrs(index), slog.Duration("elapsed", elapsed), slog.Any("error", err))
+	o.logger.Log(o.parent, slog.LevelWarn, "spara: item failed", attrs...)
+}
+
+func (o *logObserver) itemRetry(index int, attempt int, err error, wait time.Duration) {
+	attrs := append(o.attrs(index), slog.Int("attempt", attempt), slog.Duration("wait", wait), slog.Any("error", err))
+	o.logger.Log(o.parent, slog.LevelInfo, "spara: retrying item", attrs...)
+}
+
+func (o *logObserver) runEnd(err error, elapsed time.Duration) {
+	ctx := o.parent
+	switch {
+	case err == nil:
+		o.logger.LogAttrs(ctx, slog.LevelDebug, "spara: run finished", slog.Duration("elapsed", elapsed))
+	case ctx.Err() != nil && err == ctx.Err():
+		o.logger.LogAttrs(ctx, slog.LevelInfo, "spara: run canceled",
+			slog.Duration("elapsed", elapsed), slog.Any("error", err), slog.Any("cause", context.Cause(ctx)))
+	default:
+		o.logger.LogAttrs(ctx, slog.LevelError, "spara: run failed",
+			slog.Duration("elapsed", elapsed), slog.Any("error", err))
+	}
+}
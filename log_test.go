@@ -0,0 +1,63 @@
+package spara
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var attempts int
+	err := Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		if i == 2 {
+			attempts++
+			return errors.New("boom")
+		}
+		return nil
+	}, Workers(1), Retry(2, nil), Names(func(i int) string { return fmt.Sprintf("item-%d", i) }), WithLogger(logger))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	out := buf.String()
+	for _, expected := range []string{
+		`msg="spara: run started" iterations=5`,
+		`msg="spara: retrying item" index=2 name=item-2 attempt=1`,
+		`msg="spara: item failed" index=2 name=item-2`,
+		`msg="spara: run failed"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected log to contain %q:\n%s", expected, out)
+		}
+	}
+}
+
+func TestWithLoggerCancel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	cause := errors.New("shutting down")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	err := Do(ctx, 5, func(ctx context.Context, i int) error {
+		cancel(cause)
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithLogger(logger))
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `msg="spara: run canceled"`) || !strings.Contains(out, `cause="shutting down"`) {
+		t.Errorf("expected the cancellation and its cause to be logged:\n%s", out)
+	}
+	if strings.Contains(out, "run started") || strings.Contains(out, "item failed") {
+		t.Errorf("expected only the cancellation to be logged:\n%s", out)
+	}
+}
@@ -0,0 +1,101 @@
+package spara
+
+import (
+	"context"
+	"unsafe"
+)
+
+// Map calls fn with every item of items across up to workers goroutines, and
+// returns the results in the same order as the items. Error handling is the
+// same as RunWithContext; on error, the partial results are discarded and
+// Map returns nil.
+//
+// The obvious way to write this, with each call storing straight into
+// out[index], performs badly for small result types: neighbouring indices
+// are handled by different workers at about the same time, so every store
+// lands on a cache line that some other core has just written to, and the
+// line bounces back and forth between them. Instead, workers claim blocks of
+// indices spanning several cache lines, collect a block's results in a
+// staging buffer of their own, and copy the whole block into place once it's
+// done, so each line of the output is only ever written by one worker.
+func Map[T, R any](parent context.Context, workers int, items []T, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	if workers <= 0 {
+		return nil, invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	if fn == nil {
+		return nil, ErrNilMappingFunction
+	}
+	if parent == nil {
+		return nil, ErrNilContext
+	}
+	out := make([]R, len(items))
+	if len(items) == 0 {
+		return out, nil
+	}
+
+	block := mapBlockSize[R]()
+	blocks := (len(items) + block - 1) / block
+	if workers > blocks {
+		workers = blocks
+	}
+	index := newIndexCounter(-1)
+	err := runLoops(parent, workers, workers, func(ctx context.Context, _ int) error {
+		stage := make([]R, block)
+		done := ctx.Done()
+		w := workerFrom(ctx)
+		for b := index.next(); b < blocks; b = index.next() {
+			lo := b * block
+			hi := min(lo+block, len(items))
+			for i := lo; i < hi; i++ {
+				if isDone(done) {
+					return ctx.Err()
+				}
+				w.begin(i)
+				result, err := fn(ctx, items[i])
+				if err != nil {
+					return err
+				}
+				stage[i-lo] = result
+			}
+			copy(out[lo:hi], stage[:hi-lo])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RunSlice calls fn with every index of items, along with the item itself,
+// across up to workers goroutines. It's RunWithContext with the number of
+// iterations taken from the slice, so there's no separate len(items) to keep
+// in sync with the slice the mapping function closes over; passing one slice
+// and closing over another, say after an append has reallocated it, is an
+// easy mistake to make and a hard one to spot.
+//
+// The slice is read as it was when RunSlice was called. The items are passed
+// by value, so fn should go through items[index] if it wants to modify them
+// in place.
+func RunSlice[T any](parent context.Context, workers int, items []T, fn func(ctx context.Context, index int, item T) error) error {
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	return RunWithContext(parent, workers, len(items), func(ctx context.Context, index int) error {
+		return fn(ctx, index, items[index])
+	})
+}
+
+// mapBlockSize returns how many results of type R make up a block for Map:
+// enough to cover a few cache lines, so that the lines shared with the
+// neighbouring blocks are a small part of the whole, but not so many that a
+// small input ends up on a single worker.
+func mapBlockSize[R any]() int {
+	const lines = 4
+	var r R
+	size := int(unsafe.Sizeof(r))
+	if size == 0 {
+		return 64
+	}
+	return min(max(lines*cacheLineSize/size, 1), 64)
+}
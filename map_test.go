@@ -0,0 +1,123 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 100, 1000} {
+		items := make([]int, n)
+		for i := range items {
+			items[i] = i
+		}
+		out, err := Map(context.Background(), 4, items, func(ctx context.Context, item int) (string, error) {
+			// Each worker loops over many items, but the context still
+			// describes the one being mapped.
+			if index, _ := ItemIndex(ctx); index != item || Attempt(ctx) != 1 {
+				t.Errorf("unexpected item info for %d: index %d, attempt %d", item, index, Attempt(ctx))
+			}
+			return strconv.Itoa(item * 2), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != n {
+			t.Fatalf("expected %d results: %d", n, len(out))
+		}
+		for i, s := range out {
+			if s != strconv.Itoa(i*2) {
+				t.Fatalf("unexpected result for %d: %q", i, s)
+			}
+		}
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	expectedError := errors.New("boom")
+	items := make([]int, 1000)
+	out, err := Map(context.Background(), 4, items, func(ctx context.Context, item int) (int, error) {
+		return 0, expectedError
+	})
+	if err != expectedError || out != nil {
+		t.Errorf("expected the error and no results: %v, %v", err, out)
+	}
+	if _, err := Map[int, int](context.Background(), 0, items, nil); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected ErrInvalidWorkers: %v", err)
+	}
+}
+
+func TestMapBlockSize(t *testing.T) {
+	if n := mapBlockSize[int32](); n != 64 {
+		t.Errorf("expected small results to use the largest block: %d", n)
+	}
+	if n := mapBlockSize[[4096]byte](); n != 1 {
+		t.Errorf("expected huge results to use single item blocks: %d", n)
+	}
+	if n := mapBlockSize[struct{}](); n != 64 {
+		t.Errorf("unexpected block size for empty results: %d", n)
+	}
+}
+
+// Compares Map against writing straight into the output from every worker,
+// for a result type small enough that many share a cache line.
+func BenchmarkMap(b *testing.B) {
+	items := make([]int32, 1<<16)
+	fn := func(ctx context.Context, item int32) (int32, error) { return item + 1, nil }
+	b.Run("Direct", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			out := make([]int32, len(items))
+			RunWithContext(context.Background(), 8, len(items), func(ctx context.Context, j int) error {
+				r, err := fn(ctx, items[j])
+				out[j] = r
+				return err
+			})
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Map(context.Background(), 8, items, fn)
+		}
+	})
+}
+
+func TestRunSlice(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	sums := make([]int, len(items))
+	err := RunSlice(context.Background(), 2, items, func(ctx context.Context, index int, item int) error {
+		sums[index] = item * 2
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range sums {
+		if s != items[i]*2 {
+			t.Fatalf("unexpected result for %d: %d", i, s)
+		}
+	}
+
+	expectedError := errors.New("boom")
+	err = RunSlice(context.Background(), 2, items, func(ctx context.Context, index int, item int) error {
+		if item == 3 {
+			return expectedError
+		}
+		return nil
+	})
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+
+	if err := RunSlice[int](context.Background(), 2, nil, nil); err != ErrNilMappingFunction {
+		t.Errorf("expected ErrNilMappingFunction: %v", err)
+	}
+	if err := RunSlice(context.Background(), 0, items, func(context.Context, int, int) error { return nil }); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected ErrInvalidWorkers: %v", err)
+	}
+	var calls int
+	if err := RunSlice(context.Background(), 2, []string(nil), func(context.Context, int, string) error { calls++; return nil }); err != nil || calls != 0 {
+		t.Errorf("expected an empty slice to do nothing: %v, %d calls", err, calls)
+	}
+}
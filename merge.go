@@ -0,0 +1,46 @@
+package spara
+
+import (
+	"context"
+)
+
+// MergeChan consumes every channel in chans concurrently, calling fn for each
+// item received, with at most workers calls to fn in flight across all of
+// them. It returns once every channel has been closed and drained, or early
+// on the first error or the parent context completing, with the same
+// semantics as RunWithContext.
+//
+// Items are taken from whichever channel has one ready, so one busy channel
+// can't starve the others of workers, but no other fairness is guaranteed.
+func MergeChan[T any](parent context.Context, workers int, fn func(ctx context.Context, item T) error, chans ...<-chan T) error {
+	if workers <= 0 {
+		return invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	if parent == nil {
+		return ErrNilContext
+	}
+	if len(chans) == 0 {
+		return nil
+	}
+
+	// The merged channel is only drained while the workers are running, so
+	// cancel it on the way out to release the forwarding goroutines.
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	merged, drained := fanIn(ctx, chans...)
+	err := consumeChan(ctx, workers, merged, func(ctx context.Context, _ int, item T) error {
+		return fn(ctx, item)
+	})
+	if err != nil {
+		return err
+	}
+	// The merged channel also closes when the parent completes, which the
+	// workers can't tell apart from running out of input.
+	if !drained() {
+		return parent.Err()
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMergeChan(t *testing.T) {
+	var sum, inFlight, maxInFlight int32
+	err := MergeChan(context.Background(), 2, func(ctx context.Context, x int) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&sum, int32(x))
+		return nil
+	}, sendAll(1, 2, 3), sendAll(4, 5, 6), sendAll(7, 8, 9, 10))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sum != 55 {
+		t.Errorf("sum: %d != 55", sum)
+	}
+	if maxInFlight > 2 {
+		t.Errorf("more calls in flight than workers: %d", maxInFlight)
+	}
+}
+
+func TestMergeChanError(t *testing.T) {
+	expectedError := errors.New("")
+	err := MergeChan(context.Background(), 2, func(ctx context.Context, x int) error {
+		if x == 5 {
+			return expectedError
+		}
+		return nil
+	}, sendAll(1, 2, 3), sendAll(4, 5, 6))
+	if err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
+
+func TestMergeChanParentCanceled(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	err := MergeChan(parent, 2, func(ctx context.Context, x int) error {
+		return nil
+	}, sendAll(1, 2, 3), make(chan int))
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected err returned: %#v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package spara
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Metric names reported to a MetricsRecorder. Durations are reported in
+// seconds, as is conventional for most metrics systems.
+const (
+	MetricRuns          = "spara.runs"
+	MetricRunsFailed    = "spara.runs.failed"
+	MetricRunDuration   = "spara.run.duration"
+	MetricItems         = "spara.items"
+	MetricItemsFailed   = "spara.items.failed"
+	MetricItemsRetried  = "spara.items.retried"
+	MetricItemsInFlight = "spara.items.in_flight"
+	MetricItemDuration  = "spara.item.duration"
+)
+
+// Label is a metric label, or tag, depending on the metrics system.
+type Label struct {
+	Key   string
+	Value string
+}
+
+// MetricsRecorder is the bridge between spara and a metrics system, like
+// statsd, Prometheus, or OpenTelemetry metrics. It only has to translate
+// three kinds of measurement, and spara takes care of deciding what to
+// measure. Implementations are called from the worker goroutines, so they
+// must be safe for concurrent use, and should be quick, since item
+// measurements add to each item's latency.
+type MetricsRecorder interface {
+	// Counter adds delta to a monotonically increasing counter.
+	Counter(name string, delta int64, labels []Label)
+	// Gauge sets the current value of a gauge.
+	Gauge(name string, value float64, labels []Label)
+	// Histogram records a single observation in a distribution.
+	Histogram(name string, value float64, labels []Label)
+}
+
+// NopRecorder is a MetricsRecorder that discards everything. It's what runs
+// without the WithMetrics option effectively use, and is handy for turning
+// metrics off without changing call sites.
+type NopRecorder struct{}
+
+func (NopRecorder) Counter(string, int64, []Label)     {}
+func (NopRecorder) Gauge(string, float64, []Label)     {}
+func (NopRecorder) Histogram(string, float64, []Label) {}
+
+// WithMetrics reports the run's metrics to r. See the Metric constants for
+// what is recorded. The in-flight gauge is tracked per run, so runs sharing
+// a recorder should be told apart by label.
+func WithMetrics(r MetricsRecorder) Option {
+	return func(c *config) {
+		if r != nil {
+			c.addObserver(&metricsObserver{r: r, c: c})
+		}
+	}
+}
+
+type metricsObserver struct {
+	r        MetricsRecorder
+	c        *config
+	labels   []Label
+	inFlight int64
+}
+
+func (o *metricsObserver) runStart(ctx context.Context, total int, start time.Time) {
+	o.labels = o.c.metricLabels()
+	atomic.StoreInt64(&o.inFlight, 0)
+	o.r.Counter(MetricRuns, 1, o.labels)
+}
+
+func (o *metricsObserver) itemStart(worker, index int) {
+	o.r.Gauge(MetricItemsInFlight, float64(atomic.AddInt64(&o.inFlight, 1)), o.labels)
+}
+
+func (o *metricsObserver) itemDone(worker, index int, elapsed time.Duration, err error) {
+	o.r.Gauge(MetricItemsInFlight, float64(atomic.AddInt64(&o.inFlight, -1)), o.labels)
+	o.r.Counter(MetricItems, 1, o.labels)
+	if err != nil {
+		o.r.Counter(MetricItemsFailed, 1, o.labels)
+	}
+	o.r.Histogram(MetricItemDuration, elapsed.Seconds(), o.labels)
+}
+
+func (o *metricsObserver) itemRetry(index int, attempt int, err error, wait time.Duration) {
+	o.r.Counter(MetricItemsRetried, 1, o.labels)
+}
+
+func (o *metricsObserver) runEnd(err error, elapsed time.Duration) {
+	if err != nil {
+		o.r.Counter(MetricRunsFailed, 1, o.labels)
+	}
+	o.r.Histogram(MetricRunDuration, elapsed.Seconds(), o.labels)
+}
+
+// metricLabels returns the labels every metric for the run carries, which is
+// just the run's name, if it has one.
+func (c *config) metricLabels() []Label {
+	if c.runName == "" {
+		return nil
+	}
+	return []Label{{Key: "run", Value: c.runName}}
+}
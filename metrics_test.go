@@ -0,0 +1,75 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type recorder struct {
+	mu         sync.Mutex
+	counters   map[string]int64
+	gauges     map[string]float64
+	histograms map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		counters:   make(map[string]int64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]int),
+	}
+}
+
+func (r *recorder) Counter(name string, delta int64, labels []Label) {
+	r.mu.Lock()
+	r.counters[name] += delta
+	r.mu.Unlock()
+}
+
+func (r *recorder) Gauge(name string, value float64, labels []Label) {
+	r.mu.Lock()
+	r.gauges[name] = value
+	r.mu.Unlock()
+}
+
+func (r *recorder) Histogram(name string, value float64, labels []Label) {
+	r.mu.Lock()
+	r.histograms[name]++
+	r.mu.Unlock()
+}
+
+func TestWithMetrics(t *testing.T) {
+	r := newRecorder()
+	var attempts int
+	err := Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		if i == 5 {
+			attempts++
+			return errors.New("boom")
+		}
+		return nil
+	}, Workers(1), Retry(2, nil), WithMetrics(r))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	expected := map[string]int64{
+		MetricRuns:         1,
+		MetricRunsFailed:   1,
+		MetricItems:        6,
+		MetricItemsFailed:  1,
+		MetricItemsRetried: 1,
+	}
+	for name, value := range expected {
+		if r.counters[name] != value {
+			t.Errorf("expected %s to be %d: %d", name, value, r.counters[name])
+		}
+	}
+	if r.histograms[MetricItemDuration] != 6 || r.histograms[MetricRunDuration] != 1 {
+		t.Errorf("unexpected histogram observations: %v", r.histograms)
+	}
+	if r.gauges[MetricItemsInFlight] != 0 {
+		t.Errorf("expected nothing in flight after the run: %v", r.gauges)
+	}
+}
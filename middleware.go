@@ -0,0 +1,46 @@
+package spara
+
+// Middleware wraps a mapping function with behavior of its own, like
+// tracing, logging, or recovering from panics, and returns the wrapped
+// function. It's how the package's own per-item options, like Retry,
+// ItemTimeout, and the logging and metrics observers, are put together, and
+// Use lets callers add their own in the same way.
+//
+// Middleware is applied once per run, not once per item, so it can set up
+// anything that the run's calls share before returning the wrapped function.
+type Middleware func(next MappingFunc) MappingFunc
+
+// Use adds middleware around the mapping function. The first middleware
+// given is the outermost, and Use can be passed more than once, with each
+// adding to the inside of the chain built so far.
+//
+// The middleware sits directly around the mapping function, inside the
+// package's own: it's called once per attempt with Retry, inside the
+// deadline set by ItemTimeout, and only once any rate limit, Limiter, or
+// MemoryBudget has admitted the item.
+func Use(mw ...Middleware) Option {
+	return func(c *config) {
+		c.middleware = append(c.middleware, mw...)
+	}
+}
+
+// Chain combines middleware into one, with the first being the outermost.
+// Nil middleware is skipped.
+func Chain(mw ...Middleware) Middleware {
+	return func(fn MappingFunc) MappingFunc {
+		for i := len(mw) - 1; i >= 0; i-- {
+			if mw[i] != nil {
+				fn = mw[i](fn)
+			}
+		}
+		return fn
+	}
+}
+
+// userMiddleware returns the middleware passed to Use, or nil.
+func (c *config) userMiddleware() Middleware {
+	if c == nil || len(c.middleware) == 0 {
+		return nil
+	}
+	return Chain(c.middleware...)
+}
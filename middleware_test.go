@@ -0,0 +1,59 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUse(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		trace []string
+	)
+	record := func(name string) Middleware {
+		return func(next MappingFunc) MappingFunc {
+			return func(ctx context.Context, index int) error {
+				mu.Lock()
+				trace = append(trace, name+" in")
+				mu.Unlock()
+				err := next(ctx, index)
+				mu.Lock()
+				trace = append(trace, name+" out")
+				mu.Unlock()
+				return err
+			}
+		}
+	}
+	err := Do(context.Background(), 1, func(ctx context.Context, i int) error {
+		trace = append(trace, "fn")
+		return nil
+	}, Use(record("a"), nil, record("b")), Use(record("c")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a in", "b in", "c in", "fn", "c out", "b out", "a out"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("unexpected order: %v", trace)
+	}
+
+	// Middleware runs per attempt, inside the item's deadline.
+	var attempts int
+	err = Do(context.Background(), 1, func(ctx context.Context, i int) error {
+		return errors.New("flaky")
+	}, Workers(1), Retry(3, nil), ItemTimeout(time.Second), Use(func(next MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			attempts++
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("expected the item's deadline")
+			}
+			return next(ctx, index)
+		}
+	}))
+	if err == nil || attempts != 3 {
+		t.Errorf("expected three attempts through the middleware: %v, %d", err, attempts)
+	}
+}
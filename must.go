@@ -0,0 +1,54 @@
+package spara
+
+import "context"
+
+// The Must variants panic on configuration errors rather than returning
+// them, for call sites where the worker count and the like are constants and
+// the only way for them to be wrong is a bug. Errors from the mapping
+// function are still returned as usual; it's only the arguments that can't
+// be wrong at runtime.
+
+// MustRun is like Run, but panics if the arguments are invalid.
+func MustRun(workers int, iterations int, fn func(index int) error) error {
+	mustCheck(workers, iterations, fn == nil)
+	if c := defaultConfig(); c != nil {
+		return runDefault(c, workers, iterations, fn)
+	}
+	return runPlain(getDefaultPool(), workers, iterations, fn)
+}
+
+// MustRunWithContext is like RunWithContext, but panics if the arguments are
+// invalid.
+func MustRunWithContext(parent context.Context, workers int, iterations int, fn MappingFunc) error {
+	mustCheck(workers, iterations, fn == nil)
+	if parent == nil {
+		panic(ErrNilContext)
+	}
+	return run(parent, workers, iterations, fn, defaultConfig())
+}
+
+// MustNew is like New, but panics if the options are invalid. It's meant for
+// package level Runners built from fixed options:
+//
+//	var thumbnails = spara.MustNew(spara.Workers(8), spara.Retry(3, backoff))
+func MustNew(opts ...Option) *Runner {
+	r, err := New(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// mustCheck panics with the error Run would return for the given arguments,
+// if any.
+func mustCheck(workers int, iterations int, nilFn bool) {
+	if workers <= 0 {
+		panic(invalid(ErrInvalidWorkers, "workers", workers))
+	}
+	if iterations < 0 {
+		panic(invalid(ErrInvalidIterations, "iterations", iterations))
+	}
+	if nilFn {
+		panic(ErrNilMappingFunction)
+	}
+}
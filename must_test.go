@@ -0,0 +1,45 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func expectPanic(t *testing.T, expected error, f func()) {
+	t.Helper()
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, expected) {
+			t.Errorf("expected a panic with %v: %v", expected, err)
+		}
+	}()
+	f()
+}
+
+func TestMust(t *testing.T) {
+	expectedError := errors.New("boom")
+	noop := func(int) error { return nil }
+	ctxNoop := func(context.Context, int) error { return nil }
+
+	// Errors from the mapping function are returned, not panicked.
+	if err := MustRun(2, 10, func(int) error { return expectedError }); err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if err := MustRunWithContext(context.Background(), 2, 10, func(context.Context, int) error { return expectedError }); err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if err := MustRun(2, 10, noop); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expectPanic(t, ErrInvalidWorkers, func() { MustRun(0, 10, noop) })
+	expectPanic(t, ErrInvalidIterations, func() { MustRun(1, -1, noop) })
+	expectPanic(t, ErrNilMappingFunction, func() { MustRun(1, 10, nil) })
+	expectPanic(t, ErrInvalidWorkers, func() { MustRunWithContext(context.Background(), 0, 10, ctxNoop) })
+	expectPanic(t, ErrNilContext, func() { MustRunWithContext(nil, 1, 10, ctxNoop) })
+
+	if r := MustNew(Workers(2)); r == nil {
+		t.Errorf("expected a runner")
+	}
+	expectPanic(t, ErrInvalidWorkers, func() { MustNew(Workers(-1)) })
+}
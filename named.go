@@ -0,0 +1,36 @@
+package spara
+
+import (
+	"context"
+)
+
+// Named gives the run a name, which shows up everywhere the run is
+// reported: errors are wrapped in an *ItemError carrying it, and it's added
+// to log records from WithLogger, labels from WithMetrics and ProfileLabels,
+// and debug log lines. Once a service has more than a couple of places
+// starting runs, it's the only way to tell them apart.
+//
+// The name is also available to the mapping function, and anything it calls,
+// through RunName.
+func Named(name string) Option {
+	return func(c *config) {
+		c.runName = name
+	}
+}
+
+type runNameKey struct{}
+
+// RunName returns the name of the run ctx belongs to, as given by the Named
+// option, or the empty string.
+func RunName(ctx context.Context) string {
+	name, _ := ctx.Value(runNameKey{}).(string)
+	return name
+}
+
+// withRunName attaches the run's name to ctx, if it has one.
+func (c *config) withRunName(ctx context.Context) context.Context {
+	if c == nil || c.runName == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, runNameKey{}, c.runName)
+}
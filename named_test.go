@@ -0,0 +1,51 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	expectedError := errors.New("boom")
+	var labels []Label
+	err := Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		if name := RunName(ctx); name != "thumbnails" {
+			t.Errorf("unexpected run name in item context: %q", name)
+		}
+		if run, _ := pprof.Label(ctx, "spara.run"); run != "thumbnails" {
+			t.Errorf("unexpected run profile label: %q", run)
+		}
+		if i == 3 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), Named("thumbnails"), ProfileLabels(), WithMetrics(metricsFunc(func(l []Label) { labels = l })))
+
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) || itemErr.Run != "thumbnails" || !errors.Is(err, expectedError) {
+		t.Fatalf("expected an ItemError for the run: %v", err)
+	}
+	if err.Error() != "spara: thumbnails: index 3: boom" {
+		t.Errorf("unexpected error message: %q", err)
+	}
+	if len(labels) != 1 || labels[0] != (Label{Key: "run", Value: "thumbnails"}) {
+		t.Errorf("unexpected metric labels: %v", labels)
+	}
+
+	err = Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		return expectedError
+	}, Workers(1), Named("thumbnails"), Names(func(i int) string { return fmt.Sprintf("user-%d", i) }))
+	if err.Error() != "spara: thumbnails: user-0: boom" {
+		t.Errorf("unexpected error message: %q", err)
+	}
+}
+
+// metricsFunc is a MetricsRecorder that only reports the labels it's given.
+type metricsFunc func(labels []Label)
+
+func (f metricsFunc) Counter(name string, delta int64, labels []Label)     { f(labels) }
+func (f metricsFunc) Gauge(name string, value float64, labels []Label)     { f(labels) }
+func (f metricsFunc) Histogram(name string, value float64, labels []Label) { f(labels) }
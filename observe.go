@@ -0,0 +1,155 @@
+package spara
+
+import (
+	"context"
+	"time"
+)
+
+// observer is implemented by the options that watch a run without changing
+// how it executes, like WithStats. Runs without observers skip all of this,
+// including the calls to time.Now.
+type observer interface {
+	runStart(ctx context.Context, total int, start time.Time)
+	itemDone(worker, index int, elapsed time.Duration, err error)
+	runEnd(err error, elapsed time.Duration)
+}
+
+// startObserver is implemented by observers that also want to hear about
+// items as they start, which costs an extra call per item.
+type startObserver interface {
+	itemStart(worker, index int)
+}
+
+// workerObserver is implemented by observers that want to hear about worker
+// goroutines starting and stopping. The calls are made on the worker
+// goroutine itself.
+type workerObserver interface {
+	workerStart(worker int)
+	workerEnd(worker int)
+}
+
+// clockObserver is implemented by observers that tell time on their own, not
+// just with the times they're passed, so that they can use the run's Clock.
+// It's called before runStart.
+type clockObserver interface {
+	useClock(clock Clock)
+}
+
+// cancelObserver is implemented by observers that want to know the moment
+// the run is canceled, by an error or by its parent context, while items may
+// still be in flight.
+type cancelObserver interface {
+	runCanceled(at time.Time)
+}
+
+// retryObserver is implemented by observers that also want to hear about
+// retries, which happen inside of a single item.
+type retryObserver interface {
+	itemRetry(index int, attempt int, err error, wait time.Duration)
+}
+
+func (c *config) observed() bool {
+	return c != nil && len(c.observers) > 0
+}
+
+// addObserver registers o with the config. Options use this rather than
+// appending directly so the order observers are called in is the order the
+// options were given.
+func (c *config) addObserver(o observer) {
+	c.observers = append(c.observers, o)
+}
+
+// observe returns middleware that lets every observer hear about each item
+// as it finishes, or nil if there are none. Each worker gets middleware of
+// its own, so observers know which worker processed the item. The logging,
+// metrics, hooks, and stats options are all observers.
+func (c *config) observe(worker int) Middleware {
+	if !c.observed() {
+		return nil
+	}
+	observers, clock := c.observers, c.getClock()
+	var starters []startObserver
+	for _, o := range observers {
+		if s, ok := o.(startObserver); ok {
+			starters = append(starters, s)
+		}
+	}
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			for _, s := range starters {
+				s.itemStart(worker, index)
+			}
+			start := clock.Now()
+			err := fn(ctx, index)
+			elapsed := since(clock, start)
+			for _, o := range observers {
+				o.itemDone(worker, index, elapsed, err)
+			}
+			return err
+		}
+	}
+}
+
+func (c *config) runStart(ctx context.Context, total int) time.Time {
+	clock := c.getClock()
+	start := clock.Now()
+	for _, o := range c.observers {
+		if co, ok := o.(clockObserver); ok {
+			co.useClock(clock)
+		}
+		o.runStart(ctx, total, start)
+	}
+	return start
+}
+
+func (c *config) runEnd(err error, start time.Time) {
+	elapsed := since(c.getClock(), start)
+	for _, o := range c.observers {
+		o.runEnd(err, elapsed)
+	}
+}
+
+// retryObservers returns the observers that want to hear about retries.
+func (c *config) retryObservers() []retryObserver {
+	var observers []retryObserver
+	for _, o := range c.observers {
+		if r, ok := o.(retryObserver); ok {
+			observers = append(observers, r)
+		}
+	}
+	return observers
+}
+
+func (c *config) workerStart(worker int) {
+	if !c.observed() {
+		return
+	}
+	for _, o := range c.observers {
+		if w, ok := o.(workerObserver); ok {
+			w.workerStart(worker)
+		}
+	}
+}
+
+func (c *config) workerEnd(worker int) {
+	if !c.observed() {
+		return
+	}
+	for _, o := range c.observers {
+		if w, ok := o.(workerObserver); ok {
+			w.workerEnd(worker)
+		}
+	}
+}
+
+func (c *config) runCanceled() {
+	if !c.observed() {
+		return
+	}
+	now := c.getClock().Now()
+	for _, o := range c.observers {
+		if co, ok := o.(cancelObserver); ok {
+			co.runCanceled(now)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package spara
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// Option configures a call to Do.
+type Option func(*config)
+
+// config holds the settings built up from a list of options. A nil *config
+// is valid and behaves like one with nothing set, which lets the plain
+// RunWithContext path skip building one at all.
+type config struct {
+	workers int
+	name    func(index int) string
+	pool    *Pool
+	poolSet bool
+
+	attempts  int
+	retrySet  bool
+	backoff   Backoff
+	retryable func(error) bool
+
+	observers []observer
+
+	labels       []string
+	labelsSet    bool
+	indexBuckets int
+
+	slowThreshold time.Duration
+	slowItem      func(index int, elapsed time.Duration)
+
+	debug func(format string, args ...any)
+
+	completions bool
+
+	runName string
+
+	noCancel bool
+
+	scheduler     Scheduler
+	checkEvery    int
+	checkEverySet bool
+
+	budget    int64
+	budgetSet bool
+	cost      func(index int) int64
+
+	limiter Limiter
+	rate    RateLimiter
+
+	dedupeKey   func(index int) string
+	dedupeShare func(from, to int)
+
+	sequential bool
+	verify     bool
+
+	cleanup        func(ctx context.Context, index int, err error)
+	cleanupTimeout time.Duration
+
+	finalize func(ctx context.Context, index int, err error)
+
+	middleware []Middleware
+
+	clock Clock
+
+	chaos *Chaos
+
+	earlyReturn bool
+	earlyDone   func(err error)
+	earlyStop   func(err error)
+
+	onStop func(cause error)
+
+	ignoreDefaults bool
+
+	collect bool
+
+	timeout        time.Duration
+	timeoutSet     bool
+	itemTimeout    time.Duration
+	itemTimeoutSet bool
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		workers: runtime.GOMAXPROCS(0),
+	}
+	for _, opt := range getDefaults() {
+		opt(c)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.ignoreDefaults && getDefaults() != nil {
+		// Options can't be undone, so start over without the defaults.
+		c = &config{
+			workers: runtime.GOMAXPROCS(0),
+		}
+		for _, opt := range opts {
+			opt(c)
+		}
+	}
+	return c
+}
+
+// validate checks the settings that can't be checked as the options are
+// applied, since options have no way of returning an error, and then claims
+// anything that can only be used by a single run.
+func (c *config) validate() error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	return c.claim()
+}
+
+// check is the part of validate that only looks at the settings, and so only
+// needs doing once for a given list of options.
+func (c *config) check() error {
+	if c.workers <= 0 {
+		return invalid(ErrInvalidWorkers, "workers", c.workers)
+	}
+	if c.retrySet && c.attempts < 1 {
+		return invalid(ErrInvalidAttempts, "attempts", c.attempts)
+	}
+	if len(c.labels)%2 != 0 {
+		return invalid(ErrInvalidLabels, "keyvals", c.labels)
+	}
+	if c.indexBuckets < 0 {
+		return invalid(ErrInvalidIndexBuckets, "indexBuckets", c.indexBuckets)
+	}
+	if c.slowItem != nil && c.slowThreshold <= 0 {
+		return invalid(ErrInvalidThreshold, "threshold", c.slowThreshold)
+	}
+	if c.budgetSet && c.budget <= 0 {
+		return invalid(ErrInvalidBudget, "limit", c.budget)
+	}
+	if c.budgetSet && c.cost == nil {
+		return invalid(ErrInvalidBudget, "cost", nil)
+	}
+	if c.checkEvery < 0 {
+		return invalid(ErrInvalidCheckInterval, "checkEvery", c.checkEvery)
+	}
+	if c.timeoutSet && c.timeout <= 0 {
+		return invalid(ErrInvalidTimeout, "timeout", c.timeout)
+	}
+	if c.itemTimeoutSet && c.itemTimeout <= 0 {
+		return invalid(ErrInvalidTimeout, "itemTimeout", c.itemTimeout)
+	}
+	if c.cleanup != nil && c.cleanupTimeout <= 0 {
+		return invalid(ErrInvalidTimeout, "timeout", c.cleanupTimeout)
+	}
+	if c.chaos != nil {
+		if err := c.chaos.validate(); err != nil {
+			return err
+		}
+	}
+	if v, ok := c.scheduler.(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+	for _, o := range c.observers {
+		if v, ok := o.(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// claim claims the single-use resources the options refer to, like Trackers.
+// If one of them has already been used, the ones claimed before it are
+// released again, since this run won't be using them either.
+func (c *config) claim() error {
+	for i, o := range c.observers {
+		if t, ok := o.(*Tracker); ok {
+			if err := t.claim(); err != nil {
+				for _, o := range c.observers[:i] {
+					if t, ok := o.(*Tracker); ok {
+						t.release()
+					}
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// abandon is called in place of a run that was rejected before it started.
+// It uses up the run's Trackers that aren't already following another run,
+// closing their updates, since no run will ever do that for them.
+func (c *config) abandon() {
+	if c == nil {
+		return
+	}
+	for _, o := range c.observers {
+		if t, ok := o.(*Tracker); ok {
+			t.abandon()
+		}
+	}
+}
+
+// Workers sets the maximum number of goroutines that will call the mapping
+// function concurrently.
+func Workers(n int) Option {
+	return func(c *config) {
+		c.workers = n
+	}
+}
+
+// NoCancel skips creating the child context that's canceled when the mapping
+// function returns an error, and passes the mapping function the parent
+// context instead. The first error still stops any more indices from being
+// handed out, but items already in progress run to completion rather than
+// being canceled, so it's only a good fit for short, CPU-bound items that
+// never look at the context anyway, where it saves the cost of setting up and
+// tearing down the child context on every run. Run, which has no context to
+// pass along, always works this way.
+func NoCancel() Option {
+	return func(c *config) {
+		c.noCancel = true
+	}
+}
+
+// cancelable reports whether the run needs a context of its own to cancel.
+func (c *config) cancelable() bool {
+	return c == nil || !c.noCancel
+}
+
+// Names gives each index a human readable name. When the mapping function
+// fails, the returned error will be an *ItemError carrying the name of the
+// index that failed, so it reads as "thumbnail:user-42" rather than some
+// meaningless offset into a slice. The name function is only called for
+// indices that fail, for the ones in flight when the run is on a Pool and
+// Pool.Jobs is called, and for every item passed to the item hooks of
+// WithHooks; an empty name leaves the error unwrapped.
+func Names(name func(index int) string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// itemName returns the name of index, or "" if the run doesn't name its
+// items.
+func (c *config) itemName(index int) string {
+	if c == nil || c.name == nil {
+		return ""
+	}
+	return c.name(index)
+}
+
+// wrapError decorates an error returned from the mapping function with
+// whatever the config knows about the item that returned it.
+func (c *config) wrapError(index int, err error) error {
+	if c == nil || (c.name == nil && c.runName == "") {
+		return err
+	}
+	var name string
+	if c.name != nil {
+		name = c.name(index)
+	}
+	if name == "" && c.runName == "" {
+		return err
+	}
+	return &ItemError{Run: c.runName, Index: index, Name: name, Err: err}
+}
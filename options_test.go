@@ -0,0 +1,89 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDoWorkersOption(t *testing.T) {
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 10, noop, Workers(0)); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected calling Do with zero workers to fail: %v", err)
+	}
+	if err := Do(context.Background(), 10, noop); err != nil {
+		t.Errorf("expected calling Do with default workers to succeed: %v", err)
+	}
+}
+
+func TestNamesOption(t *testing.T) {
+	expectedError := errors.New("boom")
+	name := func(i int) string {
+		if i == 3 {
+			return ""
+		}
+		return fmt.Sprintf("item-%d", i)
+	}
+
+	err := Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		if i == 7 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), Names(name))
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) || itemErr.Name != "item-7" {
+		t.Errorf("expected an *ItemError for item-7: %v", err)
+	}
+
+	// Empty names leave the error alone.
+	err = Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		if i == 3 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), Names(name))
+	if err != expectedError {
+		t.Errorf("expected the unwrapped error: %v", err)
+	}
+}
+
+func TestNoCancelOption(t *testing.T) {
+	type key struct{}
+	parent := context.WithValue(context.Background(), key{}, "parent")
+	expectedError := errors.New("boom")
+	var canceled bool
+	err := Do(parent, 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			return expectedError
+		}
+		// Without a child context, the error can't cancel this item.
+		select {
+		case <-ctx.Done():
+			canceled = true
+		case <-time.After(50 * time.Millisecond):
+		}
+		return nil
+	}, Workers(2), NoCancel())
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if canceled {
+		t.Errorf("expected the in-flight item's context not to be canceled")
+	}
+
+	// Iteration still stops on the first error.
+	var calls int
+	err = Do(parent, 10, func(ctx context.Context, i int) error {
+		calls++
+		if ctx.Value(key{}) != "parent" {
+			t.Errorf("expected the parent's values")
+		}
+		return expectedError
+	}, Workers(1), NoCancel())
+	if err != expectedError || calls != 1 {
+		t.Errorf("expected iteration to stop after the error: %v, %d calls", err, calls)
+	}
+}
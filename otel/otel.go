@@ -0,0 +1,105 @@
+// Package otel traces spara runs with OpenTelemetry. A run gets a span of its
+// own, and every item runs under that span's context, so anything the
+// mapping function calls that is itself instrumented, like an HTTP client or
+// database driver, shows up in the trace under the run. Items can optionally
+// get child spans of their own too, sampled so that huge runs don't produce
+// huge traces.
+package otel
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/heyimalex/spara"
+)
+
+var ErrInvalidSampling = errors.New("otel: item span sampling must be at least 1")
+
+// Option configures Do.
+type Option func(*config)
+
+type config struct {
+	itemSpans bool
+	every     int
+	opts      []spara.Option
+}
+
+// ItemSpans gives items spans of their own, as children of the run's span.
+// Only one out of every n items is traced, by index, so ItemSpans(1) traces
+// every item and ItemSpans(100) traces one percent of them. Items without a
+// span of their own still run under the run's span. Item spans record the
+// item's index as spara.index, and, if the options passed with Spara
+// include spara.Names, its name as spara.item.
+func ItemSpans(n int) Option {
+	return func(c *config) {
+		c.itemSpans = true
+		c.every = n
+	}
+}
+
+// Spara passes options through to spara.Do.
+func Spara(opts ...spara.Option) Option {
+	return func(c *config) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+// Do is spara.Do, traced with tracer under a span called name. The span
+// records the number of iterations, and the run's error if it fails. The run
+// is also given name with spara.Named, unless the options passed with Spara
+// name it something else.
+func Do(parent context.Context, tracer trace.Tracer, name string, iterations int, fn spara.MappingFunc, opts ...Option) error {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.itemSpans && c.every < 1 {
+		return &spara.ValidationError{Param: "itemSpans", Value: c.every, Err: ErrInvalidSampling}
+	}
+	if parent == nil {
+		return spara.ErrNilContext
+	}
+	if fn == nil {
+		return spara.ErrNilMappingFunction
+	}
+
+	ctx, span := tracer.Start(parent, name, trace.WithAttributes(
+		attribute.Int("spara.iterations", iterations),
+	))
+	defer span.End()
+
+	traced := fn
+	if c.itemSpans {
+		itemName := name + ".item"
+		traced = func(ctx context.Context, index int) error {
+			if index%c.every != 0 {
+				return fn(ctx, index)
+			}
+			attrs := []attribute.KeyValue{attribute.Int("spara.index", index)}
+			if name := spara.ItemName(ctx); name != "" {
+				attrs = append(attrs, attribute.String("spara.item", name))
+			}
+			ctx, span := tracer.Start(ctx, itemName, trace.WithAttributes(attrs...))
+			defer span.End()
+			err := fn(ctx, index)
+			recordError(span, err)
+			return err
+		}
+	}
+
+	sparaOpts := append([]spara.Option{spara.Named(name)}, c.opts...)
+	err := spara.Do(ctx, iterations, traced, sparaOpts...)
+	recordError(span, err)
+	return err
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,140 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/heyimalex/spara"
+)
+
+// recorder is a minimal tracer that keeps every span it starts, so the
+// tests don't need the full SDK.
+type recorder struct {
+	embedded.Tracer
+
+	mu    sync.Mutex
+	next  byte
+	spans []*span
+}
+
+type span struct {
+	noop.Span
+	name   string
+	sc     trace.SpanContext
+	parent trace.SpanContext
+	attrs  []attribute.KeyValue
+	status codes.Code
+}
+
+func (r *recorder) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	parent := trace.SpanContextFromContext(ctx)
+	config := trace.NewSpanStartConfig(opts...)
+	traceID := parent.TraceID()
+	if !parent.IsValid() {
+		traceID = trace.TraceID{r.next}
+	}
+	s := &span{
+		name:   name,
+		parent: parent,
+		attrs:  config.Attributes(),
+		sc:     trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{r.next}}),
+	}
+	r.spans = append(r.spans, s)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (s *span) SpanContext() trace.SpanContext      { return s.sc }
+func (s *span) SetStatus(code codes.Code, _ string) { s.status = code }
+
+func TestDo(t *testing.T) {
+	tracer := &recorder{}
+	var mismatched int32
+	err := Do(context.Background(), tracer, "job", 10, func(ctx context.Context, i int) error {
+		// Every item must run under the run's trace.
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			atomic.AddInt32(&mismatched, 1)
+		}
+		return nil
+	}, ItemSpans(5), Spara(spara.Workers(1)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if mismatched != 0 {
+		t.Errorf("expected every item to have a span context")
+	}
+
+	spans := tracer.spans
+	if len(spans) != 3 || spans[0].name != "job" {
+		t.Fatalf("expected a run span and 2 item spans, got %d", len(spans))
+	}
+	for _, s := range spans[1:] {
+		if s.name != "job.item" || s.parent.SpanID() != spans[0].sc.SpanID() {
+			t.Errorf("expected item spans to be children of the run span")
+		}
+	}
+}
+
+// attr returns the value of the attribute called key, or "" if s doesn't
+// have one.
+func (s *span) attr(key attribute.Key) string {
+	for _, kv := range s.attrs {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestDoItemNames(t *testing.T) {
+	tracer := &recorder{}
+	err := Do(context.Background(), tracer, "job", 3, func(ctx context.Context, i int) error {
+		return nil
+	}, ItemSpans(1), Spara(spara.Workers(1), spara.Names(func(i int) string { return fmt.Sprint("user-", i) })))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, s := range tracer.spans[1:] {
+		if s.attr("spara.item") != "user-"+s.attr("spara.index") {
+			t.Errorf("expected the item's name: %v", s.attrs)
+		}
+	}
+
+	tracer = &recorder{}
+	Do(context.Background(), tracer, "job", 1, func(ctx context.Context, i int) error { return nil }, ItemSpans(1))
+	if s := tracer.spans[1]; s.attr("spara.item") != "" {
+		t.Errorf("expected no name without spara.Names: %v", s.attrs)
+	}
+}
+
+func TestDoError(t *testing.T) {
+	tracer := &recorder{}
+	expectedError := errors.New("boom")
+	err := Do(context.Background(), tracer, "job", 3, func(ctx context.Context, i int) error {
+		return expectedError
+	}, ItemSpans(1), Spara(spara.Workers(1)))
+	if !errors.Is(err, expectedError) {
+		t.Fatalf("expected the error: %v", err)
+	}
+	for _, s := range tracer.spans {
+		if s.status != codes.Error {
+			t.Errorf("expected %s to record the error", s.name)
+		}
+	}
+
+	if err := Do(context.Background(), tracer, "job", 1, func(ctx context.Context, i int) error { return nil }, ItemSpans(0)); !errors.Is(err, ErrInvalidSampling) {
+		t.Errorf("expected ErrInvalidSampling: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/heyimalex/spara"
+)
+
+// Collector is a terminal stage that gathers every item of a stream. Each
+// worker appends to its own buffer, and the buffers are only merged once the
+// pipeline has finished, so the workers never contend over a shared lock.
+type Collector[T any] struct {
+	parts [][]T
+}
+
+// Collect creates a Collector consuming in. The Workers option sets how many
+// goroutines receive from in; it's only worth raising when the receiving
+// itself is the bottleneck.
+func Collect[T any](in *Stream[T], opts ...StageOption) *Collector[T] {
+	p := in.p
+	c := p.newStage("collect", opts)
+	in.consume(c)
+	col := &Collector[T]{parts: make([][]T, c.workers)}
+	p.addWorkerStage(c, c.workers, func(ctx context.Context, worker int) error {
+		for {
+			item, ok, err := in.recv(ctx)
+			if !ok {
+				return err
+			}
+			col.parts[worker] = append(col.parts[worker], item)
+		}
+	})
+	return col
+}
+
+// Items returns everything collected, in no particular order. It must only be
+// called once Run has returned.
+func (c *Collector[T]) Items() []T {
+	n := 0
+	for _, part := range c.parts {
+		n += len(part)
+	}
+	items := make([]T, 0, n)
+	for _, part := range c.parts {
+		items = append(items, part...)
+	}
+	return items
+}
+
+// MapCollector is a terminal stage that gathers every item of a stream into
+// a map. Like Collector, each worker builds its own map, and they're merged
+// once the pipeline has finished.
+type MapCollector[K comparable, V any] struct {
+	parts []map[K]V
+}
+
+// CollectMap creates a MapCollector consuming in, using fn to turn each item
+// into a key and value. If two items have the same key, which one ends up in
+// the map is undefined.
+func CollectMap[T any, K comparable, V any](in *Stream[T], fn func(item T) (K, V), opts ...StageOption) *MapCollector[K, V] {
+	p := in.p
+	c := p.newStage("collect", opts)
+	in.consume(c)
+	col := &MapCollector[K, V]{parts: make([]map[K]V, c.workers)}
+	if fn == nil {
+		p.fail(spara.ErrNilMappingFunction)
+		return col
+	}
+	for i := range col.parts {
+		col.parts[i] = make(map[K]V)
+	}
+	p.addWorkerStage(c, c.workers, func(ctx context.Context, worker int) error {
+		part := col.parts[worker]
+		for {
+			item, ok, err := in.recv(ctx)
+			if !ok {
+				return err
+			}
+			k, v := fn(item)
+			part[k] = v
+		}
+	})
+	return col
+}
+
+// Map returns everything collected. It must only be called once Run has
+// returned.
+func (c *MapCollector[K, V]) Map() map[K]V {
+	if len(c.parts) == 1 {
+		return c.parts[0]
+	}
+	n := 0
+	for _, part := range c.parts {
+		n += len(part)
+	}
+	m := make(map[K]V, n)
+	for _, part := range c.parts {
+		for k, v := range part {
+			m[k] = v
+		}
+	}
+	return m
+}
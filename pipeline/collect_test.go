@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	p := New(context.Background())
+	doubled := Map(FromSlice(p, inputs), func(ctx context.Context, x int) (int, error) {
+		return x * 2, nil
+	}, Workers(4))
+	col := Collect(doubled, Workers(3))
+	if err := p.Run(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	items := col.Items()
+	sort.Ints(items)
+	if len(items) != len(inputs) {
+		t.Fatalf("expected %d items: %v", len(inputs), items)
+	}
+	for i, x := range items {
+		if x != i*2 {
+			t.Fatalf("unexpected items: %v", items)
+		}
+	}
+}
+
+func TestCollectMap(t *testing.T) {
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	p := New(context.Background())
+	col := CollectMap(FromSlice(p, inputs), func(x int) (string, int) {
+		return strconv.Itoa(x), x
+	}, Workers(3))
+	if err := p.Run(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	m := col.Map()
+	if len(m) != len(inputs) {
+		t.Fatalf("expected %d entries: %v", len(inputs), m)
+	}
+	for _, x := range inputs {
+		if m[strconv.Itoa(x)] != x {
+			t.Fatalf("unexpected map: %v", m)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/heyimalex/spara"
+)
+
+var ErrInvalidReorderBuffer = errors.New("pipeline: invalid reorder buffer size")
+
+// ReorderBuffer sets how many items an OrderedMap stage may have outstanding
+// at once, counting both items being processed and finished items waiting on
+// an earlier one. Once it's full, the stage stops accepting input until the
+// oldest item finishes, so a single slow item can't make the stage buffer an
+// unbounded number of results behind it. The default is twice the number of
+// workers; anything smaller than the number of workers leaves some of them
+// idle.
+func ReorderBuffer(n int) StageOption {
+	return func(c *stageConfig) {
+		c.reorder = n
+	}
+}
+
+// OrderedMap is like Map, except the results are emitted in the same order as
+// the items they came from, even though they're processed concurrently.
+// Results that finish early are held until everything before them has been
+// emitted; ReorderBuffer bounds how many that can be.
+func OrderedMap[T, R any](in *Stream[T], fn func(ctx context.Context, item T) (R, error), opts ...StageOption) *Stream[R] {
+	p := in.p
+	c := p.newStage("ordered-map", opts)
+	in.consume(c)
+	out := newStream[R](c)
+	if fn == nil {
+		p.fail(spara.ErrNilMappingFunction)
+		return out
+	}
+	window := c.reorder
+	if window == 0 {
+		window = 2 * c.workers
+	}
+	if window < 0 {
+		p.fail(invalid(ErrInvalidReorderBuffer, "reorderBuffer", window))
+		return out
+	}
+
+	p.addStage(c, 1, func(ctx context.Context) error {
+		s := &sequencer[T, R]{
+			tasks:   make(chan sequenced[T]),
+			slots:   make(chan struct{}, window),
+			notify:  make(chan struct{}, 1),
+			results: make([]sequenced[R], window),
+			total:   -1,
+		}
+		// One goroutine hands out sequence numbers, one emits results in
+		// sequence, and the rest do the actual work.
+		n := c.workers + 2
+		return spara.Do(ctx, n, func(ctx context.Context, i int) error {
+			switch i {
+			case 0:
+				return s.dispatch(ctx, in)
+			case 1:
+				return s.emit(ctx, out)
+			default:
+				return s.work(ctx, c, fn)
+			}
+		}, spara.Workers(n), spara.IgnoreDefaults())
+	}, out)
+	return out
+}
+
+type sequenced[T any] struct {
+	seq   int
+	value T
+	ok    bool
+}
+
+// sequencer is the state shared by the goroutines of an OrderedMap stage.
+// slots is a semaphore with one slot per outstanding item, and results is a
+// ring buffer of the same size, so an item's result always has a place to go
+// that nothing else is using.
+type sequencer[T, R any] struct {
+	tasks  chan sequenced[T]
+	slots  chan struct{}
+	notify chan struct{}
+
+	mu      sync.Mutex
+	results []sequenced[R]
+	total   int // number of items, or -1 while input is still open
+}
+
+func (s *sequencer[T, R]) dispatch(ctx context.Context, in *Stream[T]) error {
+	defer close(s.tasks)
+	for seq := 0; ; seq++ {
+		item, ok, err := in.recv(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			s.mu.Lock()
+			s.total = seq
+			s.mu.Unlock()
+			s.wake()
+			return nil
+		}
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case s.tasks <- sequenced[T]{seq: seq, value: item}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *sequencer[T, R]) work(ctx context.Context, c *stage, fn func(ctx context.Context, item T) (R, error)) error {
+	for {
+		var task sequenced[T]
+		var ok bool
+		select {
+		case task, ok = <-s.tasks:
+			if !ok {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		start := time.Now()
+		result, err := fn(ctx, task.value)
+		c.addBusy(start)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.results[task.seq%len(s.results)] = sequenced[R]{seq: task.seq, value: result, ok: true}
+		s.mu.Unlock()
+		s.wake()
+	}
+}
+
+func (s *sequencer[T, R]) emit(ctx context.Context, out *Stream[R]) error {
+	for next := 0; ; {
+		s.mu.Lock()
+		slot := &s.results[next%len(s.results)]
+		result := *slot
+		if result.ok {
+			*slot = sequenced[R]{}
+		}
+		done := s.total == next
+		s.mu.Unlock()
+
+		if done {
+			return nil
+		}
+		if !result.ok {
+			select {
+			case <-s.notify:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		if err := out.send(ctx, result.value); err != nil {
+			return err
+		}
+		<-s.slots
+		next++
+	}
+}
+
+// wake lets the emitter know something changed without ever blocking; one
+// pending notification is enough, since it rechecks everything on waking.
+func (s *sequencer[T, R]) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOrderedMap(t *testing.T) {
+	inputs := make([]int, 200)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	var results []int
+	p := New(context.Background())
+	mapped := OrderedMap(FromSlice(p, inputs), func(ctx context.Context, x int) (int, error) {
+		time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+		return x * 2, nil
+	}, Workers(8))
+	Sink(mapped, func(ctx context.Context, x int) error {
+		results = append(results, x)
+		return nil
+	})
+	if err := p.Run(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(results) != len(inputs) {
+		t.Fatalf("expected %d results: %d", len(inputs), len(results))
+	}
+	for i, x := range results {
+		if x != i*2 {
+			t.Fatalf("results out of order at %d: %v", i, results)
+		}
+	}
+}
+
+// A slow first item must stop the stage from running more than the reorder
+// buffer ahead of it.
+func TestOrderedMapBackpressure(t *testing.T) {
+	const window = 4
+	var started int32
+	release := make(chan struct{})
+	full := make(chan struct{})
+
+	inputs := make([]int, 50)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	p := New(context.Background())
+	mapped := OrderedMap(FromSlice(p, inputs), func(ctx context.Context, x int) (int, error) {
+		if atomic.AddInt32(&started, 1) == window {
+			close(full)
+		}
+		if x == 0 {
+			<-release
+		}
+		return x, nil
+	}, Workers(8), ReorderBuffer(window), Name("ordered"))
+	Sink(mapped, func(ctx context.Context, x int) error { return nil })
+
+	done := make(chan error)
+	go func() { done <- p.Run() }()
+	// Once the buffer is full, the stage takes one more item and then
+	// waits on the first with it in hand.
+	waitReceived(t, p, "ordered", window+1)
+	<-full
+	for _, s := range p.Stats() {
+		if s.Name == "ordered" && s.Received != window+1 {
+			t.Errorf("received %d items with a reorder buffer of %d", s.Received, window)
+		}
+	}
+	if n := atomic.LoadInt32(&started); n != window {
+		t.Errorf("started %d items with a reorder buffer of %d", n, window)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestOrderedMapError(t *testing.T) {
+	expectedError := errors.New("")
+	p := New(context.Background())
+	mapped := OrderedMap(FromSlice(p, make([]int, 100)), func(ctx context.Context, x int) (int, error) {
+		return 0, expectedError
+	}, Workers(4))
+	Sink(mapped, func(ctx context.Context, x int) error { return nil })
+	if err := p.Run(); err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+
+	p = New(context.Background())
+	Sink(OrderedMap(FromSlice(p, []int{1}), func(ctx context.Context, x int) (int, error) {
+		return x, nil
+	}, ReorderBuffer(-1)), func(ctx context.Context, x int) error { return nil })
+	if err := p.Run(); !errors.Is(err, ErrInvalidReorderBuffer) {
+		t.Errorf("expected an invalid reorder buffer error: %v", err)
+	}
+}
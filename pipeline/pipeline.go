@@ -0,0 +1,337 @@
+// Package pipeline connects concurrent processing stages with bounded
+// buffers. Each stage has its own number of workers, and the whole pipeline
+// shares a single context; the first error in any stage cancels every other
+// stage, and is what Run returns.
+//
+// A pipeline is built by creating a source stream, and then passing it
+// through stage functions, each of which returns a new stream:
+//
+//	p := pipeline.New(ctx)
+//	paths := pipeline.FromSlice(p, files)
+//	images := pipeline.Map(paths, load, pipeline.Workers(4), pipeline.Buffer(8))
+//	large := pipeline.Filter(images, isLarge)
+//	pipeline.Sink(large, upload, pipeline.Workers(16))
+//	err := p.Run()
+//
+// Every stream must be consumed by exactly one stage; Run refuses to start a
+// pipeline where that isn't the case, since the unconsumed stream would
+// eventually block its producer forever.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/heyimalex/spara"
+)
+
+var (
+	ErrAlreadyRun        = errors.New("pipeline: pipeline has already been run")
+	ErrStreamConsumed    = errors.New("pipeline: stream already has a consumer")
+	ErrStreamNotConsumed = errors.New("pipeline: stream has no consumer")
+	ErrInvalidBuffer     = errors.New("pipeline: invalid buffer size")
+	ErrInvalidBatchSize  = errors.New("pipeline: invalid batch size")
+	ErrInvalidOutputs    = errors.New("pipeline: invalid number of outputs")
+	ErrInvalidMaxAge     = errors.New("pipeline: invalid max age")
+)
+
+// invalid reports value, passed as param, as invalid for the reason given by
+// the sentinel err, with the same spara.ValidationError the spara package
+// uses.
+func invalid(err error, param string, value any) error {
+	return &spara.ValidationError{Param: param, Value: value, Err: err}
+}
+
+// Pipeline is a set of connected stages. Stages are added by the stage
+// functions in this package, and nothing runs until Run is called.
+type Pipeline struct {
+	started int64 // unix nanos, updated atomically
+
+	parent  context.Context
+	metrics []*stage
+	stages  []func(ctx context.Context) error
+	streams []consumable
+	err     error
+	ran     bool
+
+	draining chan struct{}
+	drain    sync.Once
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// New creates an empty pipeline that will run under the parent context.
+func New(parent context.Context) *Pipeline {
+	return &Pipeline{
+		parent:   parent,
+		draining: make(chan struct{}),
+	}
+}
+
+// ShutdownMode selects how Shutdown stops a pipeline.
+type ShutdownMode int
+
+const (
+	// Drain stops the sources from taking in anything new, and lets every
+	// item already inside the pipeline flow through the remaining stages.
+	// Batches and windows in progress are flushed, just as if the input
+	// had run out, and Run returns nil if nothing fails along the way.
+	Drain ShutdownMode = iota
+	// Cancel cancels the context shared by the stages immediately, dropping
+	// anything in flight. Run returns context.Canceled.
+	Cancel
+)
+
+// Shutdown asks a running pipeline to stop early, in the given mode. It
+// doesn't wait; Run returns once the pipeline has actually stopped. It's safe
+// to call from any goroutine, more than once, and even before Run, in which
+// case the pipeline stops as soon as it starts. A Drain that's taking too
+// long can be escalated by calling Shutdown again with Cancel.
+func (p *Pipeline) Shutdown(mode ShutdownMode) {
+	switch mode {
+	case Drain:
+		p.drain.Do(func() { close(p.draining) })
+	case Cancel:
+		p.mu.Lock()
+		p.canceled = true
+		if p.cancel != nil {
+			p.cancel()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Run starts every stage and waits for them all to finish. Sources finish
+// when they run out of items, and every other stage finishes once its input
+// is closed and drained. If any stage returns an error, the context shared by
+// all of the stages is canceled, and Run returns that first error once they
+// have all stopped.
+//
+// Mistakes made while building the pipeline, like consuming a stream twice,
+// are reported by Run before anything is started.
+func (p *Pipeline) Run() error {
+	if p.ran {
+		return ErrAlreadyRun
+	}
+	p.ran = true
+	if p.err != nil {
+		return p.err
+	}
+	for _, s := range p.streams {
+		if !s.consumed() {
+			return ErrStreamNotConsumed
+		}
+	}
+	if len(p.stages) == 0 {
+		return nil
+	}
+	if p.parent == nil {
+		return spara.ErrNilContext
+	}
+
+	ctx, cancel := context.WithCancel(p.parent)
+	defer cancel()
+	p.mu.Lock()
+	p.cancel = cancel
+	if p.canceled {
+		cancel()
+	}
+	p.mu.Unlock()
+
+	atomic.StoreInt64(&p.started, time.Now().UnixNano())
+	// Every stage needs its own goroutine, since they all run at once.
+	n := len(p.stages)
+	return spara.Do(ctx, n, func(ctx context.Context, i int) error {
+		return p.stages[i](ctx)
+	}, spara.Workers(n), spara.IgnoreDefaults())
+}
+
+// fail records the first error made while building the pipeline.
+func (p *Pipeline) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// addStage registers the work for stage st, made up of workers goroutines all
+// running fn. The stage's output streams are closed once they have all
+// returned.
+func (p *Pipeline) addStage(st *stage, workers int, fn func(ctx context.Context) error, outputs ...closer) {
+	p.addWorkerStage(st, workers, func(ctx context.Context, _ int) error {
+		return fn(ctx)
+	}, outputs...)
+}
+
+// addWorkerStage is like addStage, but also tells each goroutine which
+// worker it is, in the range [0, workers).
+func (p *Pipeline) addWorkerStage(st *stage, workers int, fn func(ctx context.Context, worker int) error, outputs ...closer) {
+	st.running = workers
+	p.stages = append(p.stages, func(ctx context.Context) error {
+		defer func() {
+			for _, out := range outputs {
+				out.close()
+			}
+			st.finish()
+		}()
+		return spara.Do(ctx, workers, fn, spara.Workers(workers), spara.IgnoreDefaults())
+	})
+}
+
+// Stream is the output of one stage and the input of another. Streams are
+// only ever handed out by the functions in this package.
+type Stream[T any] struct {
+	p        *Pipeline
+	ch       chan T
+	producer *stage
+	consumer *stage
+}
+
+type consumable interface {
+	consumed() bool
+}
+
+type closer interface {
+	close()
+}
+
+// newStream creates the output stream of stage st.
+func newStream[T any](st *stage) *Stream[T] {
+	p := st.p
+	buffer := st.buffer
+	if buffer < 0 {
+		p.fail(invalid(ErrInvalidBuffer, "buffer", buffer))
+		buffer = 0
+	}
+	s := &Stream[T]{p: p, ch: make(chan T, buffer), producer: st}
+	p.streams = append(p.streams, s)
+	return s
+}
+
+func (s *Stream[T]) consumed() bool { return s.consumer != nil }
+func (s *Stream[T]) close()         { close(s.ch) }
+
+// consume claims the stream as the input of stage st.
+func (s *Stream[T]) consume(st *stage) {
+	if s.consumer != nil {
+		s.p.fail(ErrStreamConsumed)
+		return
+	}
+	s.consumer = st
+	st.queued = append(st.queued, func() int { return len(s.ch) })
+}
+
+// recv receives the next item from the stream. ok is false once the stream
+// is closed, and err is non-nil if the context completes first.
+func (s *Stream[T]) recv(ctx context.Context) (item T, ok bool, err error) {
+	select {
+	case item, ok = <-s.ch:
+		if ok {
+			s.received()
+		}
+		return item, ok, nil
+	case <-ctx.Done():
+		return item, false, ctx.Err()
+	}
+}
+
+// intake is like send, but for sources; it also gives up if the pipeline
+// starts draining, in which case stop is true.
+func (s *Stream[T]) intake(ctx context.Context, item T) (stop bool, err error) {
+	select {
+	case s.ch <- item:
+		s.sent()
+		return false, nil
+	case <-s.p.draining:
+		return true, nil
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
+
+// send delivers an item downstream, unless the context completes first.
+func (s *Stream[T]) send(ctx context.Context, item T) error {
+	select {
+	case s.ch <- item:
+		s.sent()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// received and sent update the stage metrics. Stages that use the channel
+// directly rather than through recv and send must call them themselves.
+func (s *Stream[T]) received() { atomic.AddInt64(&s.consumer.received, 1) }
+func (s *Stream[T]) sent()     { atomic.AddInt64(&s.producer.emitted, 1) }
+
+// StageOption configures a single stage.
+type StageOption func(*stageConfig)
+
+type stageConfig struct {
+	name    string
+	workers int
+	buffer  int
+	reorder int
+	maxAge  time.Duration
+	clock   spara.Clock
+}
+
+// Name sets the name the stage is reported under by Stats. By default,
+// stages are named after their kind and position, eg "map-2".
+func Name(name string) StageOption {
+	return func(c *stageConfig) {
+		c.name = name
+	}
+}
+
+// Workers sets how many goroutines process the stage's input concurrently.
+// The default is 1.
+func Workers(n int) StageOption {
+	return func(c *stageConfig) {
+		c.workers = n
+	}
+}
+
+// Buffer sets how many items the stage's output stream can hold before the
+// stage blocks waiting on its consumer. The default is 0, ie unbuffered.
+func Buffer(n int) StageOption {
+	return func(c *stageConfig) {
+		c.buffer = n
+	}
+}
+
+// Clock sets the clock the stage tells time by, for the stages that wait on
+// it: Batch with MaxAge, Throttle, Debounce, and Window with a time based
+// spec. The default is spara.SystemClock; sparatest.Clock is one for tests.
+// Other stages ignore it, and so do the metrics reported by Stats, which are
+// always in real time.
+func Clock(clock spara.Clock) StageOption {
+	return func(c *stageConfig) {
+		c.clock = clock
+	}
+}
+
+// newStage applies the options for a new stage of the given kind, and
+// registers it for metrics.
+func (p *Pipeline) newStage(kind string, opts []StageOption) *stage {
+	st := &stage{p: p}
+	st.workers = 1
+	st.clock = spara.SystemClock
+	for _, opt := range opts {
+		opt(&st.stageConfig)
+	}
+	if st.workers <= 0 {
+		p.fail(invalid(spara.ErrInvalidWorkers, "workers", st.workers))
+		st.workers = 1
+	}
+	if st.name == "" {
+		st.name = fmt.Sprintf("%s-%d", kind, len(p.metrics))
+	}
+	p.metrics = append(p.metrics, st)
+	return st
+}
@@ -0,0 +1,324 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heyimalex/spara"
+	"github.com/heyimalex/spara/sparatest"
+)
+
+func TestPipeline(t *testing.T) {
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	var mu sync.Mutex
+	var results []int
+	var batches int
+
+	p := New(context.Background())
+	src := FromSlice(p, inputs)
+	doubled := Map(src, func(ctx context.Context, x int) (int, error) {
+		return x * 2, nil
+	}, Workers(4), Buffer(4))
+	small := Filter(doubled, func(ctx context.Context, x int) (bool, error) {
+		return x < 100, nil
+	}, Workers(2))
+	batched := Batch(small, 8)
+	Sink(batched, func(ctx context.Context, batch []int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(batch) > 8 {
+			t.Errorf("batch too large: %d", len(batch))
+		}
+		batches++
+		results = append(results, batch...)
+		return nil
+	}, Workers(3))
+
+	if err := p.Run(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Ints(results)
+	if len(results) != 50 {
+		t.Fatalf("expected 50 results: %v", results)
+	}
+	for i, x := range results {
+		if x != i*2 {
+			t.Fatalf("unexpected results: %v", results)
+		}
+	}
+	if batches != 7 {
+		t.Errorf("expected 7 batches: %d", batches)
+	}
+}
+
+// An error in a late stage must stop an earlier stage that would otherwise
+// block forever on a full buffer.
+func TestPipelineError(t *testing.T) {
+	expectedError := errors.New("")
+	ch := make(chan int)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case ch <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	p := New(context.Background())
+	src := FromChan(p, ch)
+	mapped := Map(src, func(ctx context.Context, x int) (int, error) {
+		return x, nil
+	}, Workers(2))
+	Sink(mapped, func(ctx context.Context, x int) error {
+		if x == 10 {
+			return expectedError
+		}
+		return nil
+	})
+
+	if err := p.Run(); err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
+
+func TestPipelineParentCanceled(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	p := New(parent)
+	src := FromChan(p, make(chan int))
+	Sink(src, func(ctx context.Context, x int) error { return nil })
+	if err := p.Run(); err != context.DeadlineExceeded {
+		t.Errorf("unexpected err returned: %#v", err)
+	}
+}
+
+func TestPipelineBuildErrors(t *testing.T) {
+	noop := func(ctx context.Context, x int) error { return nil }
+
+	p := New(context.Background())
+	FromSlice(p, []int{1})
+	if err := p.Run(); err != ErrStreamNotConsumed {
+		t.Errorf("expected an unconsumed stream error: %v", err)
+	}
+	if err := p.Run(); err != ErrAlreadyRun {
+		t.Errorf("expected an already run error: %v", err)
+	}
+
+	p = New(context.Background())
+	src := FromSlice(p, []int{1})
+	Sink(src, noop)
+	Sink(src, noop)
+	if err := p.Run(); err != ErrStreamConsumed {
+		t.Errorf("expected a consumed stream error: %v", err)
+	}
+
+	p = New(context.Background())
+	src = FromSlice(p, []int{1})
+	Sink(src, noop, Workers(0))
+	var verr *spara.ValidationError
+	if err := p.Run(); !errors.As(err, &verr) || verr.Err != spara.ErrInvalidWorkers || verr.Value != 0 {
+		t.Errorf("expected an invalid workers error: %v", err)
+	}
+
+	p = New(context.Background())
+	Sink(Batch(FromSlice(p, []int{1}), 0), func(ctx context.Context, x []int) error { return nil })
+	if err := p.Run(); !errors.Is(err, ErrInvalidBatchSize) {
+		t.Errorf("expected an invalid batch size error: %v", err)
+	}
+}
+
+func TestTee(t *testing.T) {
+	inputs := make([]int, 100)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	p := New(context.Background())
+	outs := Tee(FromSlice(p, inputs), 2, Buffer(4))
+	var stored, indexed []int
+	Sink(outs[0], func(ctx context.Context, x int) error {
+		stored = append(stored, x)
+		return nil
+	})
+	Sink(outs[1], func(ctx context.Context, x int) error {
+		time.Sleep(time.Microsecond * 10)
+		indexed = append(indexed, x)
+		return nil
+	})
+	if err := p.Run(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, results := range [][]int{stored, indexed} {
+		if len(results) != len(inputs) {
+			t.Fatalf("expected %d results: %v", len(inputs), results)
+		}
+		for i, x := range results {
+			if x != i {
+				t.Fatalf("unexpected results: %v", results)
+			}
+		}
+	}
+
+	p = New(context.Background())
+	outs = Tee(FromSlice(p, inputs), 2)
+	Sink(outs[0], func(ctx context.Context, x int) error { return nil })
+	if err := p.Run(); err != ErrStreamNotConsumed {
+		t.Errorf("expected an unconsumed stream error: %v", err)
+	}
+}
+
+func TestBatchMaxAge(t *testing.T) {
+	clock := sparatest.NewClock(time.Time{})
+	ch := make(chan int)
+	p := New(context.Background())
+	batches := Batch(FromChan(p, ch), 100, MaxAge(time.Millisecond*10), Clock(clock), Name("batch"))
+	sizes := make(chan int, 10)
+	Sink(batches, func(ctx context.Context, batch []int) error {
+		sizes <- len(batch)
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- p.Run() }()
+	// Two bursts separated by more than the max age should come out as two
+	// partial batches rather than waiting to fill one.
+	for i := 0; i < 3; i++ {
+		ch <- i
+	}
+	waitReceived(t, p, "batch", 3)
+	clock.Advance(time.Millisecond * 10)
+	if size := <-sizes; size != 3 {
+		t.Errorf("unexpected first batch: %d", size)
+	}
+	for i := 0; i < 2; i++ {
+		ch <- i
+	}
+	waitReceived(t, p, "batch", 5)
+	clock.Advance(time.Millisecond * 10)
+	if size := <-sizes; size != 2 {
+		t.Errorf("unexpected second batch: %d", size)
+	}
+	close(ch)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(sizes) != 0 {
+		t.Errorf("unexpected extra batch: %d", <-sizes)
+	}
+}
+
+// waitReceived waits until the named stage has received n items, for tests
+// that need to know items have arrived before they move a fake clock on.
+func waitReceived(t *testing.T, p *Pipeline, name string, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, s := range p.Stats() {
+			if s.Name == name && s.Received >= n {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s to receive %d items", name, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBatchMaxAgeClock(t *testing.T) {
+	clock := sparatest.NewClock(time.Time{})
+	ch := make(chan int)
+	p := New(context.Background())
+	batches := Batch(FromChan(p, ch), 100, MaxAge(time.Minute), Clock(clock), Name("batch"))
+	sizes := make(chan int, 10)
+	Sink(batches, func(ctx context.Context, batch []int) error {
+		sizes <- len(batch)
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- p.Run() }()
+	for i := 0; i < 3; i++ {
+		ch <- i
+	}
+	waitReceived(t, p, "batch", 3)
+	clock.Advance(time.Minute - 1)
+	ch <- 3
+	waitReceived(t, p, "batch", 4)
+	clock.Advance(1)
+	if size := <-sizes; size != 4 {
+		t.Errorf("expected the batch to be emitted a minute after its first item: %d", size)
+	}
+	ch <- 4
+	close(ch)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if size := <-sizes; size != 1 {
+		t.Errorf("unexpected final batch: %d", size)
+	}
+}
+
+func TestShutdownDrain(t *testing.T) {
+	ch := make(chan int)
+	p := New(context.Background())
+	batches := Batch(FromChan(p, ch), 100)
+	var received []int
+	Sink(batches, func(ctx context.Context, batch []int) error {
+		received = append(received, batch...)
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- p.Run() }()
+	for i := 0; i < 10; i++ {
+		ch <- i
+	}
+	p.Shutdown(Drain)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// The partial batch has to be flushed rather than dropped.
+	if len(received) != 10 {
+		t.Errorf("expected the 10 buffered items to be drained: %v", received)
+	}
+}
+
+func TestShutdownCancel(t *testing.T) {
+	p := New(context.Background())
+	started := make(chan struct{})
+	Sink(FromSlice(p, make([]int, 10)), func(ctx context.Context, x int) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	done := make(chan error)
+	go func() { done <- p.Run() }()
+	<-started
+	p.Shutdown(Cancel)
+	if err := <-done; err != context.Canceled {
+		t.Errorf("unexpected err returned: %#v", err)
+	}
+
+	// Shutting down before running stops the pipeline immediately.
+	p = New(context.Background())
+	Sink(FromChan(p, make(chan int)), func(ctx context.Context, x int) error { return nil })
+	p.Shutdown(Drain)
+	if err := p.Run(); err != nil {
+		t.Errorf("err: %v", err)
+	}
+}
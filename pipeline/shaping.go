@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/heyimalex/spara"
+)
+
+var (
+	ErrInvalidRate     = errors.New("pipeline: invalid rate")
+	ErrInvalidInterval = errors.New("pipeline: invalid interval")
+)
+
+// Throttle creates a stage that passes items along at no more than n per
+// interval, spaced evenly; Throttle(in, 100, time.Second) emits an item at
+// most every 10ms. Items are never dropped. When input arrives faster than
+// the rate, the stage stops receiving and the backpressure reaches whatever
+// is upstream.
+func Throttle[T any](in *Stream[T], n int, interval time.Duration, opts ...StageOption) *Stream[T] {
+	p := in.p
+	c := p.newStage("throttle", opts)
+	in.consume(c)
+	out := newStream[T](c)
+	if n <= 0 {
+		p.fail(invalid(ErrInvalidRate, "n", n))
+		return out
+	}
+	if interval <= 0 {
+		p.fail(invalid(ErrInvalidInterval, "interval", interval))
+		return out
+	}
+	spacing := interval / time.Duration(n)
+	p.addStage(c, 1, func(ctx context.Context) error {
+		var next time.Time
+		for {
+			item, ok, err := in.recv(ctx)
+			if !ok {
+				return err
+			}
+			if wait := next.Sub(c.clock.Now()); wait > 0 {
+				timer := c.clock.NewTimer(wait)
+				select {
+				case <-timer.C():
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+			if err := out.send(ctx, item); err != nil {
+				return err
+			}
+			next = c.clock.Now().Add(spacing)
+		}
+	}, out)
+	return out
+}
+
+// Debounce creates a stage that collapses bursts of items into the last item
+// of the burst. An item is only emitted once quiet has passed without any
+// newer item arriving; anything superseded in the meantime is dropped. If in
+// is closed while an item is pending, it's emitted right away.
+func Debounce[T any](in *Stream[T], quiet time.Duration, opts ...StageOption) *Stream[T] {
+	p := in.p
+	c := p.newStage("debounce", opts)
+	in.consume(c)
+	out := newStream[T](c)
+	if quiet <= 0 {
+		p.fail(invalid(ErrInvalidInterval, "quiet", quiet))
+		return out
+	}
+	p.addStage(c, 1, func(ctx context.Context) error {
+		var latest T
+		var timer spara.Timer
+		var expired <-chan time.Time
+		for {
+			select {
+			case item, ok := <-in.ch:
+				if !ok {
+					if timer != nil {
+						timer.Stop()
+						return out.send(ctx, latest)
+					}
+					return nil
+				}
+				latest = item
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = c.clock.NewTimer(quiet)
+				expired = timer.C()
+				// Counted once its timer is running, so a test that sees
+				// it counted knows the quiet period has started.
+				in.received()
+			case <-expired:
+				timer, expired = nil, nil
+				if err := out.send(ctx, latest); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}, out)
+	return out
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/heyimalex/spara/sparatest"
+)
+
+func TestThrottle(t *testing.T) {
+	p := New(context.Background())
+	throttled := Throttle(FromSlice(p, make([]int, 11)), 100, time.Second)
+	var times []time.Time
+	Sink(throttled, func(ctx context.Context, x int) error {
+		times = append(times, time.Now())
+		return nil
+	})
+	if err := p.Run(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// 11 items at 100/s means at least 10 gaps of 10ms.
+	if elapsed := times[len(times)-1].Sub(times[0]); elapsed < time.Millisecond*100 {
+		t.Errorf("items were not throttled: %v", elapsed)
+	}
+
+	p = New(context.Background())
+	Sink(Throttle(FromSlice(p, []int{1}), 0, time.Second), func(ctx context.Context, x int) error { return nil })
+	if err := p.Run(); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("expected an invalid rate error: %v", err)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	clock := sparatest.NewClock(time.Time{})
+	ch := make(chan int)
+	p := New(context.Background())
+	results := make(chan int, 10)
+	Sink(Debounce(FromChan(p, ch), time.Second, Clock(clock), Name("debounce")), func(ctx context.Context, x int) error {
+		results <- x
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- p.Run() }()
+	for i := 1; i <= 5; i++ {
+		ch <- i
+	}
+	waitReceived(t, p, "debounce", 5)
+	clock.Advance(time.Second - 1)
+	if len(results) != 0 {
+		t.Errorf("expected nothing before the burst went quiet: %d", <-results)
+	}
+	clock.Advance(1)
+	if x := <-results; x != 5 {
+		t.Errorf("expected the last item of the first burst: %d", x)
+	}
+	for i := 6; i <= 8; i++ {
+		ch <- i
+	}
+	close(ch)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	close(results)
+	var rest []int
+	for x := range results {
+		rest = append(rest, x)
+	}
+	if len(rest) != 1 || rest[0] != 8 {
+		t.Errorf("expected the last item of the second burst: %v", rest)
+	}
+}
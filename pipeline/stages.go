@@ -0,0 +1,266 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/heyimalex/spara"
+)
+
+// FromSlice creates a source stream that emits every item in items, in order.
+// Sources stop early if the pipeline is shut down.
+func FromSlice[T any](p *Pipeline, items []T, opts ...StageOption) *Stream[T] {
+	c := p.newStage("source", opts)
+	out := newStream[T](c)
+	p.addStage(c, 1, func(ctx context.Context) error {
+		for _, item := range items {
+			if stop, err := out.intake(ctx, item); stop {
+				return err
+			}
+		}
+		return nil
+	}, out)
+	return out
+}
+
+// FromChan creates a source stream that emits everything received from ch
+// until it's closed, or the pipeline is shut down. Anything left in ch after
+// a shutdown is left for the caller.
+func FromChan[T any](p *Pipeline, ch <-chan T, opts ...StageOption) *Stream[T] {
+	c := p.newStage("source", opts)
+	out := newStream[T](c)
+	p.addStage(c, 1, func(ctx context.Context) error {
+		for {
+			select {
+			case item, ok := <-ch:
+				if !ok {
+					return nil
+				}
+				// Having taken the item off of ch, it's part of the
+				// pipeline now, draining or not.
+				if err := out.send(ctx, item); err != nil {
+					return err
+				}
+			case <-p.draining:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}, out)
+	return out
+}
+
+// Map creates a stage that transforms every item of in with fn. Items are
+// processed concurrently, so with more than one worker the output order is
+// not the input order.
+func Map[T, R any](in *Stream[T], fn func(ctx context.Context, item T) (R, error), opts ...StageOption) *Stream[R] {
+	p := in.p
+	c := p.newStage("map", opts)
+	in.consume(c)
+	out := newStream[R](c)
+	if fn == nil {
+		p.fail(spara.ErrNilMappingFunction)
+		return out
+	}
+	p.addStage(c, c.workers, func(ctx context.Context) error {
+		for {
+			item, ok, err := in.recv(ctx)
+			if !ok {
+				return err
+			}
+			start := time.Now()
+			result, err := fn(ctx, item)
+			c.addBusy(start)
+			if err != nil {
+				return err
+			}
+			if err := out.send(ctx, result); err != nil {
+				return err
+			}
+		}
+	}, out)
+	return out
+}
+
+// Filter creates a stage that only passes along the items for which fn
+// returns true.
+func Filter[T any](in *Stream[T], fn func(ctx context.Context, item T) (bool, error), opts ...StageOption) *Stream[T] {
+	p := in.p
+	c := p.newStage("filter", opts)
+	in.consume(c)
+	out := newStream[T](c)
+	if fn == nil {
+		p.fail(spara.ErrNilMappingFunction)
+		return out
+	}
+	p.addStage(c, c.workers, func(ctx context.Context) error {
+		for {
+			item, ok, err := in.recv(ctx)
+			if !ok {
+				return err
+			}
+			start := time.Now()
+			keep, err := fn(ctx, item)
+			c.addBusy(start)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue
+			}
+			if err := out.send(ctx, item); err != nil {
+				return err
+			}
+		}
+	}, out)
+	return out
+}
+
+// Batch creates a stage that groups items into slices of up to size items.
+// A batch is emitted as soon as it's full, or once in is closed. With the
+// MaxAge option, batches are also emitted once their oldest item has waited
+// that long, so a trickle of input doesn't sit around waiting for a full
+// batch. Batching is inherently sequential, so the Workers option has no
+// effect; pass the batches to a Sink with multiple workers to handle them
+// concurrently.
+func Batch[T any](in *Stream[T], size int, opts ...StageOption) *Stream[[]T] {
+	p := in.p
+	c := p.newStage("batch", opts)
+	in.consume(c)
+	out := newStream[[]T](c)
+	if size <= 0 {
+		p.fail(invalid(ErrInvalidBatchSize, "size", size))
+		return out
+	}
+	if c.maxAge < 0 {
+		p.fail(invalid(ErrInvalidMaxAge, "maxAge", c.maxAge))
+		return out
+	}
+	p.addStage(c, 1, func(ctx context.Context) error {
+		batch := make([]T, 0, size)
+		var timer spara.Timer
+		var expired <-chan time.Time
+		flush := func() error {
+			if timer != nil {
+				timer.Stop()
+				timer, expired = nil, nil
+			}
+			full := batch
+			batch = make([]T, 0, size)
+			return out.send(ctx, full)
+		}
+		for {
+			select {
+			case item, ok := <-in.ch:
+				if !ok {
+					if len(batch) > 0 {
+						return flush()
+					}
+					return nil
+				}
+				batch = append(batch, item)
+				if len(batch) == 1 && c.maxAge > 0 {
+					timer = c.clock.NewTimer(c.maxAge)
+					expired = timer.C()
+				}
+				// Counted once it's in the batch, so a test that sees it
+				// counted knows it's there.
+				in.received()
+				if len(batch) == size {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			case <-expired:
+				if err := flush(); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}, out)
+	return out
+}
+
+// MaxAge limits how long an item can wait in a Batch stage before its batch
+// is emitted, even if it isn't full.
+func MaxAge(d time.Duration) StageOption {
+	return func(c *stageConfig) {
+		c.maxAge = d
+	}
+}
+
+// Sink creates a terminal stage that calls fn for every item of in.
+func Sink[T any](in *Stream[T], fn func(ctx context.Context, item T) error, opts ...StageOption) {
+	p := in.p
+	c := p.newStage("sink", opts)
+	in.consume(c)
+	if fn == nil {
+		p.fail(spara.ErrNilMappingFunction)
+		return
+	}
+	p.addStage(c, c.workers, func(ctx context.Context) error {
+		for {
+			item, ok, err := in.recv(ctx)
+			if !ok {
+				return err
+			}
+			start := time.Now()
+			err = fn(ctx, item)
+			c.addBusy(start)
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Tee creates a stage that copies every item of in to n output streams, so
+// that a single producer can feed several independent consumers. Every
+// output must be consumed. Each output gets its own buffer, sized by the
+// Buffer option, so a consumer that falls behind only holds the others up
+// once its buffer is full. Items are copied by assignment; if they're
+// pointers or contain slices or maps, consumers share them.
+func Tee[T any](in *Stream[T], n int, opts ...StageOption) []*Stream[T] {
+	p := in.p
+	c := p.newStage("tee", opts)
+	in.consume(c)
+	if n <= 0 {
+		p.fail(invalid(ErrInvalidOutputs, "n", n))
+		return nil
+	}
+	outs := make([]*Stream[T], n)
+	closers := make([]closer, n)
+	for i := range outs {
+		outs[i] = newStream[T](c)
+		closers[i] = outs[i]
+	}
+	p.addStage(c, 1, func(ctx context.Context) error {
+		pending := make([]*Stream[T], 0, n)
+		for {
+			item, ok, err := in.recv(ctx)
+			if !ok {
+				return err
+			}
+			// Deliver to every output with room right away, and only
+			// then wait on the ones that are full.
+			pending = pending[:0]
+			for _, out := range outs {
+				select {
+				case out.ch <- item:
+					out.sent()
+				default:
+					pending = append(pending, out)
+				}
+			}
+			for _, out := range pending {
+				if err := out.send(ctx, item); err != nil {
+					return err
+				}
+			}
+		}
+	}, closers...)
+	return outs
+}
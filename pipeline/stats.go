@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// stage holds a stage's configuration and metrics.
+type stage struct {
+	// Updated atomically; kept first so they're 64-bit aligned.
+	received int64
+	emitted  int64
+	busy     int64
+
+	stageConfig
+	p       *Pipeline
+	running int
+	queued  []func() int
+	done    int64 // unix nanos once finished, updated atomically
+}
+
+// addBusy records time spent in the stage's function since start.
+func (st *stage) addBusy(start time.Time) {
+	atomic.AddInt64(&st.busy, int64(time.Since(start)))
+}
+
+func (st *stage) finish() {
+	atomic.StoreInt64(&st.done, time.Now().UnixNano())
+}
+
+// StageStats is a snapshot of the metrics for a single stage.
+type StageStats struct {
+	Name string
+	// Workers is the number of goroutines running the stage.
+	Workers int
+	// Received is the number of items taken from the stage's input.
+	Received int64
+	// Emitted is the number of items sent to the stage's outputs.
+	Emitted int64
+	// Queued is the number of items currently sitting in the stage's
+	// input buffer, waiting for a worker.
+	Queued int
+	// Busy is the total time the stage's workers have spent inside its
+	// function, for stages that take one. Time spent waiting on input or
+	// on a full output isn't counted.
+	Busy time.Duration
+	// Elapsed is how long the stage has been running, or ran for.
+	Elapsed time.Duration
+}
+
+// Throughput returns the number of items received per second.
+func (s StageStats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Received) / s.Elapsed.Seconds()
+}
+
+// Utilization returns the fraction of the stage's available worker time that
+// was spent busy, between 0 and 1. A stage close to 1 with a full input queue
+// is the bottleneck; adding workers to it is likely to help.
+func (s StageStats) Utilization() float64 {
+	if s.Elapsed <= 0 || s.Workers <= 0 {
+		return 0
+	}
+	return s.Busy.Seconds() / (s.Elapsed.Seconds() * float64(s.Workers))
+}
+
+// Stats returns a snapshot of every stage's metrics, in the order the stages
+// were added. It's safe to call while the pipeline is running, which is
+// usually the point.
+func (p *Pipeline) Stats() []StageStats {
+	started := atomic.LoadInt64(&p.started)
+	now := time.Now().UnixNano()
+	stats := make([]StageStats, len(p.metrics))
+	for i, st := range p.metrics {
+		s := StageStats{
+			Name:     st.name,
+			Workers:  st.running,
+			Received: atomic.LoadInt64(&st.received),
+			Emitted:  atomic.LoadInt64(&st.emitted),
+			Busy:     time.Duration(atomic.LoadInt64(&st.busy)),
+		}
+		for _, queued := range st.queued {
+			s.Queued += queued()
+		}
+		if started != 0 {
+			end := atomic.LoadInt64(&st.done)
+			if end == 0 {
+				end = now
+			}
+			s.Elapsed = time.Duration(end - started)
+		}
+		stats[i] = s
+	}
+	return stats
+}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	p := New(context.Background())
+	src := FromSlice(p, make([]int, 20), Buffer(20))
+	mapped := Map(src, func(ctx context.Context, x int) (int, error) {
+		return x, nil
+	}, Name("fast"), Workers(2), Buffer(5))
+	Sink(mapped, func(ctx context.Context, x int) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}, Name("slow"))
+
+	if stats := p.Stats(); len(stats) != 3 || stats[0].Elapsed != 0 {
+		t.Fatalf("unexpected stats before running: %+v", stats)
+	}
+	if err := p.Run(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	stats := p.Stats()
+	if stats[0].Name != "source-0" || stats[1].Name != "fast" || stats[2].Name != "slow" {
+		t.Errorf("unexpected stage names: %+v", stats)
+	}
+	if stats[0].Emitted != 20 || stats[1].Received != 20 || stats[1].Emitted != 20 || stats[2].Received != 20 {
+		t.Errorf("unexpected item counts: %+v", stats)
+	}
+	if stats[1].Workers != 2 {
+		t.Errorf("unexpected worker count: %+v", stats[1])
+	}
+	if stats[2].Busy < time.Millisecond*20 {
+		t.Errorf("sink busy time too low: %v", stats[2].Busy)
+	}
+	if u := stats[2].Utilization(); u <= 0 || u > 1 {
+		t.Errorf("sink utilization out of range: %v", u)
+	}
+	if stats[2].Throughput() <= 0 {
+		t.Errorf("sink throughput not positive: %v", stats[2].Throughput())
+	}
+	for _, s := range stats {
+		if s.Queued != 0 {
+			t.Errorf("items left queued after running: %+v", s)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/heyimalex/spara"
+)
+
+var ErrInvalidWindow = errors.New("pipeline: invalid window")
+
+// WindowSpec describes how a Window stage groups items. Use one of Tumbling,
+// Sliding, TumblingTime, or SlidingTime to create one.
+type WindowSpec struct {
+	size, step   int
+	length, hop  time.Duration
+	timeBased    bool
+	invalidValue bool
+}
+
+// Tumbling groups every n consecutive items into a window, with no overlap.
+func Tumbling(n int) WindowSpec {
+	return Sliding(n, n)
+}
+
+// Sliding emits a window of the last n items after every step items. With
+// step smaller than n, windows overlap.
+func Sliding(n, step int) WindowSpec {
+	return WindowSpec{size: n, step: step, invalidValue: n <= 0 || step <= 0}
+}
+
+// TumblingTime groups the items that arrive in each consecutive period of
+// length d into a window.
+func TumblingTime(d time.Duration) WindowSpec {
+	return SlidingTime(d, d)
+}
+
+// SlidingTime emits a window of the items that arrived during the last
+// length, every hop.
+func SlidingTime(length, hop time.Duration) WindowSpec {
+	return WindowSpec{length: length, hop: hop, timeBased: true, invalidValue: length <= 0 || hop <= 0}
+}
+
+// Window creates a stage that groups items of in into windows according to
+// spec, and calls fn on each window. Windows are handled concurrently across
+// the stage's workers, so with more than one worker results may be emitted
+// out of order; use OrderedMap downstream of a single-worker Window, or a
+// single worker here, if order matters.
+//
+// Time based windows use the time items arrive at the stage, and empty
+// windows are skipped rather than passed to fn. For both kinds, once in is
+// closed, the window that would have been emitted next is emitted early if
+// anything arrived since the last one, so it may be smaller than the rest.
+//
+// Windows are freshly allocated slices, so fn is free to keep them.
+func Window[T, R any](in *Stream[T], spec WindowSpec, fn func(ctx context.Context, window []T) (R, error), opts ...StageOption) *Stream[R] {
+	p := in.p
+	c := p.newStage("window", opts)
+	in.consume(c)
+	out := newStream[R](c)
+	if spec.invalidValue || (spec.size == 0 && !spec.timeBased) {
+		p.fail(invalid(ErrInvalidWindow, "spec", spec))
+		return out
+	}
+	if fn == nil {
+		p.fail(spara.ErrNilMappingFunction)
+		return out
+	}
+	p.addStage(c, 1, func(ctx context.Context) error {
+		windows := make(chan []T)
+		n := c.workers + 1
+		return spara.Do(ctx, n, func(ctx context.Context, i int) error {
+			if i == 0 {
+				defer close(windows)
+				w := &windower[T]{in: in, out: windows, clock: c.clock}
+				if spec.timeBased {
+					return w.byTime(ctx, spec.length, spec.hop)
+				}
+				return w.byCount(ctx, spec.size, spec.step)
+			}
+			for {
+				var window []T
+				var ok bool
+				select {
+				case window, ok = <-windows:
+					if !ok {
+						return nil
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				start := time.Now()
+				result, err := fn(ctx, window)
+				c.addBusy(start)
+				if err != nil {
+					return err
+				}
+				if err := out.send(ctx, result); err != nil {
+					return err
+				}
+			}
+		}, spara.Workers(n), spara.IgnoreDefaults())
+	}, out)
+	return out
+}
+
+type timestamped[T any] struct {
+	at   time.Time
+	item T
+}
+
+// windower is the goroutine of a Window stage that assigns items to windows.
+type windower[T any] struct {
+	in    *Stream[T]
+	out   chan []T
+	buf   []timestamped[T]
+	clock spara.Clock
+}
+
+func (w *windower[T]) emit(ctx context.Context, items []timestamped[T]) error {
+	window := make([]T, len(items))
+	for i := range items {
+		window[i] = items[i].item
+	}
+	select {
+	case w.out <- window:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *windower[T]) byCount(ctx context.Context, size, step int) error {
+	// buf always starts at the first item of the next window to emit. skip
+	// counts items that fall in the gap between windows when step > size,
+	// and fresh whether anything has arrived since the last window.
+	skip := 0
+	fresh := false
+	for {
+		item, ok, err := w.in.recv(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if fresh && len(w.buf) > 0 {
+				return w.emit(ctx, w.buf)
+			}
+			return nil
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		w.buf = append(w.buf, timestamped[T]{item: item})
+		fresh = true
+		if len(w.buf) < size {
+			continue
+		}
+		if err := w.emit(ctx, w.buf); err != nil {
+			return err
+		}
+		fresh = false
+		if step < size {
+			w.buf = append(w.buf[:0], w.buf[step:]...)
+		} else {
+			w.buf = w.buf[:0]
+			skip = step - size
+		}
+	}
+}
+
+func (w *windower[T]) byTime(ctx context.Context, length, hop time.Duration) error {
+	ticker := w.clock.NewTicker(hop)
+	defer ticker.Stop()
+	// last is the end of the last window emitted; the stage starting
+	// counts as one.
+	last := w.clock.Now()
+	// start returns where the window ending at now begins. Ticks can arrive
+	// late, so it's never later than where the previous tick expected the
+	// next window to begin; otherwise items could fall between windows.
+	start := func(now time.Time) time.Time {
+		from := now.Add(-length)
+		if next := last.Add(hop - length); next.Before(from) {
+			from = next
+		}
+		return from
+	}
+	for {
+		select {
+		case item, ok := <-w.in.ch:
+			if !ok {
+				now := w.clock.Now()
+				if len(w.buf) > 0 && !w.buf[len(w.buf)-1].at.Before(last) {
+					return w.emit(ctx, w.within(start(now), now.Add(1)))
+				}
+				return nil
+			}
+			// Counted once it's stamped, so a test that sees it counted
+			// knows when it arrived.
+			w.buf = append(w.buf, timestamped[T]{at: w.clock.Now(), item: item})
+			w.in.received()
+		case now := <-ticker.C():
+			if window := w.within(start(now), now); len(window) > 0 {
+				if err := w.emit(ctx, window); err != nil {
+					return err
+				}
+			}
+			last = now
+			// Anything that arrived before the start of the next window
+			// will never be needed again.
+			w.drop(now.Add(hop - length))
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// within returns the buffered items that arrived in [from, to).
+func (w *windower[T]) within(from, to time.Time) []timestamped[T] {
+	start := 0
+	for start < len(w.buf) && w.buf[start].at.Before(from) {
+		start++
+	}
+	end := start
+	for end < len(w.buf) && w.buf[end].at.Before(to) {
+		end++
+	}
+	return w.buf[start:end]
+}
+
+// drop discards buffered items that arrived before t.
+func (w *windower[T]) drop(t time.Time) {
+	n := 0
+	for n < len(w.buf) && w.buf[n].at.Before(t) {
+		n++
+	}
+	w.buf = append(w.buf[:0], w.buf[n:]...)
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/heyimalex/spara/sparatest"
+)
+
+func collectWindows(t *testing.T, inputs []int, spec WindowSpec) [][]int {
+	p := New(context.Background())
+	windows := Window(FromSlice(p, inputs), spec, func(ctx context.Context, w []int) ([]int, error) {
+		return w, nil
+	})
+	var results [][]int
+	Sink(windows, func(ctx context.Context, w []int) error {
+		results = append(results, w)
+		return nil
+	})
+	if err := p.Run(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return results
+}
+
+func TestTumblingWindow(t *testing.T) {
+	results := collectWindows(t, []int{1, 2, 3, 4, 5, 6, 7}, Tumbling(3))
+	expected := [][]int{{1, 2, 3}, {4, 5, 6}, {7}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("windows: %v != expected: %v", results, expected)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	results := collectWindows(t, []int{1, 2, 3, 4, 5, 6, 7}, Sliding(4, 2))
+	expected := [][]int{{1, 2, 3, 4}, {3, 4, 5, 6}, {5, 6, 7}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("windows: %v != expected: %v", results, expected)
+	}
+}
+
+func TestHoppingWindow(t *testing.T) {
+	results := collectWindows(t, []int{1, 2, 3, 4, 5, 6, 7}, Sliding(2, 3))
+	expected := [][]int{{1, 2}, {4, 5}, {7}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("windows: %v != expected: %v", results, expected)
+	}
+}
+
+func TestTumblingTimeWindow(t *testing.T) {
+	clock := sparatest.NewClock(time.Time{})
+	ch := make(chan int)
+	p := New(context.Background())
+	sums := Window(FromChan(p, ch), TumblingTime(time.Millisecond*30), func(ctx context.Context, w []int) (int, error) {
+		sum := 0
+		for _, x := range w {
+			sum += x
+		}
+		return sum, nil
+	}, Workers(2), Clock(clock), Name("window"))
+	results := make(chan int, 10)
+	Sink(sums, func(ctx context.Context, sum int) error {
+		results <- sum
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- p.Run() }()
+	clock.BlockUntil(t, 1)
+	// An item every 10ms puts three in each window; waiting for each
+	// window before moving on keeps them in order despite the workers.
+	var windows []int
+	for i := 1; i <= 10; i++ {
+		ch <- i
+		waitReceived(t, p, "window", int64(i))
+		if i == 10 {
+			break
+		}
+		clock.Advance(time.Millisecond * 10)
+		if i%3 == 0 {
+			windows = append(windows, <-results)
+		}
+	}
+	close(ch)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	windows = append(windows, <-results)
+	if expected := []int{6, 15, 24, 10}; !reflect.DeepEqual(windows, expected) {
+		t.Errorf("windows: %v != expected: %v", windows, expected)
+	}
+}
+
+func TestTumblingTimeWindowClock(t *testing.T) {
+	clock := sparatest.NewClock(time.Time{})
+	ch := make(chan int)
+	p := New(context.Background())
+	sums := Window(FromChan(p, ch), TumblingTime(time.Minute), func(ctx context.Context, w []int) (int, error) {
+		sum := 0
+		for _, x := range w {
+			sum += x
+		}
+		return sum, nil
+	}, Clock(clock), Name("window"))
+	results := make(chan int, 10)
+	Sink(sums, func(ctx context.Context, sum int) error {
+		results <- sum
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- p.Run() }()
+	clock.BlockUntil(t, 1)
+	ch <- 1
+	ch <- 2
+	waitReceived(t, p, "window", 2)
+	clock.Advance(time.Minute)
+	if sum := <-results; sum != 3 {
+		t.Errorf("unexpected first window: %d", sum)
+	}
+	// A window with nothing in it isn't emitted.
+	clock.Advance(time.Minute)
+	ch <- 3
+	waitReceived(t, p, "window", 3)
+	clock.Advance(30 * time.Second)
+	ch <- 4
+	waitReceived(t, p, "window", 4)
+	clock.Advance(30 * time.Second)
+	if sum := <-results; sum != 7 {
+		t.Errorf("unexpected third window: %d", sum)
+	}
+	close(ch)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestInvalidWindow(t *testing.T) {
+	p := New(context.Background())
+	Sink(Window(FromSlice(p, []int{1}), Sliding(0, 1), func(ctx context.Context, w []int) (int, error) {
+		return 0, nil
+	}), func(ctx context.Context, x int) error { return nil })
+	if err := p.Run(); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("expected an invalid window error: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package spara
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Plan describes how a run would be carried out, as worked out by DryRun.
+type Plan struct {
+	Iterations int
+	// Workers is the number of workers the run would start, which is
+	// limited by the number of iterations, and by Sequential.
+	Workers int
+	// Scheduler is the name of the scheduler: "dynamic", "chunked",
+	// "static", "work-stealing", or "deterministic".
+	Scheduler string
+	// ChunkSize is how many indices a worker claims at a time, for the
+	// schedulers that hand them out as they're asked for, and Chunks is how
+	// many claims that makes. Both are zero for the schedulers that
+	// partition the indices up front.
+	ChunkSize int
+	Chunks    int
+	// Partitions is the range of indices each worker starts out with, for
+	// the schedulers that partition the indices up front, in worker order.
+	Partitions []Partition
+	// Attempts is how many times each item may be tried, and Backoffs is
+	// how long the run waits before each retry.
+	Attempts int
+	Backoffs []time.Duration
+	// Timeout and ItemTimeout are the limits on the whole run and on each
+	// call to the mapping function, or zero for none.
+	Timeout     time.Duration
+	ItemTimeout time.Duration
+	ErrorPolicy ErrorPolicy
+	// Cancelable is false if NoCancel was passed, in which case items in
+	// flight aren't canceled when the run stops.
+	Cancelable bool
+}
+
+// Partition is the range of indices [Lo, Hi).
+type Partition struct {
+	Lo, Hi int
+}
+
+// DryRun validates opts as Do would, and works out how a run over iterations
+// indices would be carried out with them, without calling anything. It's
+// for debugging configuration driven jobs, where what the settings actually
+// add up to isn't obvious from any one place:
+//
+//	plan, err := spara.DryRun(len(items), cfg.Options()...)
+//	log.Print(plan)
+//
+// Trackers passed to DryRun aren't used up.
+func DryRun(iterations int, opts ...Option) (Plan, error) {
+	if iterations < 0 {
+		return Plan{}, invalid(ErrInvalidIterations, "iterations", iterations)
+	}
+	c := newConfig(opts)
+	if err := c.check(); err != nil {
+		return Plan{}, err
+	}
+	return c.plan(iterations), nil
+}
+
+// Plan is DryRun with the config's options.
+func (c Config) Plan(iterations int) (Plan, error) {
+	if err := c.Validate(); err != nil {
+		return Plan{}, err
+	}
+	return DryRun(iterations, c.Options()...)
+}
+
+// Plan is DryRun with the runner's options, followed by opts.
+func (r *Runner) Plan(iterations int, opts ...Option) (Plan, error) {
+	all := make([]Option, 0, len(r.opts)+len(opts))
+	all = append(all, r.opts...)
+	all = append(all, opts...)
+	return DryRun(iterations, all...)
+}
+
+// plan works out the plan for a run with an already checked config. It
+// follows what run does, and has to be kept in step with it.
+func (c *config) plan(iterations int) Plan {
+	workers := c.limitWorkers(min(c.workers, iterations))
+	p := Plan{
+		Iterations:  iterations,
+		Workers:     workers,
+		Attempts:    1,
+		ErrorPolicy: StopOnError,
+		Cancelable:  c.cancelable(),
+	}
+	switch s := c.scheduler.(type) {
+	case nil, dynamicScheduler:
+		p.Scheduler = "dynamic"
+		p.ChunkSize = 1
+	case chunkedScheduler:
+		p.Scheduler = "chunked"
+		p.ChunkSize = s.size
+	case staticScheduler:
+		p.Scheduler = "static"
+	case workStealingScheduler:
+		p.Scheduler = "work-stealing"
+	case deterministicScheduler:
+		p.Scheduler = "deterministic"
+		p.ChunkSize = 1
+	}
+	if p.ChunkSize > 0 {
+		p.Chunks = (iterations + p.ChunkSize - 1) / p.ChunkSize
+	} else {
+		p.Partitions = make([]Partition, workers)
+		for i := range p.Partitions {
+			p.Partitions[i] = Partition{i * iterations / workers, (i + 1) * iterations / workers}
+		}
+	}
+	if c.retrySet && c.attempts > 1 {
+		p.Attempts = c.attempts
+		p.Backoffs = make([]time.Duration, c.attempts-1)
+		if c.backoff != nil {
+			for i := range p.Backoffs {
+				p.Backoffs[i] = c.backoff(i + 1)
+			}
+		}
+	}
+	if c.timeoutSet {
+		p.Timeout = c.timeout
+	}
+	if c.itemTimeoutSet {
+		p.ItemTimeout = c.itemTimeout
+	}
+	if c.collecting() {
+		p.ErrorPolicy = CollectAllErrors
+	}
+	return p
+}
+
+// String describes the plan on a single line, for logging.
+func (p Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d iterations on %d workers, %s scheduler", p.Iterations, p.Workers, p.Scheduler)
+	if p.ChunkSize > 0 {
+		fmt.Fprintf(&b, " (%d chunks of %d)", p.Chunks, p.ChunkSize)
+	} else if len(p.Partitions) > 0 {
+		b.WriteString(" (")
+		for i, part := range p.Partitions {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "[%d, %d)", part.Lo, part.Hi)
+		}
+		b.WriteString(")")
+	}
+	if p.Attempts > 1 {
+		fmt.Fprintf(&b, ", %d attempts (backoff %v)", p.Attempts, p.Backoffs)
+	}
+	if p.Timeout > 0 {
+		fmt.Fprintf(&b, ", timeout %v", p.Timeout)
+	}
+	if p.ItemTimeout > 0 {
+		fmt.Fprintf(&b, ", item timeout %v", p.ItemTimeout)
+	}
+	fmt.Fprintf(&b, ", error policy %s", p.ErrorPolicy)
+	if !p.Cancelable {
+		b.WriteString(", no cancel")
+	}
+	return b.String()
+}
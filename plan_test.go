@@ -0,0 +1,70 @@
+package spara
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDryRun(t *testing.T) {
+	p, err := DryRun(10, Workers(4), WithScheduler(Chunked(3)), Retry(3, ExponentialBackoff(time.Second, 0)), CollectErrors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Plan{
+		Iterations:  10,
+		Workers:     4,
+		Scheduler:   "chunked",
+		ChunkSize:   3,
+		Chunks:      4,
+		Attempts:    3,
+		Backoffs:    []time.Duration{time.Second, 2 * time.Second},
+		ErrorPolicy: CollectAllErrors,
+		Cancelable:  true,
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("unexpected plan:\n%+v\n%+v", p, expected)
+	}
+	const s = "10 iterations on 4 workers, chunked scheduler (4 chunks of 3), 3 attempts (backoff [1s 2s]), error policy collect"
+	if p.String() != s {
+		t.Errorf("unexpected description: %q", p.String())
+	}
+
+	p, err = DryRun(10, Workers(3), WithScheduler(Static()), NoCancel())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Partition{{0, 3}, {3, 6}, {6, 10}}; !reflect.DeepEqual(p.Partitions, want) {
+		t.Errorf("unexpected partitions: %v", p.Partitions)
+	}
+	if p.Cancelable {
+		t.Errorf("expected the plan to be uncancelable")
+	}
+
+	// Workers are limited by the iterations.
+	if p, _ := DryRun(2, Workers(8)); p.Workers != 2 || p.Scheduler != "dynamic" {
+		t.Errorf("expected 2 dynamic workers: %v", p)
+	}
+
+	if _, err := DryRun(10, WithScheduler(Chunked(0))); !errors.Is(err, ErrInvalidChunkSize) {
+		t.Errorf("expected ErrInvalidChunkSize: %v", err)
+	}
+	if _, err := DryRun(-1); !errors.Is(err, ErrInvalidIterations) {
+		t.Errorf("expected ErrInvalidIterations: %v", err)
+	}
+
+	// Trackers aren't claimed.
+	tracker := NewTracker(time.Second)
+	if _, err := DryRun(10, Track(tracker)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.claim(); err != nil {
+		t.Errorf("expected the tracker to still be usable: %v", err)
+	}
+
+	p, err = Config{Workers: 2, ItemTimeout: Duration(time.Second)}.Plan(4)
+	if err != nil || p.Workers != 2 || p.ItemTimeout != time.Second {
+		t.Errorf("unexpected plan for the config: %v, %v", p, err)
+	}
+}
@@ -0,0 +1,356 @@
+package spara
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pool is a set of long-lived goroutines that runs can borrow instead of
+// spawning fresh ones. Goroutines are cheap, but they aren't free; programs
+// that call Run in a tight loop over small inputs can spend a surprising
+// amount of time creating and scheduling workers that only live for a few
+// microseconds.
+//
+// A Pool never limits concurrency. When a run needs a worker and every pooled
+// goroutine is busy, the worker simply runs on a new goroutine as it would
+// without the pool. This means a mapping function is free to start nested
+// runs on the same pool without any risk of deadlocking on it.
+type Pool struct {
+	processed int64 // updated atomically
+	failed    int64 // updated atomically
+	idle      int64 // updated atomically
+
+	// Work is handed to parked goroutines through a lock-free ring, with
+	// the wake channel only there to let them block while they wait. See
+	// spawn for how the two are kept in step.
+	work    *ring[func()]
+	wake    chan struct{}
+	spin    int
+	quit    chan struct{}
+	closing sync.Once
+	wg      sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[*job]struct{}
+}
+
+// PoolOption configures a Pool.
+type PoolOption func(*Pool)
+
+// Spin makes the pool's goroutines poll for new work up to n times, yielding
+// the processor in between, before they block. When work arrives in quick
+// succession, as with tight loops of runs over tiny inputs, a goroutine that's
+// still spinning picks it up without the round trip through the scheduler
+// that waking a blocked one costs, which can be more than the work itself.
+// The spinning is adaptive: each goroutine doubles its budget, up to n,
+// whenever spinning pays off, and halves it whenever it doesn't, so a pool
+// that's mostly idle quickly stops burning CPU on it. Spinning is off by
+// default.
+func Spin(n int) PoolOption {
+	return func(p *Pool) {
+		p.spin = n
+	}
+}
+
+// NewPool starts a Pool with size parked goroutines.
+func NewPool(size int, opts ...PoolOption) (*Pool, error) {
+	if size <= 0 {
+		return nil, invalid(ErrInvalidWorkers, "size", size)
+	}
+	p := &Pool{
+		work: newRing[func()](size),
+		wake: make(chan struct{}, size),
+		quit: make(chan struct{}),
+		jobs: make(map[*job]struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.loop()
+	}
+	return p, nil
+}
+
+func (p *Pool) loop() {
+	defer p.wg.Done()
+	budget := p.spin
+	for {
+		atomic.AddInt64(&p.idle, 1)
+		if p.spin > 0 {
+			if p.trySpin(budget) {
+				budget = min(budget*2, p.spin)
+				p.run()
+				continue
+			}
+			budget = max(budget/2, 1)
+		}
+		select {
+		case <-p.wake:
+			p.run()
+		case <-p.quit:
+			p.exit()
+			return
+		}
+	}
+}
+
+// trySpin polls for a wake-up up to n times, reporting whether it got one.
+// It gives up early if the pool is closing.
+func (p *Pool) trySpin(n int) bool {
+	for i := 0; i < n; i++ {
+		select {
+		case <-p.wake:
+			return true
+		case <-p.quit:
+			return false
+		default:
+			runtime.Gosched()
+		}
+	}
+	return false
+}
+
+// run pops and runs a function after a wake-up has been received. Every
+// wake-up is sent after a completed push, but the ring hands items out in the
+// order their slots were claimed, not the order they were filled in: if one
+// spawn has claimed the oldest slot and not yet written to it when another
+// spawn's wake-up arrives, pop comes back empty even though there's work for
+// this goroutine. The laggard is in the middle of a couple of stores, so it's
+// enough to yield and try again.
+func (p *Pool) run() {
+	for {
+		if f, ok := p.work.pop(); ok {
+			f()
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+// exit takes a goroutine that's shutting down out of the idle count. If the
+// count is already zero, a spawn has claimed this goroutine's slot and is
+// about to hand it some work, so that has to be run first.
+func (p *Pool) exit() {
+	for {
+		n := atomic.LoadInt64(&p.idle)
+		if n == 0 {
+			<-p.wake
+			p.run()
+			return
+		}
+		if atomic.CompareAndSwapInt64(&p.idle, n, n-1) {
+			return
+		}
+	}
+}
+
+// Close stops the pool's goroutines once they finish whatever they're
+// currently running, and waits for them to exit. Runs using the pool after it
+// has been closed still work; they just stop getting any benefit from it.
+func (p *Pool) Close() {
+	p.closing.Do(func() {
+		close(p.quit)
+	})
+	p.wg.Wait()
+}
+
+// Go runs f on one of the pool's parked goroutines if one is available, and
+// on a new goroutine otherwise, so it can stand in for a go statement in code
+// that wants to share the pool with its runs. Like a go statement, it never
+// blocks. Calling Go on a nil pool always uses a new goroutine.
+func (p *Pool) Go(f func()) {
+	p.spawn(f)
+}
+
+// spawn runs f on a parked goroutine if one is available, and on a new
+// goroutine otherwise. Calling spawn on a nil pool always uses a new
+// goroutine.
+//
+// Work is only ever pushed after claiming one of the idle goroutines, by
+// decrementing the idle count, so there's always a goroutine committed to
+// running it; nothing ever sits in the ring waiting for a goroutine to free
+// up, which is what lets nested runs on the same pool never deadlock. That
+// also means the ring never holds more items than there are goroutines, but
+// push can still report it full: a slot is only reused once the consumer from
+// a lap ago has finished with it, and a consumer that's slow to do so holds
+// up its slot while the ones around it keep moving. Like the empty pop in
+// run, that only lasts as long as a couple of stores on another goroutine,
+// so spawn yields and tries again.
+func (p *Pool) spawn(f func()) {
+	if p == nil || !p.claimIdle() {
+		go f()
+		return
+	}
+	for !p.work.push(f) {
+		runtime.Gosched()
+	}
+	p.wake <- struct{}{}
+}
+
+// claimIdle takes one goroutine out of the idle count, if there are any.
+func (p *Pool) claimIdle() bool {
+	for {
+		n := atomic.LoadInt64(&p.idle)
+		if n == 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&p.idle, n, n-1) {
+			return true
+		}
+	}
+}
+
+var defaultPool atomic.Value // *Pool
+
+// SetDefaultPool makes Run, RunWithContext, and any other call that doesn't
+// specify a pool of its own execute on p. Passing nil restores the default
+// behavior of spawning fresh goroutines for every call.
+//
+// This is opt-in because it changes where work runs in ways that can be
+// surprising, eg a slow mapping function will now hold on to a goroutine that
+// unrelated runs were hoping to reuse.
+func SetDefaultPool(p *Pool) {
+	defaultPool.Store(p)
+}
+
+// getDefaultPool returns the pool set with SetDefaultPool, or nil.
+func getDefaultPool() *Pool {
+	p, _ := defaultPool.Load().(*Pool)
+	return p
+}
+
+// WithPool makes the run execute on p, overriding any default pool.
+func WithPool(p *Pool) Option {
+	return func(c *config) {
+		c.pool = p
+		c.poolSet = true
+	}
+}
+
+// getPool returns the pool a run should execute on, or nil if it should use
+// fresh goroutines.
+func (c *config) getPool() *Pool {
+	if c != nil && c.poolSet {
+		return c.pool
+	}
+	return getDefaultPool()
+}
+
+// JobInfo describes an item that is currently being processed by a run
+// executing on a Pool.
+type JobInfo struct {
+	// Name is the item's name as given by the Names option, or empty.
+	Name string
+	// Index is the index the mapping function was called with.
+	Index int
+	// Worker identifies the worker within the run that is processing the
+	// item, in the range [0, workers).
+	Worker int
+	// Started is when the mapping function was called.
+	Started time.Time
+}
+
+// Jobs returns every item currently being processed on the pool, oldest
+// first. It's meant for admin and debug endpoints that want to show what a
+// service is working on right now, and is a snapshot; items may well have
+// finished by the time it returns.
+func (p *Pool) Jobs() []JobInfo {
+	p.mu.Lock()
+	jobs := make([]JobInfo, 0, len(p.jobs))
+	names := make([]func(int) string, 0, len(p.jobs))
+	for j := range p.jobs {
+		j.mu.Lock()
+		if j.busy {
+			jobs = append(jobs, JobInfo{
+				Index:   j.index,
+				Worker:  j.worker,
+				Started: j.started,
+			})
+			var name func(int) string
+			if j.c != nil {
+				name = j.c.name
+			}
+			names = append(names, name)
+		}
+		j.mu.Unlock()
+	}
+	p.mu.Unlock()
+	// The names come from the caller's code, which could be slow or even
+	// use the pool itself, so they're only looked up once the locks are
+	// released.
+	for i, name := range names {
+		if name != nil {
+			jobs[i].Name = name(jobs[i].Index)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].Started.Before(jobs[b].Started)
+	})
+	return jobs
+}
+
+// job tracks what a single worker of a run on a pool is doing. Workers
+// register once when they start, and then just update their job in place for
+// each item, so the pool's lock is only taken twice per worker.
+type job struct {
+	p      *Pool
+	c      *config
+	worker int
+
+	mu      sync.Mutex
+	busy    bool
+	index   int
+	started time.Time
+}
+
+// track registers a worker with the pool. It returns nil when called on a
+// nil pool, and all of the job methods are no-ops on a nil job.
+func (p *Pool) track(c *config, worker int) *job {
+	if p == nil {
+		return nil
+	}
+	j := &job{p: p, c: c, worker: worker}
+	p.mu.Lock()
+	p.jobs[j] = struct{}{}
+	p.mu.Unlock()
+	return j
+}
+
+func (p *Pool) untrack(j *job) {
+	if j == nil {
+		return
+	}
+	p.mu.Lock()
+	delete(p.jobs, j)
+	p.mu.Unlock()
+}
+
+func (j *job) begin(index int) {
+	if j == nil {
+		return
+	}
+	now := j.c.getClock().Now()
+	j.mu.Lock()
+	j.busy = true
+	j.index = index
+	j.started = now
+	j.mu.Unlock()
+}
+
+func (j *job) end(err error) {
+	if j == nil {
+		return
+	}
+	atomic.AddInt64(&j.p.processed, 1)
+	if err != nil {
+		atomic.AddInt64(&j.p.failed, 1)
+	}
+	j.mu.Lock()
+	j.busy = false
+	j.mu.Unlock()
+}
@@ -0,0 +1,263 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	p, err := NewPool(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 100; i++ {
+		counts := make([]int32, 50)
+		err := Do(context.Background(), len(counts), func(ctx context.Context, idx int) error {
+			counts[idx]++
+			return nil
+		}, Workers(8), WithPool(p))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for idx, count := range counts {
+			if count != 1 {
+				t.Fatalf("function called %d times with index %d", count, idx)
+			}
+		}
+	}
+}
+
+// Nested runs on the same pool must not deadlock, even when the outer run
+// occupies every pooled goroutine.
+func TestPoolNested(t *testing.T) {
+	p, err := NewPool(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer p.Close()
+
+	err = Do(context.Background(), 4, func(ctx context.Context, i int) error {
+		return Do(ctx, 4, func(ctx context.Context, j int) error {
+			return nil
+		}, Workers(4), WithPool(p))
+	}, Workers(4), WithPool(p))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestDefaultPool(t *testing.T) {
+	p, err := NewPool(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	SetDefaultPool(p)
+	defer func() {
+		SetDefaultPool(nil)
+		p.Close()
+	}()
+
+	expectedError := errors.New("")
+	err = Run(4, 20, func(i int) error {
+		if i == 10 {
+			return expectedError
+		}
+		return nil
+	})
+	if err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
+
+func TestPoolClosed(t *testing.T) {
+	p, err := NewPool(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	p.Close()
+	if err := Do(context.Background(), 10, func(ctx context.Context, i int) error { return nil }, WithPool(p)); err != nil {
+		t.Errorf("expected runs on a closed pool to succeed: %v", err)
+	}
+}
+
+func TestNewPoolInvalidSize(t *testing.T) {
+	if _, err := NewPool(0); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected calling NewPool with zero size to fail: %v", err)
+	}
+}
+
+func TestPoolJobs(t *testing.T) {
+	p, err := NewPool(4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer p.Close()
+
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Do(context.Background(), 3, func(ctx context.Context, i int) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}, Workers(3), WithPool(p), Names(func(i int) string {
+			return fmt.Sprintf("job-%d", i)
+		}))
+	}()
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+
+	jobs := p.Jobs()
+	if len(jobs) != 3 {
+		t.Fatalf("expected 3 in-flight jobs: %+v", jobs)
+	}
+	seen := make(map[string]bool)
+	for _, job := range jobs {
+		if job.Name != fmt.Sprintf("job-%d", job.Index) {
+			t.Errorf("unexpected job name: %+v", job)
+		}
+		if job.Started.IsZero() {
+			t.Errorf("job is missing its start time: %+v", job)
+		}
+		seen[job.Name] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected distinct jobs: %+v", jobs)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if jobs := p.Jobs(); len(jobs) != 0 {
+		t.Errorf("expected no in-flight jobs after the run: %+v", jobs)
+	}
+}
+
+// TestPoolJobsNamesUsePool checks that Jobs doesn't hold the pool's locks
+// while it calls back into the caller's Names function, which is free to use
+// the pool itself.
+func TestPoolJobsNamesUsePool(t *testing.T) {
+	p, err := NewPool(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	noop := func(ctx context.Context, i int) error { return nil }
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Do(context.Background(), 1, func(ctx context.Context, i int) error {
+			close(started)
+			<-release
+			return nil
+		}, Workers(1), WithPool(p), Names(func(i int) string {
+			if err := Do(context.Background(), 1, noop, WithPool(p)); err != nil {
+				t.Error(err)
+			}
+			return "job"
+		}))
+	}()
+	<-started
+	if jobs := p.Jobs(); len(jobs) != 1 || jobs[0].Name != "job" {
+		t.Errorf("unexpected jobs: %+v", jobs)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolCloseWhileSpawning(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		p, _ := NewPool(4)
+		done := make(chan struct{}, 8)
+		for j := 0; j < 8; j++ {
+			go p.spawn(func() { done <- struct{}{} })
+		}
+		p.Close()
+		for j := 0; j < 8; j++ {
+			<-done
+		}
+	}
+}
+
+// Many goroutines submitting at once is the case the lock-free ring is for.
+func BenchmarkPoolSpawn(b *testing.B) {
+	p, _ := NewPool(64)
+	defer p.Close()
+	b.RunParallel(func(pb *testing.PB) {
+		done := make(chan struct{})
+		f := func() { done <- struct{}{} }
+		for pb.Next() {
+			p.spawn(f)
+			<-done
+		}
+	})
+}
+
+func TestPoolSpin(t *testing.T) {
+	p, err := NewPool(4, Spin(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		err := Do(context.Background(), 100, func(ctx context.Context, i int) error {
+			return nil
+		}, Workers(4), WithPool(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		p.spawn(func() { close(done) })
+		<-done
+	}
+	p.Close()
+}
+
+func TestPoolGo(t *testing.T) {
+	p, err := NewPool(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	var nilPool *Pool
+	done := make(chan struct{})
+	for _, pool := range []*Pool{p, nilPool} {
+		pool.Go(func() { done <- struct{}{} })
+		<-done
+	}
+}
+
+// TestPoolConcurrentSpawns has many goroutines hand work to the pool at once,
+// so that a wake-up can arrive before the slot it corresponds to has been
+// filled in.
+func TestPoolConcurrentSpawns(t *testing.T) {
+	p, err := NewPool(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				done := make(chan struct{})
+				p.Go(func() { close(done) })
+				<-done
+			}
+		}()
+	}
+	wg.Wait()
+}
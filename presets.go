@@ -0,0 +1,38 @@
+package spara
+
+import "runtime"
+
+// CPUBound configures a run for items that keep a processor busy the whole
+// time they run, like hashing, encoding, or image processing. It uses one
+// worker per processor, since more just take turns on the same processors,
+// and the work-stealing scheduler, which copes best with items of uneven
+// size. It also sets NoCancel, on the basis that items that never block
+// never look at their context either.
+//
+// Options after CPUBound override whatever it set, eg CPUBound(), Workers(2)
+// keeps everything but the worker count.
+func CPUBound() Option {
+	return func(c *config) {
+		c.workers = runtime.GOMAXPROCS(0)
+		c.scheduler = WorkStealing()
+		c.noCancel = true
+	}
+}
+
+// IOBound configures a run for items that spend most of their time waiting,
+// on the network, a disk, or another service. The number of workers has
+// nothing to do with the number of processors then; it's however many
+// requests should be in flight at once, which only the caller can know, so
+// it has to be passed explicitly. Indices are handed out one at a time, so
+// that a slow response never holds up anything queued behind it, and the
+// context is canceled on the first error, so requests still in flight are
+// abandoned rather than waited for.
+//
+// As with CPUBound, options after IOBound override whatever it set.
+func IOBound(maxInFlight int) Option {
+	return func(c *config) {
+		c.workers = maxInFlight
+		c.scheduler = Dynamic()
+		c.noCancel = false
+	}
+}
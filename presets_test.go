@@ -0,0 +1,45 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPresets(t *testing.T) {
+	c := newConfig([]Option{CPUBound()})
+	if c.workers != runtime.GOMAXPROCS(0) || !c.noCancel {
+		t.Errorf("unexpected CPUBound config: %+v", c)
+	}
+	if _, ok := c.scheduler.(workStealingScheduler); !ok {
+		t.Errorf("expected CPUBound to steal work: %T", c.scheduler)
+	}
+	c = newConfig([]Option{CPUBound(), Workers(2)})
+	if c.workers != 2 {
+		t.Errorf("expected later options to override CPUBound: %d", c.workers)
+	}
+	c = newConfig([]Option{CPUBound(), IOBound(64)})
+	if c.workers != 64 || c.noCancel {
+		t.Errorf("unexpected IOBound config: %+v", c)
+	}
+
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 10, noop, IOBound(0)); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected IOBound(0) to fail: %v", err)
+	}
+
+	// With many more workers than processors, waiting items overlap.
+	start := time.Now()
+	err := Do(context.Background(), 50, func(ctx context.Context, i int) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}, IOBound(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the sleeps to overlap: %v", elapsed)
+	}
+}
@@ -0,0 +1,73 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"strconv"
+)
+
+var (
+	ErrInvalidLabels       = errors.New("spara: labels must be key-value pairs")
+	ErrInvalidIndexBuckets = errors.New("spara: invalid index bucket size")
+)
+
+// ProfileLabels attaches pprof labels to the run's worker goroutines, so CPU
+// and goroutine profiles attribute time to the run rather than to an
+// anonymous closure inside spara. keyvals alternates keys and values, as with
+// pprof.Labels. Every worker also gets a "spara.worker" label with its number,
+// and a "spara.run" label with the run's name if it was given one with
+// Named.
+//
+// The labels are also on the context passed to the mapping function, so any
+// labels it adds with pprof.Do are nested under the run's.
+func ProfileLabels(keyvals ...string) Option {
+	return func(c *config) {
+		c.labels = append(c.labels, keyvals...)
+		c.labelsSet = true
+	}
+}
+
+// ProfileIndexBuckets additionally labels each item with the range of
+// indices it falls in, as "spara.index", eg "1000-1999" for a size of 1000.
+// That's enough to spot which part of an input is expensive without a
+// separate label per item. Relabeling costs a small allocation per item, so
+// it's separate from ProfileLabels.
+func ProfileIndexBuckets(size int) Option {
+	return func(c *config) {
+		c.indexBuckets = size
+		c.labelsSet = true
+	}
+}
+
+// labelWorker runs f with the worker's profiling labels applied, if any.
+func (c *config) labelWorker(ctx context.Context, worker int, f func(ctx context.Context)) {
+	if c == nil || !c.labelsSet {
+		f(ctx)
+		return
+	}
+	keyvals := append(c.labels[:len(c.labels):len(c.labels)], "spara.worker", strconv.Itoa(worker))
+	if c.runName != "" {
+		keyvals = append(keyvals, "spara.run", c.runName)
+	}
+	pprof.Do(ctx, pprof.Labels(keyvals...), f)
+}
+
+// labelItems returns middleware that labels each call with its index bucket,
+// or nil.
+func (c *config) labelItems() Middleware {
+	if c == nil || c.indexBuckets == 0 {
+		return nil
+	}
+	size := c.indexBuckets
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) (err error) {
+			lo := index / size * size
+			bucket := strconv.Itoa(lo) + "-" + strconv.Itoa(lo+size-1)
+			pprof.Do(ctx, pprof.Labels("spara.index", bucket), func(ctx context.Context) {
+				err = fn(ctx, index)
+			})
+			return err
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"sync"
+	"testing"
+)
+
+func TestProfileLabels(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	err := Do(context.Background(), 20, func(ctx context.Context, i int) error {
+		run, _ := pprof.Label(ctx, "run")
+		worker, _ := pprof.Label(ctx, "spara.worker")
+		index, _ := pprof.Label(ctx, "spara.index")
+		if run != "thumbnails" || worker == "" {
+			t.Errorf("missing labels for item %d: run=%q worker=%q", i, run, worker)
+		}
+		mu.Lock()
+		seen[index] = true
+		mu.Unlock()
+		return nil
+	}, Workers(3), ProfileLabels("run", "thumbnails"), ProfileIndexBuckets(10))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(seen) != 2 || !seen["0-9"] || !seen["10-19"] {
+		t.Errorf("unexpected index buckets: %v", seen)
+	}
+
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 1, noop, ProfileLabels("odd")); !errors.Is(err, ErrInvalidLabels) {
+		t.Errorf("expected ErrInvalidLabels: %v", err)
+	}
+	if err := Do(context.Background(), 1, noop, ProfileIndexBuckets(-1)); !errors.Is(err, ErrInvalidIndexBuckets) {
+		t.Errorf("expected ErrInvalidIndexBuckets: %v", err)
+	}
+}
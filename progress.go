@@ -0,0 +1,74 @@
+package spara
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is the minimum time between calls to a WithProgress
+// callback. Ten updates a second is plenty for anything a human is watching.
+const progressInterval = 100 * time.Millisecond
+
+// WithProgress calls fn as items complete, with the number completed so far
+// and the total number of iterations. Calls are rate limited to a handful a
+// second, so fn can redraw a progress bar without slowing down runs with many
+// cheap items, and there's always a final call before the run returns with
+// the number of items that actually completed, which is less than total if
+// the run stopped early. Calls never overlap, and done never goes backwards.
+func WithProgress(fn func(done, total int)) Option {
+	return func(c *config) {
+		if fn != nil {
+			c.addObserver(&progressObserver{fn: fn, c: c})
+		}
+	}
+}
+
+type progressObserver struct {
+	fn func(done, total int)
+	c  *config
+
+	done  int64 // updated atomically
+	total int
+
+	// mu serializes calls to fn; last and reported are only touched while
+	// holding it.
+	mu       sync.Mutex
+	last     time.Time
+	reported int
+}
+
+func (o *progressObserver) runStart(ctx context.Context, total int, start time.Time) {
+	atomic.StoreInt64(&o.done, 0)
+	o.total = total
+	o.last = start
+	o.reported = 0
+}
+
+func (o *progressObserver) itemDone(worker, index int, elapsed time.Duration, err error) {
+	atomic.AddInt64(&o.done, 1)
+	// If another worker is already reporting, it can report this item too.
+	if !o.mu.TryLock() {
+		return
+	}
+	defer o.mu.Unlock()
+	if now := o.c.getClock().Now(); now.Sub(o.last) >= progressInterval {
+		o.last = now
+		o.report()
+	}
+}
+
+func (o *progressObserver) runEnd(err error, elapsed time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if int(atomic.LoadInt64(&o.done)) != o.reported || o.total == 0 {
+		o.report()
+	}
+}
+
+// report calls fn with the current count. o.mu must be held.
+func (o *progressObserver) report() {
+	o.reported = int(atomic.LoadInt64(&o.done))
+	o.fn(o.reported, o.total)
+}
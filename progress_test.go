@@ -0,0 +1,50 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithProgress(t *testing.T) {
+	var calls, last int
+	err := Do(context.Background(), 50, func(ctx context.Context, i int) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}, Workers(4), WithProgress(func(done, total int) {
+		calls++
+		if total != 50 || done < last {
+			t.Errorf("unexpected progress: %d/%d after %d", done, total, last)
+		}
+		last = done
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if last != 50 {
+		t.Errorf("expected a final report of 50: %d", last)
+	}
+	// 50 items at 5ms across 4 workers is ~65ms; with rate limiting that
+	// shouldn't be anywhere near a call per item.
+	if calls >= 50 {
+		t.Errorf("expected progress to be rate limited: %d calls", calls)
+	}
+}
+
+func TestWithProgressEarlyStop(t *testing.T) {
+	var done, total int
+	expectedError := errors.New("boom")
+	err := Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		if i == 4 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), WithProgress(func(d, t int) { done, total = d, t }))
+	if err != expectedError {
+		t.Fatalf("expected the error: %v", err)
+	}
+	if done != 5 || total != 10 {
+		t.Errorf("expected a final report of 5/10: %d/%d", done, total)
+	}
+}
@@ -0,0 +1,41 @@
+package spara
+
+import "context"
+
+// RateLimiter paces the calls to the mapping function. Wait blocks until the
+// next call is allowed, or returns an error if ctx is done first. A
+// *rate.Limiter from golang.org/x/time/rate implements it.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// WithRateLimit makes every call to the mapping function wait on l first.
+// That's what wrapping the mapping function in a call to l.Wait would do,
+// with a few differences that make it behave better with the rest of the
+// options: every retry waits again, since it's another call to whatever the
+// limit is protecting; the wait isn't counted against ItemTimeout, so a busy
+// limiter doesn't eat into the item's own time; and the wait is on the run's
+// context, so a failed or canceled run stops waiting straight away. Passing
+// nil removes the limit.
+func WithRateLimit(l RateLimiter) Option {
+	return func(c *config) {
+		c.rate = l
+	}
+}
+
+// withRateLimit returns middleware that makes every call wait on the rate
+// limiter, or nil if there isn't one.
+func (c *config) withRateLimit() Middleware {
+	if c == nil || c.rate == nil {
+		return nil
+	}
+	l := c.rate
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			if err := l.Wait(ctx); err != nil {
+				return err
+			}
+			return fn(ctx, index)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRate counts its waits, and takes delay over each.
+type fakeRate struct {
+	waits int32
+	delay time.Duration
+}
+
+func (r *fakeRate) Wait(ctx context.Context) error {
+	atomic.AddInt32(&r.waits, 1)
+	select {
+	case <-time.After(r.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	// Every attempt waits, retries included.
+	r := &fakeRate{}
+	var calls int32
+	err := Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			return errors.New("flaky")
+		}
+		return nil
+	}, Workers(1), Retry(2, nil), WithRateLimit(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.waits != calls || calls != 10 {
+		t.Errorf("expected a wait per call: %d waits, %d calls", r.waits, calls)
+	}
+
+	// The wait doesn't count against the item's timeout.
+	r = &fakeRate{delay: 20 * time.Millisecond}
+	err = Do(context.Background(), 2, func(ctx context.Context, i int) error {
+		return ctx.Err()
+	}, Workers(2), ItemTimeout(10*time.Millisecond), WithRateLimit(r))
+	if err != nil {
+		t.Errorf("expected the items to have their full timeout: %v", err)
+	}
+
+	// Waiting stops with the run.
+	r = &fakeRate{delay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = Do(ctx, 2, func(ctx context.Context, i int) error {
+		t.Errorf("unexpected call for %d", i)
+		return nil
+	}, Workers(2), WithRateLimit(r))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline: %v", err)
+	}
+}
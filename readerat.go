@@ -0,0 +1,75 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	ErrInvalidSize      = errors.New("spara: invalid size")
+	ErrInvalidChunkSize = errors.New("spara: invalid chunk size")
+	ErrNilReader        = errors.New("spara: reader must not be nil")
+)
+
+// RunReaderAt splits the first size bytes of r into chunks of chunkSize bytes,
+// reads them concurrently across up to workers goroutines, and calls fn with
+// each chunk and the offset it was read from. The final chunk is shorter if
+// size isn't a multiple of chunkSize. This is the core of parallel hashing,
+// scanning, and upload workloads over files and other random access sources.
+//
+// Buffers are pooled and reused between chunks, so fn must not hold on to buf
+// after it returns. Reads that come up short are reported as
+// io.ErrUnexpectedEOF. Otherwise, error handling is the same as
+// RunWithContext.
+func RunReaderAt(parent context.Context, workers int, r io.ReaderAt, size, chunkSize int64, fn func(ctx context.Context, offset int64, buf []byte) error) error {
+	if r == nil {
+		return ErrNilReader
+	}
+	if size < 0 {
+		return invalid(ErrInvalidSize, "size", size)
+	}
+	if chunkSize <= 0 {
+		return invalid(ErrInvalidChunkSize, "chunkSize", chunkSize)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	chunks := size / chunkSize
+	if size%chunkSize != 0 {
+		chunks++
+	}
+
+	buffers := sync.Pool{
+		New: func() any {
+			buf := make([]byte, chunkSize)
+			return &buf
+		},
+	}
+	return RunWithContext(parent, workers, int(chunks), func(ctx context.Context, index int) error {
+		offset := int64(index) * chunkSize
+		length := chunkSize
+		if remaining := size - offset; remaining < length {
+			length = remaining
+		}
+
+		bufp := buffers.Get().(*[]byte)
+		defer buffers.Put(bufp)
+		buf := (*bufp)[:length]
+
+		n, err := r.ReadAt(buf, offset)
+		if int64(n) < length {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		// ReaderAt is allowed to return io.EOF alongside a full read at
+		// the end of the input.
+		if err != nil && err != io.EOF {
+			return err
+		}
+		return fn(ctx, offset, buf)
+	})
+}
@@ -0,0 +1,54 @@
+package spara
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestRunReaderAt(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	var mu sync.Mutex
+	seen := make([]byte, len(data))
+	chunks := 0
+	err := RunReaderAt(context.Background(), 4, bytes.NewReader(data), int64(len(data)), 64, func(ctx context.Context, offset int64, buf []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		chunks++
+		copy(seen[offset:], buf)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if chunks != 16 {
+		t.Errorf("expected 16 chunks: %d", chunks)
+	}
+	if !bytes.Equal(seen, data) {
+		t.Errorf("chunks did not reassemble to the input")
+	}
+}
+
+func TestRunReaderAtShortRead(t *testing.T) {
+	data := make([]byte, 100)
+	err := RunReaderAt(context.Background(), 2, bytes.NewReader(data), 200, 64, func(ctx context.Context, offset int64, buf []byte) error {
+		return nil
+	})
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected an unexpected EOF error: %v", err)
+	}
+
+	noop := func(ctx context.Context, offset int64, buf []byte) error { return nil }
+	if err := RunReaderAt(context.Background(), 2, bytes.NewReader(data), 100, 0, noop); !errors.Is(err, ErrInvalidChunkSize) {
+		t.Errorf("expected an invalid chunk size error: %v", err)
+	}
+	if err := RunReaderAt(context.Background(), 2, nil, 100, 10, noop); err != ErrNilReader {
+		t.Errorf("expected a nil reader error: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidAttempts = errors.New("spara: invalid number of attempts")
+
+// Backoff decides how long to wait before retrying an item. attempt is the
+// number of attempts made so far, so the first retry is preceded by
+// backoff(1).
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff waits the same amount of time before every retry.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration { return d }
+}
+
+// ExponentialBackoff waits base before the first retry, and doubles the wait
+// for every retry after that, up to max. A max of zero means no limit.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d > 0; i++ {
+			if max > 0 && d >= max {
+				break
+			}
+			if d > math.MaxInt64/2 {
+				d = math.MaxInt64
+				break
+			}
+			d *= 2
+		}
+		if max > 0 && d > max {
+			return max
+		}
+		return d
+	}
+}
+
+// Retry calls the mapping function up to attempts times for each index,
+// until it succeeds, waiting between attempts as decided by backoff. A nil
+// backoff retries immediately. attempts must be at least 1, which is the
+// same as not retrying at all. Only the final attempt's error stops the
+// run, and nothing is retried once the run's context is done, since the
+// error is almost certainly just the cancellation.
+//
+// Keep in mind that the mapping function must be safe to call more than once
+// for the same index; anything it did during a failed attempt isn't undone.
+func Retry(attempts int, backoff Backoff) Option {
+	return func(c *config) {
+		c.attempts = attempts
+		c.retrySet = true
+		c.backoff = backoff
+	}
+}
+
+// RetryIf limits Retry to the errors for which retryable returns true. Other
+// errors fail the item immediately.
+func RetryIf(retryable func(err error) bool) Option {
+	return func(c *config) {
+		c.retryable = retryable
+	}
+}
+
+// retry returns middleware that retries failed calls as configured, or nil
+// when retries aren't enabled. done is the Done channel of the run's
+// context, which every call is passed a context derived from.
+//
+// Checking whether to give up between attempts is done with a non-blocking
+// receive on done, fetched once for the whole run, rather than by calling
+// ctx.Err or ctx.Done per attempt. The receive doesn't take any locks while
+// the channel is open, where both of those take the context's mutex, which
+// every worker of a busy run would otherwise be contending on. The channel
+// is only waited on where we'd block anyway, in the backoff sleep.
+func (c *config) retry(done <-chan struct{}) Middleware {
+	if c == nil || !c.retrySet || c.attempts <= 1 {
+		return nil
+	}
+	attempts, backoff, retryable := c.attempts, c.backoff, c.retryable
+	clock := c.getClock()
+	observers := c.retryObservers()
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			var info *workerInfo
+			for attempt := 1; ; attempt++ {
+				if attempt > 1 {
+					// Only looked up once there's actually a retry.
+					if info == nil {
+						info = workerFrom(ctx)
+					}
+					if info != nil {
+						atomic.StoreInt32(&info.attempt, int32(attempt))
+					}
+				}
+				err := fn(ctx, index)
+				if err == nil || attempt == attempts || isDone(done) {
+					return err
+				}
+				if retryable != nil && !retryable(err) {
+					return err
+				}
+				var wait time.Duration
+				if backoff != nil {
+					wait = backoff(attempt)
+				}
+				for _, o := range observers {
+					o.itemRetry(index, attempt, err, wait)
+				}
+				if !sleep(clock, done, wait) {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// sleep waits for d by clock, returning false if done is closed first.
+func sleep(clock Clock, done <-chan struct{}, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// isDone reports whether done has been closed, without blocking. A nil
+// channel, as returned by contexts that can never complete, is never done.
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
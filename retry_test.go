@@ -0,0 +1,100 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	var calls [5]int32
+	err := Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		if atomic.AddInt32(&calls[i], 1) < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	}, Retry(3, ConstantBackoff(time.Millisecond)))
+	if err != nil {
+		t.Fatalf("expected every item to succeed on its third attempt: %v", err)
+	}
+
+	expectedError := errors.New("permanent")
+	var attempts int32
+	err = Do(context.Background(), 1, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&attempts, 1)
+		return expectedError
+	}, Retry(5, nil), RetryIf(func(err error) bool { return err != expectedError }))
+	if err != expectedError || attempts != 1 {
+		t.Errorf("expected non-retryable errors to fail immediately: %v after %d attempts", err, attempts)
+	}
+
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 1, noop, Retry(0, nil)); !errors.Is(err, ErrInvalidAttempts) {
+		t.Errorf("expected ErrInvalidAttempts: %v", err)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts int32
+	err := Do(ctx, 1, func(ctx context.Context, i int) error {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			cancel()
+		}
+		return errors.New("failed")
+	}, Retry(10, ConstantBackoff(time.Hour)))
+	if err == nil || attempts != 1 {
+		t.Errorf("expected no retries once canceled: %v after %d attempts", err, attempts)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, d := range expected {
+		if got := b(i + 1); got != d*time.Millisecond {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, d*time.Millisecond, got)
+		}
+	}
+	if got := ExponentialBackoff(time.Second, 0)(100); got <= 0 {
+		t.Errorf("expected unbounded backoff not to overflow: %v", got)
+	}
+}
+
+func TestIsDone(t *testing.T) {
+	if isDone(nil) {
+		t.Errorf("expected a nil channel never to be done")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ctx.Done()
+	if isDone(done) {
+		t.Errorf("expected an open channel not to be done")
+	}
+	cancel()
+	if !isDone(done) {
+		t.Errorf("expected a closed channel to be done")
+	}
+}
+
+// Every worker checks between attempts, so the check must not contend.
+func BenchmarkRetryDoneCheck(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.Run("Err", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = ctx.Err()
+			}
+		})
+	})
+	b.Run("isDone", func(b *testing.B) {
+		done := ctx.Done()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = isDone(done)
+			}
+		})
+	})
+}
@@ -0,0 +1,93 @@
+package spara
+
+import "sync/atomic"
+
+// ring is a bounded multi-producer, multi-consumer queue that doesn't take
+// any locks, after Dmitry Vyukov's design. Each slot carries a sequence
+// number saying whose turn it is: producers claim a slot by advancing the
+// enqueue position, fill it in, and then bump the slot's sequence to hand it
+// to consumers, who do the reverse. A producer or consumer that loses a race
+// for a slot just tries the next one, so nobody ever waits on anybody else
+// unless the ring is actually full or empty.
+type ring[T any] struct {
+	_       [cacheLineSize]byte
+	enqueue uint64
+	_       [cacheLineSize - 8]byte
+	dequeue uint64
+	_       [cacheLineSize - 8]byte
+
+	mask  uint64
+	slots []ringSlot[T]
+}
+
+type ringSlot[T any] struct {
+	seq uint64
+	val T
+}
+
+// newRing returns a ring that holds at least size items; the capacity is
+// rounded up to a power of two.
+func newRing[T any](size int) *ring[T] {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	r := &ring[T]{
+		mask:  uint64(n - 1),
+		slots: make([]ringSlot[T], n),
+	}
+	for i := range r.slots {
+		r.slots[i].seq = uint64(i)
+	}
+	return r
+}
+
+// push adds v to the ring, returning false if it's full.
+func (r *ring[T]) push(v T) bool {
+	pos := atomic.LoadUint64(&r.enqueue)
+	for {
+		slot := &r.slots[pos&r.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		switch diff := int64(seq) - int64(pos); {
+		case diff == 0:
+			if atomic.CompareAndSwapUint64(&r.enqueue, pos, pos+1) {
+				slot.val = v
+				atomic.StoreUint64(&slot.seq, pos+1)
+				return true
+			}
+			pos = atomic.LoadUint64(&r.enqueue)
+		case diff < 0:
+			// The slot still holds the item from a lap ago.
+			return false
+		default:
+			// Another producer got here first.
+			pos = atomic.LoadUint64(&r.enqueue)
+		}
+	}
+}
+
+// pop removes the oldest item from the ring, returning false if it's empty.
+func (r *ring[T]) pop() (v T, ok bool) {
+	pos := atomic.LoadUint64(&r.dequeue)
+	for {
+		slot := &r.slots[pos&r.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		switch diff := int64(seq) - int64(pos+1); {
+		case diff == 0:
+			if atomic.CompareAndSwapUint64(&r.dequeue, pos, pos+1) {
+				v = slot.val
+				var zero T
+				slot.val = zero
+				atomic.StoreUint64(&slot.seq, pos+r.mask+1)
+				return v, true
+			}
+			pos = atomic.LoadUint64(&r.dequeue)
+		case diff < 0:
+			// Nothing has been pushed into the slot yet.
+			return v, false
+		default:
+			// Another consumer got here first.
+			pos = atomic.LoadUint64(&r.dequeue)
+		}
+	}
+}
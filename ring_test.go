@@ -0,0 +1,92 @@
+package spara
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := newRing[int](3)
+	if len(r.slots) != 4 {
+		t.Fatalf("expected the capacity to round up to 4: %d", len(r.slots))
+	}
+	if _, ok := r.pop(); ok {
+		t.Errorf("expected an empty ring")
+	}
+	// Go around a few times to exercise the sequence numbers.
+	for lap := 0; lap < 3; lap++ {
+		for i := 0; i < 4; i++ {
+			if !r.push(i) {
+				t.Fatalf("lap %d: push %d failed", lap, i)
+			}
+		}
+		if r.push(4) {
+			t.Errorf("lap %d: expected a full ring", lap)
+		}
+		for i := 0; i < 4; i++ {
+			if v, ok := r.pop(); !ok || v != i {
+				t.Fatalf("lap %d: expected %d: %d, %v", lap, i, v, ok)
+			}
+		}
+	}
+}
+
+func TestRingConcurrent(t *testing.T) {
+	const (
+		producers   = 4
+		consumers   = 4
+		perProducer = 2000
+	)
+	r := newRing[int](16)
+	seen := make([]int32, producers*perProducer)
+	remaining := int32(len(seen))
+	var wg sync.WaitGroup
+	wg.Add(producers + consumers)
+	for p := 0; p < producers; p++ {
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				for !r.push(p*perProducer + i) {
+					runtime.Gosched()
+				}
+			}
+		}(p)
+	}
+	for c := 0; c < consumers; c++ {
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&remaining) > 0 {
+				if v, ok := r.pop(); ok {
+					atomic.AddInt32(&seen[v], 1)
+					atomic.AddInt32(&remaining, -1)
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for v, n := range seen {
+		if n != 1 {
+			t.Fatalf("value %d popped %d times", v, n)
+		}
+	}
+}
+
+func BenchmarkRing(b *testing.B) {
+	r := newRing[func()](1024)
+	f := func() {}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for !r.push(f) {
+			}
+			for {
+				if _, ok := r.pop(); ok {
+					break
+				}
+			}
+		}
+	})
+}
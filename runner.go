@@ -0,0 +1,76 @@
+package spara
+
+import "context"
+
+// Runner is a reusable set of options. Services tend to run many different
+// kinds of work with the same handful of settings, like a worker count,
+// retry policy, logger, and metrics recorder, and repeating all of them at
+// every call to Do is both noisy and an easy way for call sites to drift
+// apart. A Runner holds them in one place:
+//
+//	runner, err := spara.New(spara.Workers(16), spara.Retry(3, backoff), spara.WithMetrics(rec))
+//	...
+//	err = runner.Run(ctx, len(users), syncUser)
+//
+// The options are validated once, by New. They're applied afresh for every
+// run, so a Runner is safe to use from multiple goroutines at once, with the
+// usual caveat that options that report into a value the caller passed in,
+// like WithStats, have every run reporting into that same value. Trackers
+// can only be used by a single run, so pass them to Run instead.
+type Runner struct {
+	opts []Option
+}
+
+// New returns a Runner for the given options, or the error Do would return
+// for them.
+func New(opts ...Option) (*Runner, error) {
+	opts = append([]Option(nil), opts...)
+	if err := newConfig(opts).check(); err != nil {
+		return nil, err
+	}
+	return &Runner{opts: opts}, nil
+}
+
+// Run is like Do, with the runner's options. Any options passed to Run are
+// applied after the runner's, so they override them for this run only.
+func (r *Runner) Run(parent context.Context, iterations int, fn MappingFunc, opts ...Option) error {
+	c := newConfig(r.opts)
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := r.check(parent, iterations, fn, c, len(opts) > 0); err != nil {
+		// As with Do, the run will never start to close its Trackers.
+		c.abandon()
+		return err
+	}
+	return runConfigured(parent, iterations, fn, c)
+}
+
+// check checks Run's arguments, and claims what the run needs.
+func (r *Runner) check(parent context.Context, iterations int, fn MappingFunc, c *config, added bool) error {
+	if iterations < 0 {
+		return invalid(ErrInvalidIterations, "iterations", iterations)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	if parent == nil {
+		return ErrNilContext
+	}
+	// The runner's own options have already been checked, so that's only
+	// needed again if this run added to them.
+	if added {
+		if err := c.check(); err != nil {
+			return err
+		}
+	}
+	return c.claim()
+}
+
+// With returns a new Runner with opts added to the runner's options.
+func (r *Runner) With(opts ...Option) (*Runner, error) {
+	all := make([]Option, 0, len(r.opts)+len(opts))
+	all = append(all, r.opts...)
+	all = append(all, opts...)
+	return New(all...)
+}
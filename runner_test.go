@@ -0,0 +1,71 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunner(t *testing.T) {
+	if _, err := New(Workers(0)); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected New to validate the options: %v", err)
+	}
+
+	runner, err := New(Workers(2), Retry(3, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attempts int32
+	err = runner.Run(context.Background(), 1, func(ctx context.Context, i int) error {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected the retry option to apply: %v", err)
+	}
+
+	// Runs can happen concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var stats Stats
+			err := runner.Run(context.Background(), 100, func(ctx context.Context, i int) error {
+				return nil
+			}, WithStats(&stats))
+			if err != nil || stats.Items != 100 {
+				t.Errorf("unexpected concurrent run: %v, %d items", err, stats.Items)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Per-run options override the runner's, and are validated.
+	err = runner.Run(context.Background(), 10, func(ctx context.Context, i int) error { return nil },
+		Workers(0))
+	if !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected per-run options to be validated: %v", err)
+	}
+	var stats Stats
+	err = runner.Run(context.Background(), 10, func(ctx context.Context, i int) error { return nil },
+		Workers(1), WithStats(&stats))
+	if err != nil || len(stats.Workers) != 1 {
+		t.Errorf("expected the per-run worker count to apply: %v, %+v", err, stats.Workers)
+	}
+
+	// Trackers are single-use, even through a runner.
+	tracker := NewTracker(0)
+	withTracker, _ := runner.With(Track(tracker))
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := withTracker.Run(context.Background(), 1, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := withTracker.Run(context.Background(), 1, noop); err != ErrTrackerReused {
+		t.Errorf("expected ErrTrackerReused: %v", err)
+	}
+}
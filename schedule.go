@@ -0,0 +1,281 @@
+package spara
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrInvalidCheckInterval = errors.New("spara: invalid cancellation check interval")
+
+// Scheduler decides how the indices of a run are divided up between its
+// workers. The schedulers in this package cover the common cases:
+//
+//   - Dynamic, the default, hands out one index at a time to whichever worker
+//     asks next. It balances load perfectly, at the cost of every worker
+//     touching a shared counter after every item.
+//   - Chunked hands out contiguous chunks of indices instead, which cuts the
+//     contention on the counter for runs of many tiny items, and keeps each
+//     worker on neighbouring memory.
+//   - Static splits the indices into one contiguous range per worker up
+//     front, with no coordination at all after that. It's the cheapest when
+//     every item takes about as long as every other, and the worst when they
+//     don't.
+//   - WorkStealing starts out like Static, but workers that run out steal
+//     half of what's left from the busiest worker. It's meant for items whose
+//     durations vary by orders of magnitude, where Static leaves workers idle
+//     and Dynamic's shared counter is the bottleneck.
+type Scheduler interface {
+	// newSchedule returns the schedule for a single run, or nil for the
+	// default one-index-at-a-time behavior.
+	newSchedule(workers, iterations int) schedule
+}
+
+// schedule hands out the indices of a single run.
+type schedule interface {
+	// claim returns the next range of indices for worker to process,
+	// [lo, hi). ok is false once there's nothing left for it.
+	claim(worker int) (lo, hi int, ok bool)
+	// stop stops any more ranges from being claimed.
+	stop()
+}
+
+// exitingSchedule is implemented by schedules that need to know when each
+// worker has finished, however it finished.
+type exitingSchedule interface {
+	exit(worker int)
+}
+
+// WithScheduler sets how indices are handed out to workers. See Scheduler.
+func WithScheduler(s Scheduler) Option {
+	return func(c *config) {
+		c.scheduler = s
+	}
+}
+
+// CheckEvery sets how many items a worker works through in a claimed chunk
+// or range between checks of whether the run is stopping, whether because of
+// an error or because the parent context completed. Without it, the check is
+// made before every item, which is a single atomic load; with tiny items and
+// huge chunks, checking less often can be worth the slower response to
+// cancellation. Zero means only checking between chunks.
+//
+// It has no effect with the default scheduler, which hands out one index at a
+// time and so checks between every item anyway.
+func CheckEvery(n int) Option {
+	return func(c *config) {
+		c.checkEvery = n
+		c.checkEverySet = true
+	}
+}
+
+// checkInterval returns how many items to process between stop checks, with
+// zero meaning never.
+func (c *config) checkInterval() int {
+	if c == nil || !c.checkEverySet {
+		return 1
+	}
+	return c.checkEvery
+}
+
+// newSchedule returns the schedule for a run, or nil for the default.
+func (c *config) newSchedule(workers, iterations int) schedule {
+	if c == nil || c.scheduler == nil {
+		return nil
+	}
+	return c.scheduler.newSchedule(workers, iterations)
+}
+
+type dynamicScheduler struct{}
+
+// Dynamic hands out one index at a time, to whichever worker asks next. It's
+// the default.
+func Dynamic() Scheduler {
+	return dynamicScheduler{}
+}
+
+func (dynamicScheduler) newSchedule(workers, iterations int) schedule {
+	return nil
+}
+
+type chunkedScheduler struct {
+	size int
+}
+
+// Chunked hands out contiguous chunks of size indices at a time, to whichever
+// worker asks next. The final chunk may be smaller.
+func Chunked(size int) Scheduler {
+	return chunkedScheduler{size: size}
+}
+
+func (s chunkedScheduler) validate() error {
+	if s.size <= 0 {
+		return invalid(ErrInvalidChunkSize, "size", s.size)
+	}
+	return nil
+}
+
+func (s chunkedScheduler) newSchedule(workers, iterations int) schedule {
+	return &chunkedSchedule{
+		index:      newIndexCounter(0),
+		size:       s.size,
+		iterations: iterations,
+	}
+}
+
+type chunkedSchedule struct {
+	index      *indexCounter
+	size       int
+	iterations int
+}
+
+func (s *chunkedSchedule) claim(worker int) (lo, hi int, ok bool) {
+	hi = s.index.add(s.size)
+	lo = hi - s.size
+	if lo >= s.iterations {
+		return 0, 0, false
+	}
+	if hi > s.iterations {
+		hi = s.iterations
+	}
+	return lo, hi, true
+}
+
+func (s *chunkedSchedule) stop() {
+	s.index.stop(s.iterations)
+}
+
+type staticScheduler struct{}
+
+// Static splits the indices into one contiguous range per worker, as evenly
+// as possible, before the run starts.
+func Static() Scheduler {
+	return staticScheduler{}
+}
+
+func (staticScheduler) newSchedule(workers, iterations int) schedule {
+	return &staticSchedule{
+		workers:    workers,
+		iterations: iterations,
+		claimed:    make([]int32, workers),
+	}
+}
+
+type staticSchedule struct {
+	workers    int
+	iterations int
+	claimed    []int32
+	stopped    int32
+}
+
+func (s *staticSchedule) claim(worker int) (lo, hi int, ok bool) {
+	if atomic.LoadInt32(&s.stopped) != 0 || !atomic.CompareAndSwapInt32(&s.claimed[worker], 0, 1) {
+		return 0, 0, false
+	}
+	lo = worker * s.iterations / s.workers
+	hi = (worker + 1) * s.iterations / s.workers
+	return lo, hi, lo < hi
+}
+
+func (s *staticSchedule) stop() {
+	atomic.StoreInt32(&s.stopped, 1)
+}
+
+type workStealingScheduler struct{}
+
+// WorkStealing gives every worker its own contiguous range of indices, like
+// Static, which it works through one index at a time. A worker whose range is
+// used up steals the back half of whatever's left of the largest remaining
+// range, so the tail of an uneven run is spread across every worker rather
+// than left to whoever drew the slow items.
+func WorkStealing() Scheduler {
+	return workStealingScheduler{}
+}
+
+func (workStealingScheduler) newSchedule(workers, iterations int) schedule {
+	s := &stealingSchedule{deques: make([]deque, workers)}
+	for i := range s.deques {
+		s.deques[i].lo = i * iterations / workers
+		s.deques[i].hi = (i + 1) * iterations / workers
+	}
+	return s
+}
+
+type stealingSchedule struct {
+	deques  []deque
+	stopped int32
+}
+
+// deque is the range of indices a worker has left, [lo, hi). The owner takes
+// from the front and thieves from the back. It's padded so that workers
+// updating their own ranges don't slow down their neighbours.
+type deque struct {
+	mu     sync.Mutex
+	lo, hi int
+	_      [cacheLineSize]byte
+}
+
+// take removes the next index from the front of the range.
+func (d *deque) take() (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lo >= d.hi {
+		return 0, false
+	}
+	d.lo++
+	return d.lo - 1, true
+}
+
+// remaining can be stale as soon as it returns, so it's only used to pick a
+// victim.
+func (d *deque) remaining() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hi - d.lo
+}
+
+// stealHalf removes the back half of the range, rounding up so that a single
+// index left over can be stolen too.
+func (d *deque) stealHalf() (lo, hi int, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := d.hi - d.lo
+	if n <= 0 {
+		return 0, 0, false
+	}
+	lo, hi = d.hi-(n+1)/2, d.hi
+	d.hi = lo
+	return lo, hi, true
+}
+
+func (s *stealingSchedule) claim(worker int) (lo, hi int, ok bool) {
+	for atomic.LoadInt32(&s.stopped) == 0 {
+		own := &s.deques[worker]
+		if i, ok := own.take(); ok {
+			return i, i + 1, true
+		}
+		// Out of work, so find the worker with the most left. The sizes
+		// can change as soon as we've looked at them, which only means
+		// the steal might come back smaller than expected, or empty, in
+		// which case we look again.
+		victim, most := -1, 0
+		for i := range s.deques {
+			if n := s.deques[i].remaining(); i != worker && n > most {
+				victim, most = i, n
+			}
+		}
+		if victim < 0 {
+			return 0, 0, false
+		}
+		if lo, hi, ok := s.deques[victim].stealHalf(); ok {
+			own.mu.Lock()
+			own.lo, own.hi = lo, hi
+			own.mu.Unlock()
+		}
+	}
+	return 0, 0, false
+}
+
+func (s *stealingSchedule) stop() {
+	atomic.StoreInt32(&s.stopped, 1)
+}
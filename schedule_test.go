@@ -0,0 +1,127 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulers(t *testing.T) {
+	schedulers := map[string]Scheduler{
+		"Dynamic":  Dynamic(),
+		"Chunked1": Chunked(1),
+		"Chunked7": Chunked(7),
+		"Static":   Static(),
+		"Stealing": WorkStealing(),
+	}
+	for name, s := range schedulers {
+		for _, workers := range []int{1, 3, 8, 200} {
+			for _, iterations := range []int{0, 1, 10, 101} {
+				counts := make([]int32, iterations)
+				err := Do(context.Background(), iterations, func(ctx context.Context, i int) error {
+					atomic.AddInt32(&counts[i], 1)
+					return nil
+				}, Workers(workers), WithScheduler(s))
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				for i, count := range counts {
+					if count != 1 {
+						t.Fatalf("%s, %d workers, %d iterations: index %d called %d times", name, workers, iterations, i, count)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSchedulerValidation(t *testing.T) {
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 10, noop, WithScheduler(Chunked(0))); !errors.Is(err, ErrInvalidChunkSize) {
+		t.Errorf("expected ErrInvalidChunkSize: %v", err)
+	}
+	if err := Do(context.Background(), 10, noop, CheckEvery(-1)); !errors.Is(err, ErrInvalidCheckInterval) {
+		t.Errorf("expected ErrInvalidCheckInterval: %v", err)
+	}
+}
+
+func TestCheckEvery(t *testing.T) {
+	expectedError := errors.New("boom")
+	run := func(opts ...Option) int32 {
+		var calls int32
+		started := make(chan struct{})
+		opts = append(opts, Workers(2), WithScheduler(Static()))
+		// Worker 1's range starts at 500, and it fails as soon as worker 0
+		// has started on its own range.
+		err := Do(context.Background(), 1000, func(ctx context.Context, i int) error {
+			if i == 500 {
+				<-started
+				return expectedError
+			}
+			if i == 0 {
+				close(started)
+				<-ctx.Done()
+			}
+			atomic.AddInt32(&calls, 1)
+			return nil
+		}, opts...)
+		if err != expectedError {
+			t.Errorf("expected the error: %v", err)
+		}
+		return calls
+	}
+
+	if calls := run(); calls != 1 {
+		t.Errorf("expected worker 0 to stop after its current item: %d", calls)
+	}
+	if calls := run(CheckEvery(100)); calls != 100 {
+		t.Errorf("expected worker 0 to stop at the next check: %d", calls)
+	}
+	if calls := run(CheckEvery(0)); calls != 500 {
+		t.Errorf("expected worker 0 to finish its range: %d", calls)
+	}
+}
+
+func BenchmarkSchedulers(b *testing.B) {
+	schedulers := []struct {
+		name string
+		s    Scheduler
+	}{
+		{"Dynamic", Dynamic()},
+		{"Chunked", Chunked(64)},
+		{"Static", Static()},
+		{"WorkStealing", WorkStealing()},
+	}
+	for _, s := range schedulers {
+		b.Run(s.name, func(b *testing.B) {
+			values := make([]int, 10000)
+			fn := func(ctx context.Context, i int) error {
+				values[i]++
+				return nil
+			}
+			for i := 0; i < b.N; i++ {
+				Do(context.Background(), len(values), fn, WithScheduler(s.s))
+			}
+		})
+	}
+}
+
+// With one very slow range, Static leaves that worker to do everything in it
+// alone, while WorkStealing spreads it around.
+func TestWorkStealingBalances(t *testing.T) {
+	var stats Stats
+	err := Do(context.Background(), 400, func(ctx context.Context, i int) error {
+		if i < 100 {
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	}, Workers(4), WithScheduler(WorkStealing()), WithStats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Workers[0].Items >= 100 {
+		t.Errorf("expected worker 0's slow range to be stolen from: %+v", stats.Workers)
+	}
+}
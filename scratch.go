@@ -0,0 +1,103 @@
+package spara
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNilScratch = errors.New("spara: scratch constructor must not be nil")
+
+// Scratch describes a reusable value that each worker gets its own copy of,
+// like a buffer, an encoder, or a hasher.
+type Scratch[S any] struct {
+	// New creates a worker's value. It's called at most once per worker,
+	// on the worker's goroutine, before the worker's first item.
+	New func() S
+	// Reset, if set, is called on a worker's value after each item, to get
+	// it ready for the next one.
+	Reset func(S)
+}
+
+// RunWithScratch is like RunWithContext, but also passes fn a scratch value
+// belonging to the worker that's calling it. No other worker ever sees the
+// same value, so it can be used without any locking, and since there's one
+// per worker rather than one per item, allocating it costs next to nothing.
+// That makes it both cheaper and safer than reaching for a global sync.Pool
+// inside fn: nothing has to be put back, and nothing can be put back twice.
+//
+// fn must not hold on to the scratch value after it returns.
+func RunWithScratch[S any](parent context.Context, workers int, iterations int, scratch Scratch[S], fn func(ctx context.Context, index int, s S) error) error {
+	var newState func(int) S
+	if scratch.New != nil {
+		newState = func(int) S { return scratch.New() }
+	}
+	return runWithState(parent, workers, iterations, newState, scratch.Reset, func(ctx context.Context, s S, index int) error {
+		return fn(ctx, index, s)
+	})
+}
+
+// RunWithState is like RunWithContext, but each worker first creates a state
+// value with newState, and fn is passed the state of the worker calling it.
+// It's for the common case of each worker owning something reusable that's
+// too expensive to create per item and not safe to share, like an encoder
+// or a connection. newState is passed the worker's ID, the same one WorkerID
+// reports, which is handy for logging or for picking a shard. It's called on
+// the worker's own goroutine, and only for workers that end up with an item
+// to process.
+//
+// RunWithScratch is the same thing, for values that don't care which worker
+// they belong to, and need resetting between items.
+func RunWithState[S any](parent context.Context, workers int, iterations int, newState func(worker int) S, fn func(ctx context.Context, state S, index int) error) error {
+	return runWithState(parent, workers, iterations, newState, nil, fn)
+}
+
+// runWithState is the implementation behind RunWithScratch and RunWithState.
+func runWithState[S any](parent context.Context, workers int, iterations int, newState func(worker int) S, reset func(S), fn func(ctx context.Context, s S, index int) error) error {
+	if workers <= 0 {
+		return invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	if iterations < 0 {
+		return invalid(ErrInvalidIterations, "iterations", iterations)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	if newState == nil {
+		return ErrNilScratch
+	}
+	if parent == nil {
+		return ErrNilContext
+	}
+	if iterations == 0 {
+		return nil
+	}
+	if workers > iterations {
+		workers = iterations
+	}
+
+	// Each "index" of the underlying run is a whole worker, which pulls the
+	// real indices off of a counter of its own.
+	index := newIndexCounter(-1)
+	return runLoops(parent, workers, workers, func(ctx context.Context, worker int) error {
+		i := index.next()
+		if i >= iterations {
+			return nil
+		}
+		s := newState(worker)
+		done := ctx.Done()
+		w := workerFrom(ctx)
+		for ; i < iterations; i = index.next() {
+			if isDone(done) {
+				return ctx.Err()
+			}
+			w.begin(i)
+			if err := fn(ctx, s, i); err != nil {
+				return err
+			}
+			if reset != nil {
+				reset(s)
+			}
+		}
+		return nil
+	})
+}
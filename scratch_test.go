@@ -0,0 +1,117 @@
+package spara
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunWithScratch(t *testing.T) {
+	const workers = 4
+	var created int32
+	var mu sync.Mutex
+	seen := make(map[*bytes.Buffer]bool)
+	outputs := make([]string, 100)
+	scratch := Scratch[*bytes.Buffer]{
+		New: func() *bytes.Buffer {
+			atomic.AddInt32(&created, 1)
+			return new(bytes.Buffer)
+		},
+		Reset: (*bytes.Buffer).Reset,
+	}
+	err := RunWithScratch(context.Background(), workers, len(outputs), scratch, func(ctx context.Context, i int, buf *bytes.Buffer) error {
+		mu.Lock()
+		seen[buf] = true
+		mu.Unlock()
+		if buf.Len() != 0 {
+			return fmt.Errorf("index %d: expected a reset buffer: %q", i, buf.String())
+		}
+		fmt.Fprintf(buf, "item-%d", i)
+		outputs[i] = buf.String()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created > workers || len(seen) != int(created) {
+		t.Errorf("expected one scratch value per worker: %d created, %d seen", created, len(seen))
+	}
+	for i, out := range outputs {
+		if out != fmt.Sprintf("item-%d", i) {
+			t.Fatalf("unexpected output for %d: %q", i, out)
+		}
+	}
+}
+
+func TestRunWithScratchErrors(t *testing.T) {
+	scratch := Scratch[[]byte]{New: func() []byte { return make([]byte, 8) }}
+	noop := func(ctx context.Context, i int, s []byte) error { return nil }
+	if err := RunWithScratch(context.Background(), 1, 1, Scratch[[]byte]{}, noop); err != ErrNilScratch {
+		t.Errorf("expected ErrNilScratch: %v", err)
+	}
+	if err := RunWithScratch(context.Background(), 0, 1, scratch, noop); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected ErrInvalidWorkers: %v", err)
+	}
+	if err := RunWithScratch(context.Background(), 1, 0, scratch, noop); err != nil {
+		t.Errorf("expected zero iterations to succeed: %v", err)
+	}
+
+	expectedError := errors.New("boom")
+	var calls int32
+	err := RunWithScratch(context.Background(), 4, 10000, scratch, func(ctx context.Context, i int, s []byte) error {
+		atomic.AddInt32(&calls, 1)
+		if i == 10 {
+			return expectedError
+		}
+		return nil
+	})
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if calls == 10000 {
+		t.Errorf("expected the error to stop the other workers")
+	}
+}
+
+func TestRunWithState(t *testing.T) {
+	type conn struct {
+		worker int
+		used   int
+	}
+	var created int32
+	counts := make([]int, 1000)
+	err := RunWithState(context.Background(), 4, len(counts), func(worker int) *conn {
+		atomic.AddInt32(&created, 1)
+		return &conn{worker: worker}
+	}, func(ctx context.Context, c *conn, i int) error {
+		if id, _ := WorkerID(ctx); id != c.worker {
+			t.Errorf("expected worker %d's state, got worker %d's", id, c.worker)
+		}
+		if index, _ := ItemIndex(ctx); index != i || Attempt(ctx) != 1 {
+			t.Errorf("unexpected item info for %d: index %d, attempt %d", i, index, Attempt(ctx))
+		}
+		c.used++
+		counts[i]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created > 4 {
+		t.Errorf("expected at most one state per worker: %d", created)
+	}
+	for i, n := range counts {
+		if n != 1 {
+			t.Fatalf("index %d was processed %d times", i, n)
+		}
+	}
+
+	noop := func(ctx context.Context, c *conn, i int) error { return nil }
+	if err := RunWithState[*conn](context.Background(), 1, 1, nil, noop); err != ErrNilScratch {
+		t.Errorf("expected ErrNilScratch: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package spara
+
+import (
+	"os"
+	"strconv"
+)
+
+// SequentialEnv is the environment variable that, when set to a true value
+// as understood by strconv.ParseBool, like "1" or "true", makes every run in
+// the process behave as if it had been passed the Sequential option. It's
+// read once, at startup.
+const SequentialEnv = "SPARA_SEQUENTIAL"
+
+var forceSequential = envBool(SequentialEnv)
+
+func envBool(name string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(name))
+	return v
+}
+
+// Sequential makes the run use a single worker, whatever the Workers option
+// or the workers argument says, so the items are processed one at a time and
+// in order. It's a debugging aid: when a run misbehaves, forcing it
+// sequential is the quickest way to tell a concurrency bug from a plain one.
+// Setting SPARA_SEQUENTIAL=1 in the environment does the same for every run
+// in the process, including those started with Run and RunWithContext, so
+// there's no need to edit the call sites to try it.
+func Sequential() Option {
+	return func(c *config) {
+		c.sequential = true
+	}
+}
+
+// limitWorkers returns the number of workers a run should actually use.
+func (c *config) limitWorkers(workers int) int {
+	if forceSequential || (c != nil && c.sequential) {
+		return 1
+	}
+	return workers
+}
@@ -0,0 +1,49 @@
+package spara
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSequential(t *testing.T) {
+	check := func(name string, run func(fn func(i int))) {
+		t.Helper()
+		var order []int
+		run(func(i int) { order = append(order, i) })
+		if len(order) != 100 {
+			t.Fatalf("%s: expected 100 items: %d", name, len(order))
+		}
+		for i, j := range order {
+			if i != j {
+				t.Fatalf("%s: expected the items in order: %v", name, order)
+			}
+		}
+	}
+
+	// The option, which the race detector would catch being ignored.
+	check("Do", func(fn func(int)) {
+		Do(context.Background(), 100, func(ctx context.Context, i int) error {
+			fn(i)
+			return nil
+		}, Workers(8), Sequential())
+	})
+
+	// The environment variable, which covers every entry point.
+	forceSequential = true
+	defer func() { forceSequential = false }()
+	check("Run", func(fn func(int)) {
+		Run(8, 100, func(i int) error {
+			fn(i)
+			return nil
+		})
+	})
+	check("RunWithContext", func(fn func(int)) {
+		RunWithContext(context.Background(), 8, 100, func(ctx context.Context, i int) error {
+			fn(i)
+			return nil
+		})
+	})
+	check("RunNoErr", func(fn func(int)) {
+		RunNoErr(8, 100, fn)
+	})
+}
@@ -0,0 +1,38 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrInvalidThreshold = errors.New("spara: invalid slow item threshold")
+
+// WithSlowItemThreshold calls fn for any item that's still running after d,
+// while it's still running, so stragglers can be logged or alerted on before
+// the run finishes rather than after. fn is called at most once per item,
+// from its own goroutine, with how long the item had been running.
+func WithSlowItemThreshold(d time.Duration, fn func(index int, elapsed time.Duration)) Option {
+	return func(c *config) {
+		c.slowThreshold = d
+		c.slowItem = fn
+	}
+}
+
+// detectSlow returns middleware that reports slow items, or nil.
+func (c *config) detectSlow() Middleware {
+	if c == nil || c.slowItem == nil {
+		return nil
+	}
+	threshold, report, clock := c.slowThreshold, c.slowItem, c.getClock()
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			start := clock.Now()
+			t := clock.AfterFunc(threshold, func() {
+				report(index, since(clock, start))
+			})
+			defer t.Stop()
+			return fn(ctx, index)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithSlowItemThreshold(t *testing.T) {
+	reported := make(chan int, 10)
+	err := Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		if i == 3 {
+			// The report must arrive while the item is still running.
+			select {
+			case index := <-reported:
+				if index != 3 {
+					t.Errorf("unexpected slow item: %d", index)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("slow item was never reported")
+			}
+		}
+		return nil
+	}, WithSlowItemThreshold(10*time.Millisecond, func(index int, elapsed time.Duration) {
+		if elapsed < 10*time.Millisecond {
+			t.Errorf("reported too early: %v", elapsed)
+		}
+		reported <- index
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(reported) != 0 {
+		t.Errorf("expected only item 3 to be reported")
+	}
+
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 1, noop, WithSlowItemThreshold(0, func(int, time.Duration) {})); !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("expected ErrInvalidThreshold: %v", err)
+	}
+}
@@ -5,8 +5,6 @@ package spara
 import (
 	"context"
 	"errors"
-	"sync"
-	"sync/atomic"
 )
 
 var (
@@ -22,11 +20,21 @@ var (
 // prematurely and Run will return that first error once all in-progress calls
 // to the mapping function complete.
 func Run(workers int, iterations int, fn func(index int) error) error {
+	if workers <= 0 {
+		return invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	if iterations < 0 {
+		return invalid(ErrInvalidIterations, "iterations", iterations)
+	}
 	if fn == nil {
 		return ErrNilMappingFunction
 	}
-	wrapped := func(_ context.Context, index int) error { return fn(index) }
-	return RunWithContext(context.Background(), workers, iterations, wrapped)
+	if c := defaultConfig(); c != nil || forceVerify {
+		return runDefault(c, workers, iterations, fn)
+	}
+	// Without a context there's nothing to cancel, so skip straight to the
+	// allocation-free path.
+	return runPlain(getDefaultPool(), workers, iterations, fn)
 }
 
 type MappingFunc func(ctx context.Context, index int) error
@@ -52,10 +60,10 @@ type MappingFunc func(ctx context.Context, index int) error
 // canceled eagerly, and the function could return faster.
 func RunWithContext(parent context.Context, workers int, iterations int, fn MappingFunc) error {
 	if workers <= 0 {
-		return ErrInvalidWorkers
+		return invalid(ErrInvalidWorkers, "workers", workers)
 	}
 	if iterations < 0 {
-		return ErrInvalidIterations
+		return invalid(ErrInvalidIterations, "iterations", iterations)
 	}
 	if fn == nil {
 		return ErrNilMappingFunction
@@ -63,6 +71,60 @@ func RunWithContext(parent context.Context, workers int, iterations int, fn Mapp
 	if parent == nil {
 		return ErrNilContext
 	}
+	return run(parent, workers, iterations, fn, defaultConfig())
+}
+
+// Do is like RunWithContext, but takes its configuration as a list of
+// options rather than as positional arguments. Unless the Workers option is
+// passed, Do uses runtime.GOMAXPROCS(0) workers.
+//
+// Do is the entry point that everything else is built on; Run and
+// RunWithContext are shorthands for the common case of a plain run with a
+// fixed number of workers, and new behavior, like CollectErrors, schedulers,
+// and retries, is only ever added as options to Do:
+//
+//	err := spara.Do(ctx, len(inputs), fn, spara.Workers(8), spara.CollectErrors())
+func Do(parent context.Context, iterations int, fn MappingFunc, opts ...Option) error {
+	c := newConfig(opts)
+	if err := checkDo(parent, iterations, fn, c); err != nil {
+		// The run will never start, so nothing else will close the
+		// channels of its Trackers.
+		c.abandon()
+		return err
+	}
+	return runConfigured(parent, iterations, fn, c)
+}
+
+// checkDo checks Do's arguments.
+func checkDo(parent context.Context, iterations int, fn MappingFunc, c *config) error {
+	if c.workers <= 0 {
+		return invalid(ErrInvalidWorkers, "workers", c.workers)
+	}
+	if iterations < 0 {
+		return invalid(ErrInvalidIterations, "iterations", iterations)
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	if parent == nil {
+		return ErrNilContext
+	}
+	// Last, since it can claim resources like a Tracker that are only
+	// released by the run itself.
+	return c.validate()
+}
+
+// run is the shared implementation behind all of the entry points. Arguments
+// are assumed to have been validated by the caller. c may be nil, which is
+// equivalent to passing a config with no options set.
+func run(parent context.Context, workers int, iterations int, fn MappingFunc, c *config) (err error) {
+	parent = c.withRunName(parent)
+	parent, cancelTimeout := c.withTimeout(parent)
+	defer cancelTimeout()
+	if c.observed() {
+		start := c.runStart(parent, iterations)
+		defer func() { c.runEnd(err, start) }()
+	}
 	if iterations == 0 {
 		return nil
 	}
@@ -71,94 +133,191 @@ func RunWithContext(parent context.Context, workers int, iterations int, fn Mapp
 	if workers > iterations {
 		workers = iterations
 	}
+	workers = c.limitWorkers(workers)
+	debug := c.debugging()
+	if debug {
+		c.debugf("run: starting %d workers for %d iterations", workers, iterations)
+	}
 
 	// Eagerly check whether the parent context is already done.
 	select {
 	case <-parent.Done():
+		if debug {
+			c.debugf("run: parent context already done: %v (cause: %v)", parent.Err(), context.Cause(parent))
+		}
+		c.runStopped(parent.Err(), context.Cause(parent))
 		return parent.Err()
 	default:
 		break
 	}
 
-	// Create a function that atomically returns the next index to process. We
+	// Create a counter that atomically returns the next index to process. We
 	// can start this at workers-1, since the workers are passed their first
 	// index directly.
-	var index int32 = int32(workers - 1)
-	nextIndex := func() int {
-		return int(atomic.AddInt32(&index, 1))
-	}
+	index := newIndexCounter(workers - 1)
+	// Or, if the caller picked a scheduler, let it hand out indices instead.
+	sched := c.newSchedule(workers, iterations)
 	// Atomically stops iteration inside of the worker functions.
 	stopIteration := func() {
-		atomic.StoreInt32(&index, int32(iterations))
+		index.stop(iterations)
+		if sched != nil {
+			sched.stop()
+		}
 	}
 
 	// Wrap the parent context with cancellation so that we can stop internal
-	// processing whenever a worker returns an error.
-	ctx, cancel := context.WithCancel(parent)
-	defer cancel()
+	// processing whenever a worker returns an error, unless the caller has
+	// asked us not to bother.
+	ctx, cancel := parent, func() {}
+	if c.cancelable() {
+		ctx, cancel = context.WithCancel(parent)
+		defer cancel()
+	}
 
-	var killOnce int32
-	var firsterr error
+	var state completion
 	kill := func(err error) {
-		// Only execute this on the first call. Only worker functions call
-		// kill, so we can be certain that firsterr is safe to access once
-		// wg.Done unblocks.
-		if atomic.CompareAndSwapInt32(&killOnce, 0, 1) {
+		// Only the first call stops the run; see completion for how this
+		// interacts with the parent completing.
+		if state.fail(err) {
+			if debug {
+				c.debugf("kill: first error, stopping iteration and canceling: %v", err)
+			}
 			stopIteration()
 			cancel()
-			firsterr = err
+			c.runStopped(err, err)
+			c.runCanceled()
+		} else if debug {
+			c.debugf("kill: ignoring error, run already stopping: %v", err)
 		}
 	}
 
-	// Start a goroutine that will stop iteration on parent context done. Must
-	// be specified _after_ we wrap the parent context WithCancel; if parent
-	// never completed, the spawned goroutine would leak.
+	// Stop iteration when the parent context completes. context.AfterFunc
+	// runs the function on a goroutine of its own only if and when the
+	// parent actually completes, so a run whose parent outlives it never
+	// pays for a goroutine, and stopping the registration once the run is
+	// over means nothing leaks if the parent never completes at all.
 	//
-	// We don't need to spawn if the parent context never completes. The
-	// stdlib's context.Background's Done method returns nil, so apparently we
-	// can check that to decide what to do.
-	parentIsNeverDone := parent.Done() == nil
-	if !parentIsNeverDone {
-		go func() {
-			<-ctx.Done()
-			if atomic.CompareAndSwapInt32(&killOnce, 0, 2) {
+	// Contexts that can never complete, like context.Background, have a nil
+	// Done channel, and there's nothing to register for.
+	if parent.Done() != nil {
+		stop := context.AfterFunc(parent, func() {
+			if state.parentDone() {
+				if debug {
+					c.debugf("kill: parent context done, stopping iteration: %v (cause: %v)", parent.Err(), context.Cause(parent))
+				}
 				stopIteration()
+				c.runStopped(parent.Err(), context.Cause(parent))
+				c.runCanceled()
 			}
-		}()
+		})
+		defer stop()
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(workers)
-	for i := 0; i < workers; i++ {
-		go func(start int) {
-			defer wg.Done()
-			for j := start; j < iterations; j = nextIndex() {
-				if err := fn(ctx, j); err != nil {
-					kill(err)
-					return
-				}
-			}
-		}(i)
-	}
-	wg.Wait()
+	verify := c.newVerifier(iterations)
 
-	// killOnce = 1
-	if firsterr != nil {
-		return firsterr
+	var collected *errorCollector
+	if c.collecting() {
+		collected = &errorCollector{}
 	}
 
-	// firsterr is nil, but the parent context may have been the thing that
-	// stopped iteration, ie killOnce = 2. We know it definitely hasn't if the
-	// parent doesn't terminate in the first place.
-	if parentIsNeverDone {
-		return nil
+	// Wrap fn in the middleware for the per-item options, outermost first.
+	// The budget is per run, so it's waited on before the limiter, which
+	// might be shared, so that a slot isn't held by an item that can't start.
+	fn = Chain(
+		c.withFinalizer(),
+		c.withCleanup(&state),
+		c.withDedupe(),
+		c.withBudget(),
+		c.withLimiter(),
+		c.retry(ctx.Done()),
+		c.withRateLimit(),
+		c.withItemTimeout(),
+		c.userMiddleware(),
+		c.withChaos(),
+	)(fn)
+	pool := c.getPool()
+	state.start(workers)
+	for i := 0; i < workers; i++ {
+		start := i
+		pool.spawn(func() {
+			defer state.workerDone()
+			info := &workerInfo{id: start, sched: sched, name: c.itemName}
+			c.labelWorker(withWorker(ctx, info), start, func(ctx context.Context) {
+				c.workerStart(start)
+				defer c.workerEnd(start)
+				fn := Chain(c.labelItems(), c.detectSlow(), c.observe(start))(fn)
+				job := pool.track(c, start)
+				defer pool.untrack(job)
+				if debug {
+					c.debugf("worker %d: started", start)
+				}
+				process := func(j int) bool {
+					if debug {
+						c.debugf("worker %d: dispatching index %d", start, j)
+					}
+					if verify != nil {
+						verify.dispatch(j)
+					}
+					info.begin(j)
+					job.begin(j)
+					err := fn(ctx, j)
+					job.end(err)
+					if err != nil {
+						if collected != nil {
+							if debug {
+								c.debugf("worker %d: index %d failed, collecting: %v", start, j, err)
+							}
+							collected.add(j, c.wrapError(j, err))
+							return true
+						}
+						if debug {
+							c.debugf("worker %d: index %d failed, exiting: %v", start, j, err)
+						}
+						kill(c.wrapError(j, err))
+						return false
+					}
+					return true
+				}
+				if sched == nil {
+					for j := start; j < iterations; j = index.next() {
+						if !process(j) {
+							return
+						}
+					}
+				} else {
+					// A claimed range is worked through without going back
+					// to the scheduler, so check now and then whether the
+					// run has been stopped in the meantime.
+					if d, ok := sched.(exitingSchedule); ok {
+						defer d.exit(start)
+					}
+					every := c.checkInterval()
+					for lo, hi, ok := sched.claim(start); ok; lo, hi, ok = sched.claim(start) {
+						if debug {
+							c.debugf("worker %d: claimed indices [%d, %d)", start, lo, hi)
+						}
+						for j := lo; j < hi; j++ {
+							if every > 0 && j > lo && (j-lo)%every == 0 && state.stopping() {
+								return
+							}
+							if !process(j) {
+								return
+							}
+						}
+					}
+				}
+				if debug {
+					c.debugf("worker %d: no indices left, exiting", start)
+				}
+			})
+		})
 	}
-
-	// killOnce = 0, the parent context isn't done
-	if atomic.CompareAndSwapInt32(&killOnce, 0, 3) {
-		return nil
+	err = state.wait(parent)
+	if verify != nil && err == nil {
+		verify.complete()
 	}
-
-	// killOnce = 2
-	return ctx.Err()
+	if collected != nil {
+		err = collected.join(err)
+	}
+	return err
 }
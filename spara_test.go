@@ -76,10 +76,10 @@ func noopMappingFunc(i int) error {
 }
 
 func TestRunInputErrors(t *testing.T) {
-	if err := Run(0, 10, noopMappingFunc); err != ErrInvalidWorkers {
+	if err := Run(0, 10, noopMappingFunc); !errors.Is(err, ErrInvalidWorkers) {
 		t.Errorf("expected calling Run with zero workers to fail: %s", err)
 	}
-	if err := Run(1, -1, noopMappingFunc); err != ErrInvalidIterations {
+	if err := Run(1, -1, noopMappingFunc); !errors.Is(err, ErrInvalidIterations) {
 		t.Errorf("expected calling Run with negative iterations to fail: %s", err)
 	}
 }
@@ -0,0 +1,214 @@
+package sparatest
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heyimalex/spara"
+)
+
+// Clock is a spara.Clock for tests, which only moves when told to. Passed to
+// a run with spara.WithClock, or to a pipeline stage with pipeline.Clock, it
+// lets a test step through backoffs, timeouts, and batching windows exactly,
+// without sleeping through them:
+//
+//	clock := sparatest.NewClock(time.Time{})
+//	go spara.Do(ctx, 1, fn, spara.Retry(3, spara.ConstantBackoff(time.Minute)), spara.WithClock(clock))
+//	clock.BlockUntil(t, 1) // the first attempt failed, and the retry is waiting
+//	clock.Advance(time.Minute)
+//
+// Since the code under test runs on goroutines of its own, a test has to
+// know that it has gotten as far as waiting on the clock before advancing
+// it, or the advance can happen too soon; BlockUntil waits for that.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	seq     int // orders timers due at the same time by creation
+	pending []*clockTimer
+	changed chan struct{} // closed and replaced when pending grows
+}
+
+// NewClock returns a Clock that reads start until it's advanced.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start, changed: make(chan struct{})}
+}
+
+var _ spara.Clock = (*Clock)(nil)
+
+// clockTimer is a timer, AfterFunc, or ticker waiting on a Clock.
+type clockTimer struct {
+	clock  *Clock
+	when   time.Time
+	seq    int
+	period time.Duration // for tickers
+	ch     chan time.Time
+	f      func()
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTimer(d time.Duration) spara.Timer {
+	return c.schedule(&clockTimer{ch: make(chan time.Time, 1)}, d)
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) spara.Timer {
+	return c.schedule(&clockTimer{f: f}, d)
+}
+
+// NewTicker returns a ticker that ticks every d of the clock's time. An
+// Advance past several ticks sends them all, as far as the channel's buffer
+// of one allows, and drops the rest, just as a time.Ticker with a slow
+// receiver would.
+func (c *Clock) NewTicker(d time.Duration) spara.Ticker {
+	if d <= 0 {
+		panic("sparatest: non-positive interval for NewTicker")
+	}
+	return clockTicker{c.schedule(&clockTimer{ch: make(chan time.Time, 1), period: d}, d)}
+}
+
+func (c *Clock) schedule(t *clockTimer, d time.Duration) *clockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.clock = c
+	t.when = c.now.Add(d)
+	c.add(t)
+	return t
+}
+
+// add adds t to the pending timers. Timers that are already due fire on the
+// next Advance, even one of zero.
+func (c *Clock) add(t *clockTimer) {
+	c.seq++
+	t.seq = c.seq
+	c.pending = append(c.pending, t)
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Pending returns the number of timers, AfterFuncs, and tickers waiting on
+// the clock.
+func (c *Clock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// BlockUntil waits until at least n timers, AfterFuncs, and tickers are
+// waiting on the clock, failing t if that takes more than a few seconds of
+// real time.
+func (c *Clock) BlockUntil(t testing.TB, n int) {
+	t.Helper()
+	timeout := time.NewTimer(5 * time.Second)
+	defer timeout.Stop()
+	for {
+		c.mu.Lock()
+		pending, changed := len(c.pending), c.changed
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			t.Fatalf("sparatest: timed out waiting for %d timers on the clock, with %d", n, pending)
+		}
+	}
+}
+
+// Advance moves the clock forward by d, firing everything that comes due on
+// the way, in order. Timers and tickers are sent the time they were due, so
+// a ticker passed over several times ticks with the right times, but
+// AfterFuncs run on goroutines of their own, and may only get to look at
+// the clock once Advance is done with it.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+	c.AdvanceTo(end)
+}
+
+// AdvanceTo moves the clock forward to end, like Advance. It does nothing if
+// end isn't after the current time, other than fire anything already due.
+func (c *Clock) AdvanceTo(end time.Time) {
+	for {
+		c.mu.Lock()
+		t := c.next(end)
+		if t == nil {
+			if end.After(c.now) {
+				c.now = end
+			}
+			c.mu.Unlock()
+			return
+		}
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		now := c.now
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+			c.add(t)
+		}
+		c.mu.Unlock()
+		t.fire(now)
+	}
+}
+
+// next removes and returns the earliest timer due by end, or nil.
+func (c *Clock) next(end time.Time) *clockTimer {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	sort.Slice(c.pending, func(i, j int) bool {
+		a, b := c.pending[i], c.pending[j]
+		if !a.when.Equal(b.when) {
+			return a.when.Before(b.when)
+		}
+		return a.seq < b.seq
+	})
+	t := c.pending[0]
+	if t.when.After(end) {
+		return nil
+	}
+	c.pending = c.pending[1:]
+	return t
+}
+
+func (t *clockTimer) fire(now time.Time) {
+	if t.f != nil {
+		go t.f()
+		return
+	}
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
+
+// remove takes t off the clock, reporting whether it was still pending.
+func (t *clockTimer) remove() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.pending {
+		if other == t {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *clockTimer) C() <-chan time.Time { return t.ch }
+func (t *clockTimer) Stop() bool          { return t.remove() }
+
+type clockTicker struct {
+	*clockTimer
+}
+
+func (t clockTicker) Stop() { t.remove() }
@@ -0,0 +1,96 @@
+package sparatest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heyimalex/spara"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewClock(start)
+	late := clock.NewTimer(2 * time.Second)
+	early := clock.NewTimer(time.Second)
+	stopped := clock.NewTimer(time.Second)
+	ticker := clock.NewTicker(time.Second)
+	fired := make(chan struct{})
+	clock.AfterFunc(1500*time.Millisecond, func() { close(fired) })
+	if !stopped.Stop() || stopped.Stop() {
+		t.Errorf("expected Stop to report whether the timer was pending")
+	}
+	if n := clock.Pending(); n != 4 {
+		t.Errorf("expected 4 pending timers: %d", n)
+	}
+
+	clock.Advance(time.Second)
+	if at := <-early.C(); !at.Equal(start.Add(time.Second)) {
+		t.Errorf("unexpected time from the timer: %v", at)
+	}
+	if at := <-ticker.C(); !at.Equal(start.Add(time.Second)) {
+		t.Errorf("unexpected time from the ticker: %v", at)
+	}
+	select {
+	case <-late.C():
+		t.Errorf("expected the later timer not to have fired")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	<-fired
+	if at := <-late.C(); !at.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("unexpected time from the timer: %v", at)
+	}
+	if at := <-ticker.C(); !at.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("unexpected time from the ticker: %v", at)
+	}
+	if !clock.Now().Equal(start.Add(2 * time.Second)) {
+		t.Errorf("unexpected time: %v", clock.Now())
+	}
+
+	ticker.Stop()
+	if n := clock.Pending(); n != 0 {
+		t.Errorf("expected nothing pending: %d", n)
+	}
+}
+
+func TestClockRetryBackoff(t *testing.T) {
+	clock := NewClock(time.Time{})
+	expectedError := errors.New("boom")
+	var mu sync.Mutex
+	var attempts []time.Duration
+	done := make(chan error, 1)
+	go func() {
+		done <- spara.Do(context.Background(), 1, func(ctx context.Context, i int) error {
+			mu.Lock()
+			attempts = append(attempts, clock.Now().Sub(time.Time{}))
+			mu.Unlock()
+			return expectedError
+		}, spara.Workers(1), spara.Retry(5, spara.ExponentialBackoff(time.Second, 5*time.Second)), spara.WithClock(clock))
+	}()
+	// Each retry waits for exactly its backoff: a nanosecond less isn't
+	// enough.
+	for _, backoff := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		clock.BlockUntil(t, 1)
+		clock.Advance(backoff - 1)
+		if clock.Pending() != 1 {
+			t.Fatalf("expected the retry to still be waiting")
+		}
+		clock.Advance(1)
+	}
+	if err := <-done; err != expectedError {
+		t.Fatalf("expected the error: %v", err)
+	}
+	expected := []time.Duration{0, time.Second, 3 * time.Second, 7 * time.Second, 12 * time.Second}
+	if len(attempts) != len(expected) {
+		t.Fatalf("unexpected attempts: %v", attempts)
+	}
+	for i := range expected {
+		if attempts[i] != expected[i] {
+			t.Errorf("attempt %d at %v, expected %v", i+1, attempts[i], expected[i])
+		}
+	}
+}
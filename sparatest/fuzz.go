@@ -0,0 +1,159 @@
+package sparatest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/heyimalex/spara"
+)
+
+// RunCase is a single scenario for CheckRun.
+type RunCase struct {
+	Workers    int
+	Iterations int
+	// FailAt is the index whose call returns an error, or -1 for none.
+	FailAt int
+	// CancelAt is the number of calls after which the parent context is
+	// canceled, from inside the call that reaches it, or -1 for never.
+	// Calls are counted from 1.
+	CancelAt int
+}
+
+// errInjected is the error CheckRun's mapping function fails with.
+var errInjected = errors.New("sparatest: injected error")
+
+// CheckRun runs spara.RunWithContext with the scenario c, and fails t if it
+// breaks any of the invariants RunWithContext documents:
+//
+//   - no index is called more than once, or outside [0, Iterations);
+//   - no more than Workers calls are in flight at once;
+//   - every call has returned by the time RunWithContext does, and the
+//     context passed to them is done by then;
+//   - a run that returns nil called every index exactly once;
+//   - a run returns nil, the mapping function's error, or the parent's
+//     error, and never nil once the mapping function has failed.
+func CheckRun(t testing.TB, c RunCase) {
+	t.Helper()
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	counts := make([]int32, max(c.Iterations, 0))
+	var calls, inFlight, peak int32
+	var returned atomic.Bool
+	var mu sync.Mutex
+	var lastCtx context.Context
+	err := spara.RunWithContext(parent, c.Workers, c.Iterations, func(ctx context.Context, i int) error {
+		if returned.Load() {
+			t.Errorf("index %d called after RunWithContext returned", i)
+		}
+		if i < 0 || i >= len(counts) {
+			t.Errorf("index %d out of range [0, %d)", i, len(counts))
+			return nil
+		}
+		if n := atomic.AddInt32(&counts[i], 1); n > 1 {
+			t.Errorf("index %d called %d times", i, n)
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		mu.Lock()
+		lastCtx = ctx
+		mu.Unlock()
+		if int(atomic.AddInt32(&calls, 1)) == c.CancelAt {
+			cancel()
+		}
+		if i == c.FailAt {
+			return errInjected
+		}
+		return nil
+	})
+	returned.Store(true)
+
+	if c.Workers <= 0 || c.Iterations < 0 {
+		if err == nil {
+			t.Errorf("%+v: expected invalid arguments to be rejected", c)
+		}
+		return
+	}
+	if n := atomic.LoadInt32(&inFlight); n != 0 {
+		t.Errorf("%+v: %d calls still in flight after returning", c, n)
+	}
+	if p := atomic.LoadInt32(&peak); int(p) > c.Workers {
+		t.Errorf("%+v: %d calls in flight at once", c, p)
+	}
+	if lastCtx != nil && lastCtx.Err() == nil {
+		t.Errorf("%+v: the mapping function's context is still live after returning", c)
+	}
+	canFail := c.FailAt >= 0 && c.FailAt < c.Iterations
+	canCancel := c.CancelAt >= 1 && c.CancelAt <= c.Iterations
+	switch {
+	case err == nil:
+		if canFail && counts[c.FailAt] > 0 {
+			t.Errorf("%+v: returned nil after index %d failed", c, c.FailAt)
+		}
+		for i, n := range counts {
+			if n != 1 {
+				t.Errorf("%+v: returned nil with index %d called %d times", c, i, n)
+				break
+			}
+		}
+	case errors.Is(err, errInjected):
+		if !canFail {
+			t.Errorf("%+v: returned an error that was never returned", c)
+		}
+	case errors.Is(err, context.Canceled):
+		if !canCancel {
+			t.Errorf("%+v: returned the parent's error without it being canceled", c)
+		}
+	default:
+		t.Errorf("%+v: unexpected error: %v", c, err)
+	}
+	if !canFail && !canCancel && err != nil {
+		t.Errorf("%+v: expected nil: %v", c, err)
+	}
+}
+
+// FuzzRunWithContext is a fuzz target for spara.RunWithContext, which runs
+// CheckRun with fuzzed worker counts, iteration counts, error positions, and
+// cancellation timings. It's exported so programs that depend on spara can
+// fuzz it as part of their own test suites, for as long as they like:
+//
+//	func FuzzSpara(f *testing.F) {
+//		sparatest.FuzzRunWithContext(f)
+//	}
+//
+// and then go test -fuzz=FuzzSpara. Under plain go test, it only runs the
+// seed corpus.
+func FuzzRunWithContext(f *testing.F) {
+	for _, c := range []RunCase{
+		{1, 0, -1, -1},
+		{1, 10, -1, -1},
+		{4, 100, -1, -1},
+		{100, 10, -1, -1},
+		{4, 100, 0, -1},
+		{4, 100, 99, -1},
+		{8, 100, -1, 1},
+		{8, 100, -1, 100},
+		{3, 1000, 500, 500},
+	} {
+		f.Add(uint8(c.Workers), uint16(c.Iterations), int16(c.FailAt), int16(c.CancelAt))
+	}
+	f.Fuzz(func(t *testing.T, workers uint8, iterations uint16, failAt, cancelAt int16) {
+		c := RunCase{
+			// Keep the runs small enough to get through plenty of them.
+			Workers:    int(workers%64) + 1,
+			Iterations: int(iterations % 4096),
+			FailAt:     max(int(failAt), -1),
+			CancelAt:   max(int(cancelAt), -1),
+		}
+		CheckRun(t, c)
+	})
+}
@@ -0,0 +1,16 @@
+package sparatest
+
+import "testing"
+
+func FuzzRun(f *testing.F) {
+	FuzzRunWithContext(f)
+}
+
+func TestCheckRun(t *testing.T) {
+	Stress(t, func(t *testing.T, workers int) {
+		CheckRun(t, RunCase{Workers: workers, Iterations: 100, FailAt: -1, CancelAt: -1})
+		CheckRun(t, RunCase{Workers: workers, Iterations: 100, FailAt: 50, CancelAt: -1})
+		CheckRun(t, RunCase{Workers: workers, Iterations: 100, FailAt: -1, CancelAt: 50})
+		CheckRun(t, RunCase{Workers: workers, Iterations: 100, FailAt: 20, CancelAt: 20})
+	}, Repeat(2))
+}
@@ -0,0 +1,125 @@
+package sparatest
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sparaPrefix is the prefix of the functions of spara and its subpackages,
+// as they appear in stack traces.
+const sparaPrefix = "github.com/heyimalex/spara"
+
+// LeakGrace is how long CheckLeaks gives goroutines to wind down before
+// reporting them. Some of spara's goroutines legitimately outlive the call
+// that started them by a moment, like the stragglers left behind by
+// EarlyReturn.
+var LeakGrace = 2 * time.Second
+
+// CheckLeaks fails the test if any goroutine started by spara, or by one of
+// its subpackages, is still running when the test ends, other than those
+// that were already running when CheckLeaks was called. It's called at the
+// start of a test:
+//
+//	func TestImport(t *testing.T) {
+//		sparatest.CheckLeaks(t)
+//		...
+//	}
+//
+// A goroutine counts as spara's if spara created it, so it catches a leaked
+// monitor goroutine, a worker stuck on an item after its run returned, or a
+// Pool that was never closed, without picking up goroutines the test started
+// itself. The failure includes each leaked goroutine's stack trace.
+//
+// Goroutines from other tests are indistinguishable from the test's own, so
+// CheckLeaks mustn't be used in tests that run in parallel with others that
+// use spara.
+func CheckLeaks(t testing.TB) {
+	t.Helper()
+	before := make(map[uint64]bool)
+	for _, g := range sparaGoroutines() {
+		before[g.id] = true
+	}
+	t.Cleanup(func() {
+		deadline := time.Now().Add(LeakGrace)
+		for {
+			var leaked []goroutine
+			for _, g := range sparaGoroutines() {
+				if !before[g.id] {
+					leaked = append(leaked, g)
+				}
+			}
+			if len(leaked) == 0 {
+				return
+			}
+			if time.Now().After(deadline) {
+				var b strings.Builder
+				for _, g := range leaked {
+					b.WriteString("\n\n")
+					b.WriteString(g.trace)
+				}
+				t.Errorf("%d goroutine(s) started by spara leaked:%s", len(leaked), b.String())
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+type goroutine struct {
+	id    uint64
+	trace string
+}
+
+// sparaGoroutines returns the running goroutines that were created by
+// spara or its subpackages, other than this one.
+func sparaGoroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var out []goroutine
+	for _, trace := range bytes.Split(buf, []byte("\n\n")) {
+		id, ok := parseGoroutineID(trace)
+		if !ok || !createdBySpara(trace) {
+			continue
+		}
+		out = append(out, goroutine{id: id, trace: string(trace)})
+	}
+	return out
+}
+
+// createdBySpara reports whether the goroutine with the given stack trace
+// was started by spara, according to its "created by" line. Goroutines
+// started by this package are its own business.
+func createdBySpara(trace []byte) bool {
+	_, creator, ok := bytes.Cut(trace, []byte("\ncreated by "))
+	if !ok {
+		return false
+	}
+	return bytes.HasPrefix(creator, []byte(sparaPrefix)) &&
+		!bytes.HasPrefix(creator, []byte(sparaPrefix+"/sparatest."))
+}
+
+// parseGoroutineID parses the id out of a stack trace header of the form
+// "goroutine 123 [running]:".
+func parseGoroutineID(trace []byte) (uint64, bool) {
+	trace, ok := bytes.CutPrefix(trace, []byte("goroutine "))
+	if !ok {
+		return 0, false
+	}
+	end := bytes.IndexByte(trace, ' ')
+	if end < 0 {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(string(trace[:end]), 10, 64)
+	return id, err == nil
+}
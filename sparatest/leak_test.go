@@ -0,0 +1,58 @@
+package sparatest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heyimalex/spara"
+)
+
+// recorder is a testing.TB that records failures instead of failing.
+type recorder struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (r *recorder) Helper()          {}
+func (r *recorder) Cleanup(f func()) { r.cleanups = append(r.cleanups, f) }
+func (r *recorder) Errorf(format string, a ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, a...))
+}
+
+func (r *recorder) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestCheckLeaks(t *testing.T) {
+	defer func(grace time.Duration) { LeakGrace = grace }(LeakGrace)
+	LeakGrace = 50 * time.Millisecond
+
+	r := &recorder{TB: t}
+	CheckLeaks(r)
+	err := spara.RunWithContext(context.Background(), 4, 100, func(ctx context.Context, i int) error { return nil })
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r.finish()
+	if len(r.errors) > 0 {
+		t.Errorf("expected no leaks from a finished run: %v", r.errors)
+	}
+
+	r = &recorder{TB: t}
+	CheckLeaks(r)
+	pool, err := spara.NewPool(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	r.finish()
+	pool.Close()
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "Pool") {
+		t.Errorf("expected the unclosed pool to be reported: %v", r.errors)
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package sparatest
+
+const raceEnabled = false
@@ -0,0 +1,7 @@
+//go:build race
+
+package sparatest
+
+// raceEnabled is whether the race detector is on, which makes everything
+// several times slower.
+const raceEnabled = true
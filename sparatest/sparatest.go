@@ -0,0 +1,3 @@
+// Package sparatest has helpers for testing code built on spara, for the
+// bugs that only turn up under particular amounts of concurrency.
+package sparatest
@@ -0,0 +1,110 @@
+package sparatest
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// StressOption configures Stress.
+type StressOption func(*stressConfig)
+
+type stressConfig struct {
+	workers []int
+	procs   []int
+	repeat  int
+}
+
+// Workers sets the worker counts Stress passes to the body. The default is
+// 1, 2, 3, 4, 8, and 64: one for the sequential case, a few small counts
+// that don't divide typical iteration counts evenly, and one likely to be
+// more than there are items.
+func Workers(counts ...int) StressOption {
+	return func(c *stressConfig) {
+		c.workers = counts
+	}
+}
+
+// Procs sets the GOMAXPROCS values Stress runs the body under. The default
+// is 1, 2, and runtime.NumCPU(), without duplicates: a single processor
+// finds the bugs that depend on goroutines being preempted at a bad moment,
+// and more than one finds those that need them to actually run at once.
+func Procs(values ...int) StressOption {
+	return func(c *stressConfig) {
+		c.procs = values
+	}
+}
+
+// Repeat sets how many times the body is run for each combination of
+// worker count and GOMAXPROCS. The default is 20, or 4 with the race
+// detector on or with -short, since the race detector is slow enough that
+// the full number takes too long, and it finds races on far fewer runs than
+// it takes to trip over one without it.
+func Repeat(n int) StressOption {
+	return func(c *stressConfig) {
+		c.repeat = n
+	}
+}
+
+// Stress runs body many times over, for every combination of worker count
+// and GOMAXPROCS value, each as a subtest named like "procs=2/workers=8", so
+// a failure says which combination it was under. The body is passed the
+// worker count to use, and fails the way any test does:
+//
+//	func TestSum(t *testing.T) {
+//		sparatest.Stress(t, func(t *testing.T, workers int) {
+//			var sum int64
+//			err := spara.RunWithContext(ctx, workers, 100, func(ctx context.Context, i int) error {
+//				atomic.AddInt64(&sum, int64(i))
+//				return nil
+//			})
+//			if err != nil || sum != 4950 {
+//				t.Fatalf("got %d, %v", sum, err)
+//			}
+//		})
+//	}
+//
+// Once the body fails under a combination, it isn't run again under that
+// combination, so one bug doesn't bury the output under copies of the same
+// failure. GOMAXPROCS is process wide, so Stress mustn't be called from
+// parallel tests, and the body mustn't call t.Parallel. It's restored once
+// Stress returns.
+func Stress(t *testing.T, body func(t *testing.T, workers int), opts ...StressOption) {
+	t.Helper()
+	c := stressConfig{
+		workers: []int{1, 2, 3, 4, 8, 64},
+		procs:   defaultProcs(),
+		repeat:  20,
+	}
+	if raceEnabled || testing.Short() {
+		c.repeat = 4
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, procs := range c.procs {
+		t.Run(fmt.Sprintf("procs=%d", procs), func(t *testing.T) {
+			runtime.GOMAXPROCS(procs)
+			for _, workers := range c.workers {
+				t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+					for i := 0; i < c.repeat && !t.Failed(); i++ {
+						body(t, workers)
+					}
+				})
+			}
+		})
+	}
+}
+
+// defaultProcs returns 1, 2, and the number of CPUs, without duplicates.
+func defaultProcs() []int {
+	procs := []int{1}
+	for _, n := range []int{2, runtime.NumCPU()} {
+		if n > procs[len(procs)-1] {
+			procs = append(procs, n)
+		}
+	}
+	return procs
+}
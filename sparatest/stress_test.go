@@ -0,0 +1,42 @@
+package sparatest
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/heyimalex/spara"
+)
+
+func TestStress(t *testing.T) {
+	procs := runtime.GOMAXPROCS(0)
+	var mu sync.Mutex
+	seen := make(map[[2]int]int)
+	Stress(t, func(t *testing.T, workers int) {
+		var sum int64
+		err := spara.RunWithContext(context.Background(), workers, 100, func(ctx context.Context, i int) error {
+			atomic.AddInt64(&sum, int64(i))
+			return nil
+		})
+		if err != nil || sum != 4950 {
+			t.Fatalf("got %d, %v", sum, err)
+		}
+		mu.Lock()
+		seen[[2]int{runtime.GOMAXPROCS(0), workers}]++
+		mu.Unlock()
+	}, Workers(1, 4), Procs(1, 3), Repeat(2))
+
+	if len(seen) != 4 {
+		t.Errorf("expected every combination to run: %v", seen)
+	}
+	for combination, n := range seen {
+		if n != 2 {
+			t.Errorf("expected %v to run twice: %d", combination, n)
+		}
+	}
+	if runtime.GOMAXPROCS(0) != procs {
+		t.Errorf("expected GOMAXPROCS to be restored")
+	}
+}
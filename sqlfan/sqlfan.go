@@ -0,0 +1,104 @@
+// Package sqlfan runs the same query or statement against a *sql.DB once per
+// set of parameters, concurrently, with spara's early cancellation. It's the
+// ETL pattern of looking up or writing a batch of keys without a query per
+// key in series, or one giant IN clause.
+//
+// Concurrency is bounded by the workers argument, but keep in mind that the
+// database's own connection pool limits still apply; workers beyond
+// db.SetMaxOpenConns just wait for a connection.
+package sqlfan
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/heyimalex/spara"
+)
+
+// Querier is the subset of *sql.DB used here, which *sql.Tx and *sql.Conn
+// also satisfy. Note that a *sql.Tx runs on a single connection, so fanning
+// out across one doesn't buy any concurrency on the database side.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ArgsFunc turns one item of the parameters slice into the query's
+// arguments.
+type ArgsFunc[P any] func(param P) []any
+
+// Each runs query once for every item in params, across up to workers
+// goroutines, and calls fn with the resulting rows. The rows are closed and
+// checked for iteration errors after fn returns, so fn only needs to call
+// Next and Scan. The first error from the query or fn cancels the rest and
+// is returned.
+func Each[P any](parent context.Context, workers int, db Querier, query string, params []P, args ArgsFunc[P], fn func(ctx context.Context, i int, rows *sql.Rows) error, opts ...spara.Option) error {
+	if args == nil || fn == nil {
+		return spara.ErrNilMappingFunction
+	}
+	opts = append([]spara.Option{spara.Workers(workers)}, opts...)
+	return spara.Do(parent, len(params), func(ctx context.Context, i int) error {
+		rows, err := db.QueryContext(ctx, query, args(params[i])...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if err := fn(ctx, i, rows); err != nil {
+			return err
+		}
+		return rows.Err()
+	}, opts...)
+}
+
+// Collect is like Each, but scans every row with scan and returns the rows
+// for each item of params in the same order as params, ie results[i] holds
+// the rows returned for params[i]. Rows from a failed attempt are dropped,
+// so with Retry an item only holds the rows of the attempt that succeeded.
+func Collect[P, R any](parent context.Context, workers int, db Querier, query string, params []P, args ArgsFunc[P], scan func(rows *sql.Rows) (R, error), opts ...spara.Option) ([][]R, error) {
+	if scan == nil {
+		return nil, spara.ErrNilMappingFunction
+	}
+	results := make([][]R, len(params))
+	err := Each(parent, workers, db, query, params, args, func(ctx context.Context, i int, rows *sql.Rows) error {
+		var scanned []R
+		for rows.Next() {
+			r, err := scan(rows)
+			if err != nil {
+				return err
+			}
+			scanned = append(scanned, r)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		results[i] = scanned
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Exec runs the statement query once for every item in params, across up to
+// workers goroutines, and returns the number of rows affected by each, in
+// the same order as params. The first error cancels the rest.
+func Exec[P any](parent context.Context, workers int, db Querier, query string, params []P, args ArgsFunc[P], opts ...spara.Option) ([]int64, error) {
+	if args == nil {
+		return nil, spara.ErrNilMappingFunction
+	}
+	affected := make([]int64, len(params))
+	opts = append([]spara.Option{spara.Workers(workers)}, opts...)
+	err := spara.Do(parent, len(params), func(ctx context.Context, i int) error {
+		result, err := db.ExecContext(ctx, query, args(params[i])...)
+		if err != nil {
+			return err
+		}
+		affected[i], err = result.RowsAffected()
+		return err
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return affected, nil
+}
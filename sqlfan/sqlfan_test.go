@@ -0,0 +1,184 @@
+package sqlfan
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/heyimalex/spara"
+)
+
+// fakeDriver understands three queries: "range" returns the integers [0, n)
+// for its single argument n, "flaky" is like range but fails halfway through
+// the rows the first time it sees each n, and "insert" affects n rows. A
+// negative n is an error.
+type fakeDriver struct {
+	mu   sync.Mutex
+	seen map[int64]bool
+}
+
+type fakeConn struct{ d *fakeDriver }
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+type fakeRows struct{ next, n, failAt int64 }
+type fakeResult int64
+
+var (
+	errNegative = errors.New("negative argument")
+	errFlaky    = errors.New("flaky rows")
+)
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return 1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	n := args[0].(int64)
+	if n < 0 {
+		return nil, errNegative
+	}
+	return fakeResult(n), nil
+}
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	n := args[0].(int64)
+	if n < 0 {
+		return nil, errNegative
+	}
+	rows := &fakeRows{n: n, failAt: -1}
+	if s.query == "flaky" {
+		s.d.mu.Lock()
+		if !s.d.seen[n] {
+			s.d.seen[n] = true
+			rows.failAt = n / 2
+		}
+		s.d.mu.Unlock()
+	}
+	return rows, nil
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == r.failAt {
+		return errFlaky
+	}
+	if r.next == r.n {
+		return io.EOF
+	}
+	dest[0] = r.next
+	r.next++
+	return nil
+}
+
+func (r fakeResult) LastInsertId() (int64, error) { return 0, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+var fake = &fakeDriver{seen: make(map[int64]bool)}
+
+func init() {
+	sql.Register("sqlfan-fake", fake)
+}
+
+func openFake(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlfan-fake", "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func asArgs(n int) []any { return []any{n} }
+
+func scanInt(rows *sql.Rows) (int, error) {
+	var v int
+	err := rows.Scan(&v)
+	return v, err
+}
+
+func TestCollect(t *testing.T) {
+	db := openFake(t)
+	params := []int{3, 0, 5, 1}
+	results, err := Collect(context.Background(), 3, db, "range", params, asArgs, scanInt)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i, n := range params {
+		if len(results[i]) != n {
+			t.Fatalf("expected %d rows for params[%d], got %v", n, i, results[i])
+		}
+		for j, v := range results[i] {
+			if v != j {
+				t.Errorf("unexpected rows for params[%d]: %v", i, results[i])
+				break
+			}
+		}
+	}
+}
+
+func TestCollectRetry(t *testing.T) {
+	db := openFake(t)
+	params := []int{4, 6, 8}
+	results, err := Collect(context.Background(), 2, db, "flaky", params, asArgs, scanInt, spara.Retry(2, nil))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i, n := range params {
+		if len(results[i]) != n {
+			t.Fatalf("expected %d rows for params[%d], got %v", n, i, results[i])
+		}
+		for j, v := range results[i] {
+			if v != j {
+				t.Errorf("unexpected rows for params[%d]: %v", i, results[i])
+				break
+			}
+		}
+	}
+}
+
+func TestEachError(t *testing.T) {
+	db := openFake(t)
+	err := Each(context.Background(), 2, db, "range", []int{1, -1, 2}, asArgs, func(ctx context.Context, i int, rows *sql.Rows) error {
+		for rows.Next() {
+		}
+		return nil
+	})
+	if !errors.Is(err, errNegative) {
+		t.Errorf("expected the query error: %v", err)
+	}
+
+	expectedError := errors.New("boom")
+	err = Each(context.Background(), 2, db, "range", []int{1, 2}, asArgs, func(ctx context.Context, i int, rows *sql.Rows) error {
+		return expectedError
+	})
+	if err != expectedError {
+		t.Errorf("expected fn's error: %v", err)
+	}
+}
+
+func TestExec(t *testing.T) {
+	db := openFake(t)
+	params := []int{4, 2, 7}
+	affected, err := Exec(context.Background(), 2, db, "insert", params, asArgs)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i, n := range params {
+		if affected[i] != int64(n) {
+			t.Errorf("unexpected rows affected: %v", affected)
+		}
+	}
+	if _, err := Exec(context.Background(), 2, db, "insert", []int{1, -1}, asArgs); !errors.Is(err, errNegative) {
+		t.Errorf("expected the exec error: %v", err)
+	}
+}
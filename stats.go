@@ -0,0 +1,290 @@
+package spara
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a timing breakdown of a single run, filled in by the WithStats
+// option. Latencies are measured around each call to the mapping function,
+// including any retries, so they're what the items actually cost.
+type Stats struct {
+	// Items is how many items were processed, successfully or not. It's
+	// less than the number of iterations if the run stopped early.
+	Items int
+	// Failed is how many of those items returned an error.
+	Failed int
+	// Wall is how long the whole run took.
+	Wall time.Duration
+	// Busy is the total time spent inside the mapping function, summed
+	// across workers. Busy / Wall is roughly how many workers were kept
+	// busy on average.
+	Busy time.Duration
+
+	Min    time.Duration
+	Median time.Duration
+	P95    time.Duration
+	Max    time.Duration
+
+	// Workers breaks the run down by worker, indexed by worker number. It
+	// includes every worker the run started, even those that never got an
+	// item.
+	Workers []WorkerStats
+
+	// Completions lists every item in the order it finished, but only if
+	// the RecordCompletions option was also given.
+	Completions []Completion
+
+	// Cancellation describes how quickly the run wound down after being
+	// canceled, by an error or its parent context. It's nil if the run
+	// wasn't canceled.
+	Cancellation *CancelStats
+}
+
+// CancelStats measures how long items that were in flight when a run was
+// canceled took to notice. Mapping functions that respect their context
+// return within moments; ones that take about as long as a normal item are
+// probably ignoring it.
+type CancelStats struct {
+	// Items is how many items returned after the cancellation.
+	Items int
+	// Latency is the time from the cancellation until the last of those
+	// items returned.
+	Latency time.Duration
+	// Slowest is the index of the item that took the longest to return, or
+	// -1 if nothing was in flight.
+	Slowest int
+}
+
+// Completion records when a single item ran, relative to the start of the
+// run. Plotting Started and Finished per worker shows how evenly the work was
+// spread, eg one worker stuck on a giant item while the others sat idle.
+type Completion struct {
+	Index    int
+	Worker   int
+	Started  time.Duration
+	Finished time.Duration
+	Failed   bool
+}
+
+// RecordCompletions makes WithStats and Hooks.OnRunEnd include the
+// Completions timeline in their Stats. It costs another small record per
+// item on top of what WithStats already keeps.
+func RecordCompletions() Option {
+	return func(c *config) {
+		c.completions = true
+	}
+}
+
+// WorkerStats is the share of a run handled by a single worker.
+type WorkerStats struct {
+	// Items is how many items the worker processed.
+	Items int
+	// Busy is the time the worker spent inside the mapping function, and
+	// Idle is the rest of the run's wall time, whether the worker was
+	// waiting to be scheduled or had already run out of items.
+	Busy time.Duration
+	Idle time.Duration
+}
+
+// Utilization returns the fraction of the run the worker spent busy.
+func (w WorkerStats) Utilization() float64 {
+	total := w.Busy + w.Idle
+	if total <= 0 {
+		return 0
+	}
+	return float64(w.Busy) / float64(total)
+}
+
+// Utilization returns the fraction of the available worker time that was
+// spent inside the mapping function. Low utilization with every worker
+// processing a similar number of items means workers were mostly waiting,
+// eg on a shared resource; low utilization with a few workers doing most of
+// the items means the work was unevenly split.
+func (s Stats) Utilization() float64 {
+	var busy, total time.Duration
+	for _, w := range s.Workers {
+		busy += w.Busy
+		total += w.Busy + w.Idle
+	}
+	if total <= 0 {
+		return 0
+	}
+	return float64(busy) / float64(total)
+}
+
+// Throughput returns the number of items processed per second of wall time.
+func (s Stats) Throughput() float64 {
+	if s.Wall <= 0 {
+		return 0
+	}
+	return float64(s.Items) / s.Wall.Seconds()
+}
+
+// String summarizes the run on a single line, eg "10k items in 24s, 3
+// failed, 410/s, median 12ms, p95 40ms, max 1.2s, 87% utilization", for
+// printing as is in a CLI or a log.
+func (s Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s items in %s", formatCount(float64(s.Items)), formatDuration(s.Wall))
+	if s.Failed > 0 {
+		fmt.Fprintf(&b, ", %d failed", s.Failed)
+	}
+	if s.Items == 0 {
+		return b.String()
+	}
+	if rate := s.Throughput(); rate > 0 {
+		fmt.Fprintf(&b, ", %s", formatRate(rate))
+	}
+	fmt.Fprintf(&b, ", median %s, p95 %s, max %s", formatDuration(s.Median), formatDuration(s.P95), formatDuration(s.Max))
+	if len(s.Workers) > 0 {
+		fmt.Fprintf(&b, ", %.0f%% utilization", s.Utilization()*100)
+	}
+	return b.String()
+}
+
+// WithStats fills in s with the run's statistics once it returns, replacing
+// whatever was there. Computing the percentiles means holding on to the
+// duration of every item until the run ends, so this costs a little memory
+// per item on very large runs.
+func WithStats(s *Stats) Option {
+	return func(c *config) {
+		if s != nil {
+			c.addObserver(&statsObserver{stats: s, c: c})
+		}
+	}
+}
+
+type statsObserver struct {
+	stats *Stats
+	c     *config
+
+	mu        sync.Mutex
+	durations []time.Duration
+	failed    int
+	workers   []WorkerStats
+
+	start       time.Time
+	completions []Completion
+
+	canceled     int32 // set atomically, so items can check without the lock
+	canceledAt   time.Time
+	cancellation *CancelStats
+}
+
+func (o *statsObserver) runStart(ctx context.Context, total int, start time.Time) {
+	o.mu.Lock()
+	o.durations = make([]time.Duration, 0, total)
+	o.failed = 0
+	o.workers = nil
+	o.start = start
+	o.completions = nil
+	atomic.StoreInt32(&o.canceled, 0)
+	o.cancellation = nil
+	o.mu.Unlock()
+}
+
+// workerStart makes room for every worker as it starts, so that workers
+// that never get an item are reported as idle rather than left out.
+func (o *statsObserver) workerStart(worker int) {
+	o.mu.Lock()
+	o.addWorker(worker)
+	o.mu.Unlock()
+}
+
+func (o *statsObserver) workerEnd(worker int) {}
+
+// addWorker grows workers to include worker. It must be called with the lock
+// held.
+func (o *statsObserver) addWorker(worker int) {
+	for len(o.workers) <= worker {
+		o.workers = append(o.workers, WorkerStats{})
+	}
+}
+
+func (o *statsObserver) itemDone(worker, index int, elapsed time.Duration, err error) {
+	o.mu.Lock()
+	o.durations = append(o.durations, elapsed)
+	if err != nil {
+		o.failed++
+	}
+	o.addWorker(worker)
+	o.workers[worker].Items++
+	o.workers[worker].Busy += elapsed
+	if atomic.LoadInt32(&o.canceled) == 1 {
+		if lag := since(o.c.getClock(), o.canceledAt); lag >= o.cancellation.Latency {
+			o.cancellation.Latency = lag
+			o.cancellation.Slowest = index
+		}
+		o.cancellation.Items++
+	}
+	if o.c.completions {
+		finished := since(o.c.getClock(), o.start)
+		o.completions = append(o.completions, Completion{
+			Index:    index,
+			Worker:   worker,
+			Started:  finished - elapsed,
+			Finished: finished,
+			Failed:   err != nil,
+		})
+	}
+	o.mu.Unlock()
+}
+
+func (o *statsObserver) runEnd(err error, elapsed time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	*o.stats = summarize(o.durations, o.failed, elapsed)
+	for i := range o.workers {
+		if idle := elapsed - o.workers[i].Busy; idle > 0 {
+			o.workers[i].Idle = idle
+		}
+	}
+	o.stats.Workers = o.workers
+	o.stats.Completions = o.completions
+	o.stats.Cancellation = o.cancellation
+	o.durations, o.workers, o.completions = nil, nil, nil
+}
+
+func (o *statsObserver) runCanceled(at time.Time) {
+	o.mu.Lock()
+	o.canceledAt = at
+	o.cancellation = &CancelStats{Slowest: -1}
+	atomic.StoreInt32(&o.canceled, 1)
+	o.mu.Unlock()
+}
+
+// summarize builds Stats from a set of item durations. It sorts durations in
+// place.
+func summarize(durations []time.Duration, failed int, wall time.Duration) Stats {
+	s := Stats{Items: len(durations), Failed: failed, Wall: wall}
+	if len(durations) == 0 {
+		return s
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	for _, d := range durations {
+		s.Busy += d
+	}
+	s.Min = durations[0]
+	s.Max = durations[len(durations)-1]
+	s.Median = percentile(durations, 0.5)
+	s.P95 = percentile(durations, 0.95)
+	return s
+}
+
+// percentile returns the nearest-rank percentile p of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,198 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithStats(t *testing.T) {
+	var stats Stats
+	err := Do(context.Background(), 20, func(ctx context.Context, i int) error {
+		time.Sleep(time.Duration(i) * time.Millisecond)
+		return nil
+	}, Workers(4), WithStats(&stats))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if stats.Items != 20 || stats.Failed != 0 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.Min > stats.Median || stats.Median > stats.P95 || stats.P95 > stats.Max {
+		t.Errorf("latencies out of order: %+v", stats)
+	}
+	if stats.Max < 19*time.Millisecond || stats.Busy < 190*time.Millisecond {
+		t.Errorf("latencies too small: %+v", stats)
+	}
+	if stats.Wall <= 0 || stats.Throughput() <= 0 {
+		t.Errorf("expected wall time and throughput: %+v", stats)
+	}
+}
+
+func TestWithStatsFailure(t *testing.T) {
+	var stats Stats
+	expectedError := errors.New("boom")
+	err := Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		if i == 3 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), WithStats(&stats))
+	if err != expectedError {
+		t.Fatalf("expected the error: %v", err)
+	}
+	if stats.Items != 4 || stats.Failed != 1 {
+		t.Errorf("expected the run to stop after 4 items: %+v", stats)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i + 1)
+	}
+	if p := percentile(sorted, 0.5); p != 50 {
+		t.Errorf("expected median of 50: %v", p)
+	}
+	if p := percentile(sorted, 0.95); p != 95 {
+		t.Errorf("expected p95 of 95: %v", p)
+	}
+	if p := percentile(sorted[:1], 0.95); p != 1 {
+		t.Errorf("expected single-element percentile of 1: %v", p)
+	}
+}
+
+func TestWithStatsWorkers(t *testing.T) {
+	var stats Stats
+	err := Do(context.Background(), 8, func(ctx context.Context, i int) error {
+		if i == 0 {
+			time.Sleep(40 * time.Millisecond)
+		}
+		return nil
+	}, Workers(2), WithStats(&stats))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(stats.Workers) != 2 {
+		t.Fatalf("expected stats for 2 workers: %+v", stats.Workers)
+	}
+	// Worker 0 is stuck on the slow item while worker 1 does the rest.
+	slow, fast := stats.Workers[0], stats.Workers[1]
+	if slow.Items != 1 || fast.Items != 7 {
+		t.Errorf("unexpected split of items: %+v", stats.Workers)
+	}
+	if slow.Utilization() < 0.5 || fast.Utilization() > 0.5 {
+		t.Errorf("expected worker 0 busy and worker 1 idle: %+v", stats.Workers)
+	}
+	if u := stats.Utilization(); u <= 0 || u > 1 {
+		t.Errorf("unexpected overall utilization: %v", u)
+	}
+}
+
+func TestWithStatsIdleWorkers(t *testing.T) {
+	// Whichever worker claims the only chunk does every item, leaving the
+	// rest with nothing to do.
+	var stats Stats
+	err := Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}, Workers(4), WithScheduler(Chunked(10)), WithStats(&stats))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(stats.Workers) != 4 {
+		t.Fatalf("expected stats for 4 workers: %+v", stats.Workers)
+	}
+	idle := 0
+	for _, w := range stats.Workers {
+		if w.Items == 0 && w.Busy == 0 && w.Idle == stats.Wall {
+			idle++
+		} else if w.Items != 10 {
+			t.Errorf("expected one worker to do every item: %+v", stats.Workers)
+		}
+	}
+	if idle != 3 {
+		t.Errorf("expected 3 workers idle for the whole run: %+v", stats.Workers)
+	}
+}
+
+func TestRecordCompletions(t *testing.T) {
+	var stats Stats
+	err := Do(context.Background(), 5, func(ctx context.Context, i int) error {
+		time.Sleep(time.Duration(5-i) * time.Millisecond)
+		return nil
+	}, Workers(1), WithStats(&stats), RecordCompletions())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(stats.Completions) != 5 {
+		t.Fatalf("expected 5 completions: %+v", stats.Completions)
+	}
+	for i, c := range stats.Completions {
+		if c.Index != i || c.Worker != 0 || c.Started > c.Finished {
+			t.Errorf("unexpected completion: %+v", c)
+		}
+		if i > 0 && c.Started < stats.Completions[i-1].Finished {
+			t.Errorf("items on a single worker can't overlap: %+v", stats.Completions)
+		}
+	}
+
+	if err := Do(context.Background(), 5, func(ctx context.Context, i int) error { return nil }, WithStats(&stats)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if stats.Completions != nil {
+		t.Errorf("expected no completions without RecordCompletions")
+	}
+}
+
+func TestWithStatsCancellation(t *testing.T) {
+	var stats Stats
+	started := make(chan struct{})
+	err := Do(context.Background(), 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			<-started
+			return errors.New("boom")
+		}
+		// Ignore cancellation for a while, like a badly behaved item.
+		close(started)
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}, Workers(2), WithStats(&stats))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	c := stats.Cancellation
+	if c == nil {
+		t.Fatalf("expected cancellation stats")
+	}
+	if c.Slowest != 1 || c.Items != 1 || c.Latency < 20*time.Millisecond {
+		t.Errorf("expected item 1 to be slow to cancel: %+v", c)
+	}
+
+	if err := Do(context.Background(), 2, func(ctx context.Context, i int) error { return nil }, WithStats(&stats)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if stats.Cancellation != nil {
+		t.Errorf("expected no cancellation stats for a run that completed: %+v", stats.Cancellation)
+	}
+}
+
+func TestStatsString(t *testing.T) {
+	stats := Stats{
+		Items:   10000,
+		Failed:  3,
+		Wall:    24400 * time.Millisecond,
+		Median:  12345 * time.Microsecond,
+		P95:     40 * time.Millisecond,
+		Max:     1234 * time.Millisecond,
+		Workers: []WorkerStats{{Busy: 87 * time.Second, Idle: 13 * time.Second}},
+	}
+	expected := "10k items in 24s, 3 failed, 410/s, median 12ms, p95 40ms, max 1.2s, 87% utilization"
+	if s := stats.String(); s != expected {
+		t.Errorf("expected %q: %q", expected, s)
+	}
+	if s := (Stats{Wall: time.Millisecond}).String(); s != "0 items in 1ms" {
+		t.Errorf("unexpected summary of an empty run: %q", s)
+	}
+}
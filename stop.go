@@ -0,0 +1,34 @@
+package spara
+
+// OnStop registers a function to call when the run is stopped before it
+// finishes, either by an error from the mapping function or by the parent
+// context completing, with the cause: the error, or context.Cause of the
+// parent. It's called exactly once, synchronously, right as spara cancels
+// the context it passed to the mapping function, and before any more of the
+// run is torn down. That makes it the place to abort whatever the context
+// can't reach on its own, like killing a subprocess or aborting a multipart
+// upload, at the same moment as everything else.
+//
+// A run that finishes without being stopped never calls it. With
+// CollectErrors, errors don't stop the run, so only the parent completing
+// does. It should return quickly, since the worker that failed, or the
+// goroutine watching the parent, is waiting on it.
+func OnStop(f func(cause error)) Option {
+	return func(c *config) {
+		c.onStop = f
+	}
+}
+
+// runStopped reports that the run has been stopped with err, which is what
+// it will return, because of cause.
+func (c *config) runStopped(err, cause error) {
+	if c == nil {
+		return
+	}
+	if c.onStop != nil {
+		c.onStop(cause)
+	}
+	if c.earlyStop != nil {
+		c.earlyStop(err)
+	}
+}
@@ -0,0 +1,59 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOnStop(t *testing.T) {
+	expectedError := errors.New("boom")
+	var calls int32
+	var got error
+	err := Do(context.Background(), 100, func(ctx context.Context, i int) error {
+		if i%10 == 0 {
+			return expectedError
+		}
+		return nil
+	}, Workers(8), OnStop(func(cause error) {
+		atomic.AddInt32(&calls, 1)
+		got = cause
+	}))
+	if err != expectedError {
+		t.Errorf("expected the error: %v", err)
+	}
+	if calls != 1 || got != expectedError {
+		t.Errorf("expected one call with the error: %d calls, %v", calls, got)
+	}
+
+	// The parent's cause is passed along, and the item can be aborted from
+	// the callback.
+	cause := errors.New("shutting down")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	started := make(chan struct{})
+	abort := make(chan struct{})
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- Do(ctx, 1, func(ctx context.Context, i int) error {
+			close(started)
+			<-abort
+			return nil
+		}, OnStop(func(err error) {
+			got = err
+			close(abort)
+		}))
+	}()
+	<-started
+	cancel(cause)
+	if err := <-stopped; err != context.Canceled {
+		t.Errorf("expected the parent's error: %v", err)
+	}
+	if got != cause {
+		t.Errorf("expected the parent's cause: %v", got)
+	}
+
+	// Runs that finish aren't stopped.
+	Do(context.Background(), 10, func(ctx context.Context, i int) error { return nil },
+		OnStop(func(error) { t.Errorf("unexpected call") }))
+}
@@ -0,0 +1,76 @@
+package spara
+
+import (
+	"context"
+)
+
+// MapStream transforms every item received from in with fn, across up to
+// workers goroutines, and sends the results on the returned channel. It
+// behaves exactly like FanOut, including the wait function and the need to
+// drain the output, and exists so the streaming operators read as a set.
+func MapStream[T, R any](parent context.Context, workers int, in <-chan T, fn func(ctx context.Context, item T) (R, error)) (<-chan R, func() error) {
+	return FanOut(parent, workers, in, fn)
+}
+
+// FilterStream passes along only the items received from in for which fn
+// returns true, calling fn across up to workers goroutines. Items arrive in
+// completion order. The returned wait function behaves as it does for
+// FanOut.
+func FilterStream[T any](parent context.Context, workers int, in <-chan T, fn func(ctx context.Context, item T) (bool, error)) (<-chan T, func() error) {
+	if workers <= 0 {
+		return failedChan[T](invalid(ErrInvalidWorkers, "workers", workers))
+	}
+	if fn == nil {
+		return failedChan[T](ErrNilMappingFunction)
+	}
+	if parent == nil {
+		return failedChan[T](ErrNilContext)
+	}
+	return emitChan(parent, workers, in, func(ctx context.Context, item T, emit func(T) error) error {
+		keep, err := fn(ctx, item)
+		if err != nil || !keep {
+			return err
+		}
+		return emit(item)
+	})
+}
+
+// ReduceStream folds every item received from in into a single value, across
+// up to workers goroutines. Each worker folds the items it receives into its
+// own accumulator, starting from the zero value of A, using fn; once in is
+// closed, the workers' accumulators are combined with merge. This means the
+// zero value of A must be an identity for merge, and the result is only
+// deterministic if fn and merge are associative and commutative, as for
+// sums, counts, minimums, and the like.
+//
+// ReduceStream blocks until in is closed, and stops early on the first error
+// in the same way as RunWithContext, in which case the partial result is
+// discarded.
+func ReduceStream[T, A any](parent context.Context, workers int, in <-chan T, fn func(ctx context.Context, acc A, item T) (A, error), merge func(a, b A) A) (A, error) {
+	var result A
+	if workers <= 0 {
+		return result, invalid(ErrInvalidWorkers, "workers", workers)
+	}
+	if fn == nil || merge == nil {
+		return result, ErrNilMappingFunction
+	}
+	if parent == nil {
+		return result, ErrNilContext
+	}
+	accs := make([]A, workers)
+	err := consumeChan(parent, workers, in, func(ctx context.Context, worker int, item T) error {
+		acc, err := fn(ctx, accs[worker], item)
+		if err != nil {
+			return err
+		}
+		accs[worker] = acc
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	for _, acc := range accs {
+		result = merge(result, acc)
+	}
+	return result, nil
+}
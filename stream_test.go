@@ -0,0 +1,66 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestMapFilterStream(t *testing.T) {
+	in := sendAll(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	squares, waitMap := MapStream(context.Background(), 3, in, func(ctx context.Context, x int) (int, error) {
+		return x * x, nil
+	})
+	even, waitFilter := FilterStream(context.Background(), 2, squares, func(ctx context.Context, x int) (bool, error) {
+		return x%2 == 0, nil
+	})
+	var results []int
+	for x := range even {
+		results = append(results, x)
+	}
+	if err := waitMap(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := waitFilter(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Ints(results)
+	expected := []int{4, 16, 36, 64, 100}
+	if len(results) != len(expected) {
+		t.Fatalf("results: %v != expected: %v", results, expected)
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatalf("results: %v != expected: %v", results, expected)
+		}
+	}
+}
+
+func TestReduceStream(t *testing.T) {
+	in := sendAll(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	sum, err := ReduceStream(context.Background(), 4, in, func(ctx context.Context, acc int, x int) (int, error) {
+		return acc + x, nil
+	}, func(a, b int) int {
+		return a + b
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sum != 55 {
+		t.Errorf("sum: %d != 55", sum)
+	}
+
+	expectedError := errors.New("")
+	_, err = ReduceStream(context.Background(), 4, sendAll(1, 2, 3), func(ctx context.Context, acc int, x int) (int, error) {
+		if x == 2 {
+			return 0, expectedError
+		}
+		return acc + x, nil
+	}, func(a, b int) int {
+		return a + b
+	})
+	if err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package spara
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNilTask = errors.New("spara: tasks must not contain nil")
+
+// Task is a single unit of work that can be executed by RunTasks.
+type Task interface {
+	Run(ctx context.Context) error
+}
+
+// NamedTask is a Task that can describe itself. If a NamedTask fails inside of
+// RunTasks, its error is wrapped in an *ItemError carrying the name.
+type NamedTask interface {
+	Task
+	Name() string
+}
+
+// TaskFunc is an adapter that allows the use of an ordinary function as a
+// Task.
+type TaskFunc func(ctx context.Context) error
+
+// Run calls f(ctx).
+func (f TaskFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// RunTasks runs every task concurrently across up to workers separate
+// goroutines. It has the same semantics as RunWithContext; the first task to
+// return an error stops iteration, cancels the context passed to any tasks
+// still in progress, and is returned once they complete.
+//
+// Tasks implementing NamedTask have their errors wrapped in an *ItemError.
+//
+// This is mostly a convenience for code that already models its jobs as
+// objects, which would otherwise need to be adapted through a closure over
+// the slice index.
+func RunTasks(parent context.Context, workers int, tasks []Task) error {
+	for _, task := range tasks {
+		if task == nil {
+			return ErrNilTask
+		}
+	}
+	name := func(index int) string {
+		if named, ok := tasks[index].(NamedTask); ok {
+			return named.Name()
+		}
+		return ""
+	}
+	return Do(parent, len(tasks), func(ctx context.Context, index int) error {
+		return tasks[index].Run(ctx)
+	}, Workers(workers), Names(name))
+}
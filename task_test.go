@@ -0,0 +1,79 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+type countingTask struct {
+	calls *int32
+}
+
+func (t countingTask) Run(ctx context.Context) error {
+	atomic.AddInt32(t.calls, 1)
+	return nil
+}
+
+func TestRunTasks(t *testing.T) {
+	var calls int32
+	tasks := make([]Task, 20)
+	for i := range tasks {
+		tasks[i] = countingTask{&calls}
+	}
+	if err := RunTasks(context.Background(), 4, tasks); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if calls != int32(len(tasks)) {
+		t.Errorf("number of tasks: %d != number of times called: %d", len(tasks), calls)
+	}
+}
+
+func TestRunTasksError(t *testing.T) {
+	expectedError := errors.New("")
+	tasks := []Task{
+		TaskFunc(func(ctx context.Context) error { return nil }),
+		TaskFunc(func(ctx context.Context) error { return expectedError }),
+	}
+	if err := RunTasks(context.Background(), 1, tasks); err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}
+
+func TestRunTasksNilTask(t *testing.T) {
+	tasks := []Task{TaskFunc(func(ctx context.Context) error { return nil }), nil}
+	if err := RunTasks(context.Background(), 1, tasks); err != ErrNilTask {
+		t.Errorf("expected calling RunTasks with a nil task to fail: %v", err)
+	}
+}
+
+type namedTask struct {
+	name string
+	err  error
+}
+
+func (t namedTask) Run(ctx context.Context) error { return t.err }
+func (t namedTask) Name() string                  { return t.name }
+
+func TestRunTasksNamedError(t *testing.T) {
+	expectedError := errors.New("boom")
+	tasks := []Task{
+		namedTask{name: "thumbnail:user-41"},
+		namedTask{name: "thumbnail:user-42", err: expectedError},
+	}
+	err := RunTasks(context.Background(), 1, tasks)
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) {
+		t.Fatalf("expected an *ItemError: %#v", err)
+	}
+	if itemErr.Name != "thumbnail:user-42" || itemErr.Index != 1 {
+		t.Errorf("unexpected item: index=%d name=%q", itemErr.Index, itemErr.Name)
+	}
+	if !errors.Is(err, expectedError) {
+		t.Errorf("error does not wrap the task's error: %v", err)
+	}
+	if got, want := err.Error(), "spara: thumbnail:user-42: boom"; got != want {
+		t.Errorf("err.Error() = %q, want %q", got, want)
+	}
+}
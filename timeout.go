@@ -0,0 +1,55 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrInvalidTimeout = errors.New("spara: invalid timeout")
+
+// Timeout limits how long the whole run may take. Once it's up, the run stops
+// as if the parent context had been canceled, and returns
+// context.DeadlineExceeded.
+func Timeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+		c.timeoutSet = true
+	}
+}
+
+// ItemTimeout limits how long each call to the mapping function may take, by
+// passing it a context with that deadline. With retries, each attempt gets
+// the full timeout. What happens when it's up is up to the mapping function;
+// typically it returns context.DeadlineExceeded, which fails the item like
+// any other error.
+func ItemTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.itemTimeout = d
+		c.itemTimeoutSet = true
+	}
+}
+
+// withTimeout applies the Timeout option to the run's parent context. The
+// returned cancel function must be called once the run is over.
+func (c *config) withTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	if c == nil || !c.timeoutSet {
+		return parent, func() {}
+	}
+	return withClockTimeout(parent, c.getClock(), c.timeout)
+}
+
+// withItemTimeout returns the middleware for the ItemTimeout option, or nil.
+func (c *config) withItemTimeout() Middleware {
+	if c == nil || !c.itemTimeoutSet {
+		return nil
+	}
+	d, clock := c.itemTimeout, c.getClock()
+	return func(fn MappingFunc) MappingFunc {
+		return func(ctx context.Context, index int) error {
+			ctx, cancel := withClockTimeout(ctx, clock, d)
+			defer cancel()
+			return fn(ctx, index)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrTrackerReused = errors.New("spara: tracker has already been used")
+
+// Progress is a snapshot of how far along a run is.
+type Progress struct {
+	// Done is the number of items processed so far, successfully or not.
+	Done int
+	// Total is the number of iterations.
+	Total int
+	// Failed is the number of items that returned an error.
+	Failed int
+	// Rate is the recent number of items processed per second, smoothed so
+	// it doesn't jump around with every update.
+	Rate float64
+	// Elapsed is how long the run has been going, or how long it took once
+	// it's over.
+	Elapsed time.Duration
+}
+
+// Remaining returns how many items are left to process.
+func (p Progress) Remaining() int {
+	if p.Done >= p.Total {
+		return 0
+	}
+	return p.Total - p.Done
+}
+
+// ETA estimates how much longer the run will take, based on the smoothed
+// rate, falling back to the average rate over the whole run if nothing has
+// finished recently. ok is false if there's nothing to base an estimate on
+// yet. The estimate assumes the remaining items cost about the same as the
+// recent ones; for inputs sorted by size, it will be off.
+func (p Progress) ETA() (eta time.Duration, ok bool) {
+	remaining := p.Remaining()
+	if remaining == 0 {
+		return 0, p.Total > 0 || p.Elapsed > 0
+	}
+	rate := p.Rate
+	if rate <= 0 && p.Done > 0 && p.Elapsed > 0 {
+		rate = float64(p.Done) / p.Elapsed.Seconds()
+	}
+	if rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Second)), true
+}
+
+// String summarizes the progress on a single line, eg "1.2k/10k done, 3
+// failed, 410/s, eta 21s", for printing as is in a CLI or a log. Parts that
+// don't apply are left out: failures if there aren't any, the rate and
+// estimate before there's anything to base them on, and the estimate once
+// the run is done.
+func (p Progress) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s/%s done", formatCount(float64(p.Done)), formatCount(float64(p.Total)))
+	if p.Failed > 0 {
+		fmt.Fprintf(&b, ", %d failed", p.Failed)
+	}
+	rate := p.Rate
+	if rate <= 0 && p.Done > 0 && p.Elapsed > 0 {
+		rate = float64(p.Done) / p.Elapsed.Seconds()
+	}
+	if rate > 0 {
+		fmt.Fprintf(&b, ", %s", formatRate(rate))
+	}
+	if p.Remaining() > 0 {
+		if eta, ok := p.ETA(); ok {
+			fmt.Fprintf(&b, ", eta %s", formatDuration(eta))
+		}
+	}
+	return b.String()
+}
+
+// Tracker follows a single run and reports its progress, either on demand
+// with Progress, or as a stream of updates from Updates. It's for feeding
+// status displays that live apart from the code starting the run, like a TUI
+// or a status endpoint; for a simple progress bar, WithProgress is easier.
+//
+//	t := spara.NewTracker(time.Second)
+//	go func() {
+//		for p := range t.Updates() {
+//			log.Printf("%d/%d done", p.Done, p.Total)
+//		}
+//	}()
+//	err := spara.Do(ctx, len(items), fn, spara.Track(t))
+type Tracker struct {
+	interval time.Duration
+	updates  chan Progress
+	used     int32
+	clock    Clock
+
+	done   int64
+	failed int64
+	total  int64
+	start  int64 // unix nanos
+	end    int64 // unix nanos, once the run is over
+
+	mu       sync.Mutex
+	rate     float64
+	lastDone int64
+	lastTick time.Time
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewTracker creates a Tracker that sends an update every interval while the
+// run is going. An interval of zero or less means once a second.
+func NewTracker(interval time.Duration) *Tracker {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Tracker{
+		interval: interval,
+		updates:  make(chan Progress, 1),
+		clock:    SystemClock,
+	}
+}
+
+// Track makes the run report its progress to t. A tracker can only follow one
+// run; passing it to a second fails with ErrTrackerReused. A run that's
+// rejected before it starts, eg because of an invalid option, uses the
+// tracker up all the same, closing Updates without sending anything.
+func Track(t *Tracker) Option {
+	return func(c *config) {
+		if t != nil {
+			c.addObserver(t)
+		}
+	}
+}
+
+// Updates returns a channel carrying the latest progress every interval, and
+// a final update when the run ends, after which it's closed. If the run is
+// rejected before it starts, it's closed without any updates. Updates are
+// never queued up behind a slow reader; if the last one hasn't been received
+// yet, it's replaced with the newer one.
+func (t *Tracker) Updates() <-chan Progress {
+	return t.updates
+}
+
+// Progress returns the run's current progress. Before the run starts, it's
+// all zeroes.
+func (t *Tracker) Progress() Progress {
+	p := Progress{
+		Done:   int(atomic.LoadInt64(&t.done)),
+		Failed: int(atomic.LoadInt64(&t.failed)),
+		Total:  int(atomic.LoadInt64(&t.total)),
+	}
+	if start := atomic.LoadInt64(&t.start); start != 0 {
+		if end := atomic.LoadInt64(&t.end); end != 0 {
+			p.Elapsed = time.Duration(end - start)
+		} else {
+			p.Elapsed = since(t.clock, time.Unix(0, start))
+		}
+	}
+	t.mu.Lock()
+	p.Rate = t.rate
+	t.mu.Unlock()
+	return p
+}
+
+// ETA estimates how much longer the run will take. See Progress.ETA.
+func (t *Tracker) ETA() (eta time.Duration, ok bool) {
+	return t.Progress().ETA()
+}
+
+// claim marks the tracker as used, and is how Do rejects a tracker being
+// passed to a second run.
+func (t *Tracker) claim() error {
+	if !atomic.CompareAndSwapInt32(&t.used, 0, 1) {
+		return ErrTrackerReused
+	}
+	return nil
+}
+
+// release undoes claim, for a run that claimed the tracker but was then
+// rejected.
+func (t *Tracker) release() {
+	atomic.StoreInt32(&t.used, 0)
+}
+
+// abandon uses the tracker up without a run, closing Updates, unless it's
+// already following one.
+func (t *Tracker) abandon() {
+	if atomic.CompareAndSwapInt32(&t.used, 0, 1) {
+		close(t.updates)
+	}
+}
+
+func (t *Tracker) useClock(clock Clock) {
+	t.clock = clock
+}
+
+func (t *Tracker) runStart(ctx context.Context, total int, start time.Time) {
+	atomic.StoreInt64(&t.total, int64(total))
+	atomic.StoreInt64(&t.start, start.UnixNano())
+	t.lastTick = start
+	t.stop = make(chan struct{})
+	t.stopped = make(chan struct{})
+	go t.loop()
+}
+
+func (t *Tracker) itemDone(worker, index int, elapsed time.Duration, err error) {
+	atomic.AddInt64(&t.done, 1)
+	if err != nil {
+		atomic.AddInt64(&t.failed, 1)
+	}
+}
+
+func (t *Tracker) runEnd(err error, elapsed time.Duration) {
+	close(t.stop)
+	<-t.stopped
+	now := t.clock.Now()
+	atomic.StoreInt64(&t.end, now.UnixNano())
+	t.tick(now)
+	t.publish()
+	close(t.updates)
+}
+
+func (t *Tracker) loop() {
+	defer close(t.stopped)
+	ticker := t.clock.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C():
+			t.tick(now)
+			t.publish()
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// rateSmoothing is the weight given to the latest interval when updating the
+// smoothed rate.
+const rateSmoothing = 0.3
+
+// tick updates the smoothed rate with the items processed since the last
+// tick.
+func (t *Tracker) tick(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dt := now.Sub(t.lastTick).Seconds()
+	if dt <= 0 {
+		return
+	}
+	done := atomic.LoadInt64(&t.done)
+	instant := float64(done-t.lastDone) / dt
+	if t.lastDone == 0 && t.rate == 0 {
+		t.rate = instant
+	} else {
+		t.rate = rateSmoothing*instant + (1-rateSmoothing)*t.rate
+	}
+	t.lastDone = done
+	t.lastTick = now
+}
+
+// publish replaces whatever update is waiting in the channel with the latest
+// progress. Only the loop and runEnd publish, and never at the same time, so
+// the send can't block.
+func (t *Tracker) publish() {
+	select {
+	case <-t.updates:
+	default:
+	}
+	t.updates <- t.Progress()
+}
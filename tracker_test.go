@@ -0,0 +1,172 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(10 * time.Millisecond)
+	var updates []Progress
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for p := range tracker.Updates() {
+			updates = append(updates, p)
+		}
+	}()
+
+	expectedError := errors.New("boom")
+	err := Do(context.Background(), 20, func(ctx context.Context, i int) error {
+		time.Sleep(5 * time.Millisecond)
+		if i == 9 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), Track(tracker))
+	if err != expectedError {
+		t.Fatalf("expected the error: %v", err)
+	}
+	<-received
+
+	if len(updates) < 2 {
+		t.Fatalf("expected several updates: %+v", updates)
+	}
+	final := updates[len(updates)-1]
+	if final.Total != 20 || final.Done != 10 || final.Failed != 1 {
+		t.Errorf("unexpected final update: %+v", final)
+	}
+	for i := 1; i < len(updates); i++ {
+		if updates[i].Done < updates[i-1].Done {
+			t.Errorf("progress went backwards: %+v", updates)
+		}
+	}
+	if final.Rate <= 0 || final.Elapsed <= 0 {
+		t.Errorf("expected a rate and elapsed time: %+v", final)
+	}
+
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 1, noop, Track(tracker)); err != ErrTrackerReused {
+		t.Errorf("expected ErrTrackerReused: %v", err)
+	}
+}
+
+// closed reports whether ch has been closed, without waiting on it.
+func closed(ch <-chan Progress) bool {
+	select {
+	case _, ok := <-ch:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func TestTrackerRejectedRun(t *testing.T) {
+	noop := func(ctx context.Context, i int) error { return nil }
+	tracker := NewTracker(time.Second)
+	if err := Do(context.Background(), 1, noop, Workers(0), Track(tracker)); !errors.Is(err, ErrInvalidWorkers) {
+		t.Fatalf("expected ErrInvalidWorkers: %v", err)
+	}
+	if !closed(tracker.Updates()) {
+		t.Errorf("expected Updates to be closed when the run is rejected")
+	}
+	if err := Do(context.Background(), 1, noop, Track(tracker)); err != ErrTrackerReused {
+		t.Errorf("expected ErrTrackerReused: %v", err)
+	}
+
+	// A tracker that's in use by another run is left to it, and the others
+	// are closed.
+	busy, fresh := NewTracker(time.Second), NewTracker(time.Second)
+	busy.claim()
+	if err := Do(context.Background(), 1, noop, Track(fresh), Track(busy)); err != ErrTrackerReused {
+		t.Fatalf("expected ErrTrackerReused: %v", err)
+	}
+	if !closed(fresh.Updates()) || closed(busy.Updates()) {
+		t.Errorf("expected only the unused tracker to be closed")
+	}
+
+	runner, err := New(Workers(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker = NewTracker(time.Second)
+	if err := runner.Run(context.Background(), 1, nil, Track(tracker)); err != ErrNilMappingFunction {
+		t.Fatalf("expected ErrNilMappingFunction: %v", err)
+	}
+	if !closed(tracker.Updates()) {
+		t.Errorf("expected Updates to be closed when the runner's run is rejected")
+	}
+}
+
+func TestTrackerClock(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	tracker := NewTracker(time.Minute)
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- Do(context.Background(), 2, func(ctx context.Context, i int) error {
+			<-release
+			return nil
+		}, Workers(2), Track(tracker), WithClock(clock))
+	}()
+	// The tracker's ticker is the only timer, so once it's there the run
+	// has started.
+	clock.waitFor(t, 1)
+	clock.advance(10 * time.Second)
+	if p := tracker.Progress(); p.Elapsed != 10*time.Second {
+		t.Errorf("expected 10s elapsed by the clock: %v", p.Elapsed)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	var final Progress
+	for p := range tracker.Updates() {
+		final = p
+	}
+	if final.Done != 2 || final.Elapsed != 10*time.Second || final.Rate != 0.2 {
+		t.Errorf("unexpected final update: %+v", final)
+	}
+	// Once the run is over, the elapsed time stops growing.
+	clock.advance(time.Minute)
+	if p := tracker.Progress(); p.Elapsed != 10*time.Second {
+		t.Errorf("expected the elapsed time to be frozen: %v", p.Elapsed)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	if _, ok := (Progress{Total: 10}).ETA(); ok {
+		t.Errorf("expected no estimate before anything is done")
+	}
+	eta, ok := Progress{Done: 5, Total: 15, Rate: 2}.ETA()
+	if !ok || eta != 5*time.Second {
+		t.Errorf("expected 5s from the smoothed rate: %v %v", eta, ok)
+	}
+	eta, ok = Progress{Done: 10, Total: 20, Elapsed: 5 * time.Second}.ETA()
+	if !ok || eta != 5*time.Second {
+		t.Errorf("expected 5s from the average rate: %v %v", eta, ok)
+	}
+	eta, ok = Progress{Done: 20, Total: 20, Rate: 2}.ETA()
+	if !ok || eta != 0 {
+		t.Errorf("expected a finished run to have no time left: %v %v", eta, ok)
+	}
+}
+
+func TestProgressString(t *testing.T) {
+	for _, test := range []struct {
+		p        Progress
+		expected string
+	}{
+		{Progress{Total: 10}, "0/10 done"},
+		{Progress{Done: 1234, Total: 10000, Failed: 3, Rate: 410}, "1.2k/10k done, 3 failed, 410/s, eta 21s"},
+		{Progress{Done: 10, Total: 20, Elapsed: 4 * time.Second}, "10/20 done, 2.5/s, eta 4s"},
+		{Progress{Done: 999960, Total: 2500000, Rate: 12345}, "1M/2.5M done, 12.3k/s, eta 2m2s"},
+		{Progress{Done: 20, Total: 20, Rate: 2}, "20/20 done, 2/s"},
+	} {
+		if s := test.p.String(); s != test.expected {
+			t.Errorf("expected %q: %q", test.expected, s)
+		}
+	}
+}
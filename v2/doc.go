@@ -0,0 +1,43 @@
+// Package spara is version 2 of github.com/heyimalex/spara, which
+// concurrently maps over slices and streams, with early cancellation on
+// error.
+//
+// Version 1 grew up around a single index-based loop, Run, with everything
+// else layered on top as it was needed, which left it with a large surface
+// that doesn't quite agree with itself: some functions take a worker count
+// and others take options, and a run can be watched through Hooks, WithStats,
+// WithProgress, Track, and half a dozen more. Version 2 puts the generic
+// functions first and takes the same options everywhere:
+//
+//	results, err := spara.Map(ctx, urls, fetch, spara.Workers(8), spara.Retry(3, backoff))
+//
+// Map, ForEach, MapChan, and ForEachChan cover most programs. Run is still
+// there, as the low-level primitive the others are built on, for work that
+// isn't naturally a slice or a channel. Watching a run goes through a single
+// interface, Observer.
+//
+// # Migrating from version 1
+//
+// Version 2 is built on version 1, rather than being a copy of it, and the
+// two can be imported side by side, so a program can move over a call site
+// at a time. The shared types, like Option, Stats, Progress, and Handle, are
+// aliases of version 1's, so values pass freely between the two, and every
+// version 1 option can be given to version 2 functions as is. The errors are
+// the same values, so errors.Is works across both.
+//
+// The version 1 functions map onto version 2 as follows:
+//
+//	Run(workers, n, fn)                  Run(ctx, n, fn, Workers(workers))
+//	RunWithContext(ctx, workers, n, fn)  Run(ctx, n, fn, Workers(workers))
+//	Do(ctx, n, fn, opts...)              Run(ctx, n, fn, opts...)
+//	Map(ctx, workers, items, fn)         Map(ctx, items, fn, Workers(workers))
+//	RunSlice(ctx, workers, items, fn)    ForEach(ctx, items, fn, Workers(workers))
+//	FanOut(ctx, workers, in, fn)         MapChan(ctx, in, fn, Workers(workers))
+//	MergeChan(ctx, workers, fn, chans)   ForEachChan(ctx, FanIn(ctx, chans...), fn, Workers(workers))
+//	Start(ctx, workers, n, fn, opts...)  Start(ctx, n, fn, Workers(workers), opts...)
+//	WithHooks(Hooks{...})                Observe(observer)
+//
+// Without a Workers option, runs use runtime.GOMAXPROCS(0) workers, as Do
+// does. Everything else in version 1, like Runner, Config, Pool, and the
+// pipeline package, works with version 2's options unchanged.
+package spara
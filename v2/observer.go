@@ -0,0 +1,47 @@
+package spara
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/heyimalex/spara"
+)
+
+// Observer follows a run, for plugging in metrics, logging, and tracing. It
+// takes the place of version 1's Hooks, WithStats, and WithProgress, which
+// are all things an Observer can do. Its methods are called from the run's
+// worker goroutines, so they must be safe to call concurrently, and ItemDone
+// adds directly to each item's latency, so it should be quick.
+type Observer interface {
+	// RunStart is called before any items are started, with the number
+	// of iterations.
+	RunStart(ctx context.Context, total int)
+	// ItemDone is called as each item finishes, with its error and how
+	// long it took, counting any retries.
+	ItemDone(index int, err error, elapsed time.Duration)
+	// RunEnd is called once the run is over, with the error it's about to
+	// return and its Stats.
+	RunEnd(err error, stats Stats)
+}
+
+// ItemStarter is implemented by Observers that also want to hear about items
+// as they start, which costs an extra call per item.
+type ItemStarter interface {
+	ItemStart(index int)
+}
+
+// Observe registers an Observer with the run. It can be given more than
+// once, and the observers are called in the order they were given.
+func Observe(o Observer) Option {
+	hooks := v1.Hooks{
+		OnRunStart: o.RunStart,
+		OnItemDone: func(index int, name string, err error, elapsed time.Duration) {
+			o.ItemDone(index, err, elapsed)
+		},
+		OnRunEnd: o.RunEnd,
+	}
+	if s, ok := o.(ItemStarter); ok {
+		hooks.OnItemStart = func(index int, name string) { s.ItemStart(index) }
+	}
+	return v1.WithHooks(hooks)
+}
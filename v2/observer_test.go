@@ -0,0 +1,47 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testObserver struct {
+	total         int
+	started, done int32
+	err           error
+	stats         Stats
+}
+
+func (o *testObserver) RunStart(ctx context.Context, total int) { o.total = total }
+func (o *testObserver) ItemStart(index int)                     { atomic.AddInt32(&o.started, 1) }
+
+func (o *testObserver) ItemDone(index int, err error, elapsed time.Duration) {
+	atomic.AddInt32(&o.done, 1)
+}
+
+func (o *testObserver) RunEnd(err error, stats Stats) {
+	o.err, o.stats = err, stats
+}
+
+func TestObserve(t *testing.T) {
+	expectedError := errors.New("boom")
+	o := &testObserver{}
+	err := Run(context.Background(), 10, func(ctx context.Context, i int) error {
+		if i == 9 {
+			return expectedError
+		}
+		return nil
+	}, Workers(1), Observe(o))
+	if err != expectedError {
+		t.Fatalf("expected the error: %v", err)
+	}
+	if o.total != 10 || o.started != 10 || o.done != 10 {
+		t.Errorf("expected every item to be observed: %+v", o)
+	}
+	if o.err != expectedError || o.stats.Items != 10 || o.stats.Failed != 1 {
+		t.Errorf("expected the run's result: %v %+v", o.err, o.stats)
+	}
+}
@@ -0,0 +1,122 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	v1 "github.com/heyimalex/spara"
+)
+
+// The types shared with version 1. They're aliases, so values of them can
+// be passed to either version.
+type (
+	Option      = v1.Option
+	MappingFunc = v1.MappingFunc
+	Middleware  = v1.Middleware
+	Backoff     = v1.Backoff
+	Stats       = v1.Stats
+	Progress    = v1.Progress
+	Handle      = v1.Handle
+	ItemError   = v1.ItemError
+	Clock       = v1.Clock
+)
+
+// The errors shared with version 1.
+var (
+	ErrInvalidWorkers     = v1.ErrInvalidWorkers
+	ErrInvalidIterations  = v1.ErrInvalidIterations
+	ErrNilMappingFunction = v1.ErrNilMappingFunction
+	ErrNilContext         = v1.ErrNilContext
+)
+
+// The options most runs need, which are version 1's. The rest are used from
+// version 1 directly; see its documentation for what each does.
+var (
+	Workers       = v1.Workers
+	Retry         = v1.Retry
+	RetryIf       = v1.RetryIf
+	Timeout       = v1.Timeout
+	ItemTimeout   = v1.ItemTimeout
+	CollectErrors = v1.CollectErrors
+	NoCancel      = v1.NoCancel
+	Sequential    = v1.Sequential
+	Use           = v1.Use
+	OnStop        = v1.OnStop
+	WithClock     = v1.WithClock
+
+	ConstantBackoff    = v1.ConstantBackoff
+	ExponentialBackoff = v1.ExponentialBackoff
+)
+
+// Run calls fn with every number in the range [0, n), and returns the first
+// error, canceling the context passed to any calls still in flight. It's the
+// primitive the rest of the package is built on, for work that doesn't come
+// as a slice or a channel, and is exactly version 1's Do.
+func Run(ctx context.Context, n int, fn MappingFunc, opts ...Option) error {
+	return v1.Do(ctx, n, fn, opts...)
+}
+
+// Map calls fn with every item of items, and returns the results in the same
+// order as the items. On error, the partial results are discarded, and Map
+// returns nil along with the error. With CollectErrors, it returns every
+// result, with the zero value for those that failed, along with all of the
+// errors.
+func Map[T, R any](ctx context.Context, items []T, fn func(ctx context.Context, item T) (R, error), opts ...Option) ([]R, error) {
+	if fn == nil {
+		return nil, ErrNilMappingFunction
+	}
+	out := make([]R, len(items))
+	err := v1.Do(ctx, len(items), func(ctx context.Context, index int) error {
+		result, err := fn(ctx, items[index])
+		if err != nil {
+			return err
+		}
+		out[index] = result
+		return nil
+	}, opts...)
+	if err != nil && !collecting(opts) {
+		return nil, err
+	}
+	return out, err
+}
+
+// ForEach calls fn with every item of items, and returns the first error.
+func ForEach[T any](ctx context.Context, items []T, fn func(ctx context.Context, item T) error, opts ...Option) error {
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	return v1.Do(ctx, len(items), func(ctx context.Context, index int) error {
+		return fn(ctx, items[index])
+	}, opts...)
+}
+
+// Start begins a run in the background, and returns a Handle for following
+// it. It's version 1's Start, with the worker count taken from the options.
+func Start(ctx context.Context, n int, fn MappingFunc, opts ...Option) *Handle {
+	// Invalid options are reported by the run itself, from Wait. Start
+	// overrides the worker count with the one it's given, so an invalid one
+	// has to be passed along as such.
+	w, err := workers(opts)
+	if errors.Is(err, ErrInvalidWorkers) {
+		w = 0
+	} else if err != nil {
+		w = 1
+	}
+	return v1.Start(ctx, w, n, fn, opts...)
+}
+
+// workers returns the number of workers the options ask for.
+func workers(opts []Option) (int, error) {
+	plan, err := v1.DryRun(math.MaxInt32, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return plan.Workers, nil
+}
+
+// collecting reports whether the options carry on past errors.
+func collecting(opts []Option) bool {
+	plan, err := v1.DryRun(0, opts...)
+	return err == nil && plan.ErrorPolicy == v1.CollectAllErrors
+}
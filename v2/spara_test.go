@@ -0,0 +1,82 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/heyimalex/spara"
+)
+
+func TestMap(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	results, err := Map(context.Background(), items, func(ctx context.Context, x int) (string, error) {
+		return strconv.Itoa(x * 2), nil
+	}, Workers(2))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i, r := range results {
+		if r != strconv.Itoa(items[i]*2) {
+			t.Fatalf("unexpected results: %v", results)
+		}
+	}
+
+	expectedError := errors.New("boom")
+	fail := func(ctx context.Context, x int) (int, error) {
+		if x%2 == 0 {
+			return 0, expectedError
+		}
+		return x, nil
+	}
+	if results, err := Map(context.Background(), items, fail); err != expectedError || results != nil {
+		t.Errorf("expected the error and no results: %v %v", results, err)
+	}
+	results2, err := Map(context.Background(), items, fail, CollectErrors())
+	if !errors.Is(err, expectedError) || len(results2) != len(items) || results2[0] != 1 || results2[1] != 0 {
+		t.Errorf("expected every result with the errors: %v %v", results2, err)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	var sum int64
+	err := ForEach(context.Background(), []int64{1, 2, 3}, func(ctx context.Context, x int64) error {
+		atomic.AddInt64(&sum, x)
+		return nil
+	})
+	if err != nil || sum != 6 {
+		t.Errorf("expected every item: %v %d", err, sum)
+	}
+	if err := ForEach[int](context.Background(), nil, nil); err != ErrNilMappingFunction {
+		t.Errorf("expected a nil function to fail: %v", err)
+	}
+}
+
+func TestRunAcceptsV1Options(t *testing.T) {
+	// Version 1 options and errors work unchanged.
+	var stats v1.Stats
+	err := Run(context.Background(), 10, func(ctx context.Context, i int) error { return nil },
+		v1.Workers(2), v1.WithStats(&stats))
+	if err != nil || stats.Items != 10 {
+		t.Errorf("expected the version 1 option to apply: %v %+v", err, stats)
+	}
+	if err := Run(context.Background(), 10, nil); !errors.Is(err, v1.ErrNilMappingFunction) {
+		t.Errorf("expected version 1's error: %v", err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	var calls int32
+	h := Start(context.Background(), 10, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, Workers(3))
+	if err := h.Wait(); err != nil || calls != 10 {
+		t.Errorf("expected every index: %v %d", err, calls)
+	}
+	if err := Start(context.Background(), 10, func(context.Context, int) error { return nil }, Workers(0)).Wait(); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected the invalid option to be reported: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package spara
+
+import (
+	"context"
+
+	v1 "github.com/heyimalex/spara"
+)
+
+// MapChan transforms every item received from in with fn, and sends the
+// results on the returned channel, in completion order. The caller must
+// drain the channel, and then call the returned wait function for the first
+// error. It's version 1's FanOut.
+//
+// Items from a channel are handled one at a time as they arrive, rather
+// than as a run over known indices, so of the options only the worker count
+// applies; the rest are ignored.
+func MapChan[T, R any](ctx context.Context, in <-chan T, fn func(ctx context.Context, item T) (R, error), opts ...Option) (<-chan R, func() error) {
+	n, err := workers(opts)
+	if err != nil {
+		out := make(chan R)
+		close(out)
+		return out, func() error { return err }
+	}
+	return v1.FanOut(ctx, n, in, fn)
+}
+
+// ForEachChan calls fn with every item received from in until it's closed,
+// and returns the first error. Options apply as they do for MapChan.
+func ForEachChan[T any](ctx context.Context, in <-chan T, fn func(ctx context.Context, item T) error, opts ...Option) error {
+	n, err := workers(opts)
+	if err != nil {
+		return err
+	}
+	return v1.MergeChan(ctx, n, fn, in)
+}
+
+// FanIn merges chans into a single channel. It's version 1's FanIn.
+func FanIn[T any](ctx context.Context, chans ...<-chan T) <-chan T {
+	return v1.FanIn(ctx, chans...)
+}
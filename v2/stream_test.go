@@ -0,0 +1,61 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestMapChan(t *testing.T) {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 0; i < 10; i++ {
+			in <- i
+		}
+	}()
+	out, wait := MapChan(context.Background(), in, func(ctx context.Context, x int) (int, error) {
+		return x * x, nil
+	}, Workers(4))
+	var results []int
+	for r := range out {
+		results = append(results, r)
+	}
+	if err := wait(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Ints(results)
+	for i, r := range results {
+		if r != i*i {
+			t.Fatalf("unexpected results: %v", results)
+		}
+	}
+
+	out, wait = MapChan(context.Background(), in, func(ctx context.Context, x int) (int, error) {
+		return x, nil
+	}, Workers(-1))
+	for range out {
+	}
+	if err := wait(); !errors.Is(err, ErrInvalidWorkers) {
+		t.Errorf("expected invalid options to fail: %v", err)
+	}
+}
+
+func TestForEachChan(t *testing.T) {
+	a, b := make(chan int, 3), make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		a <- i
+		b <- i
+	}
+	close(a)
+	close(b)
+	var sum int
+	err := ForEachChan(context.Background(), FanIn(context.Background(), a, b), func(ctx context.Context, x int) error {
+		sum += x
+		return nil
+	}, Workers(1))
+	if err != nil || sum != 6 {
+		t.Errorf("expected every item: %v %d", err, sum)
+	}
+}
@@ -0,0 +1,81 @@
+package spara
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrDispatchViolation is what VerifyDispatch panics with, wrapped with the
+// details, when an index isn't handed out exactly once.
+var ErrDispatchViolation = errors.New("spara: index dispatched other than exactly once")
+
+// VerifyEnv is the environment variable that, when set to a true value as
+// understood by strconv.ParseBool, makes every run in the process behave as
+// if it had been passed the VerifyDispatch option. It's read once, at
+// startup.
+const VerifyEnv = "SPARA_VERIFY"
+
+var forceVerify = envBool(VerifyEnv)
+
+// VerifyDispatch makes the run check its own bookkeeping: it records every
+// index it hands to the mapping function, and panics with an error wrapping
+// ErrDispatchViolation if any index is handed out twice, or is out of range,
+// or if a run that wasn't stopped early finishes with any index never handed
+// out at all. Every scheduler is meant to guarantee exactly that, so a panic
+// is a bug in spara; it's for tests, and for ruling spara out when a job
+// seems to be skipping or repeating work.
+// Setting SPARA_VERIFY=1 in the environment does the same for every run in
+// the process, including those started with Run, RunWithContext, and
+// RunNoErr.
+//
+// Verification costs an atomic counter per index, and an atomic add on each
+// dispatch.
+func VerifyDispatch() Option {
+	return func(c *config) {
+		c.verify = true
+	}
+}
+
+// dispatchVerifier counts how many times each index of a run is handed out.
+type dispatchVerifier struct {
+	counts []int32
+}
+
+// newVerifier returns a verifier for a run of iterations indices, or nil if
+// verification isn't on.
+func (c *config) newVerifier(iterations int) *dispatchVerifier {
+	if !forceVerify && (c == nil || !c.verify) {
+		return nil
+	}
+	return &dispatchVerifier{counts: make([]int32, iterations)}
+}
+
+// dispatch records that index has been handed out, panicking if that's
+// wrong.
+func (v *dispatchVerifier) dispatch(index int) {
+	if index < 0 || index >= len(v.counts) {
+		panic(fmt.Errorf("%w: index %d is out of range [0, %d)", ErrDispatchViolation, index, len(v.counts)))
+	}
+	if n := atomic.AddInt32(&v.counts[index], 1); n != 1 {
+		panic(fmt.Errorf("%w: index %d dispatched %d times", ErrDispatchViolation, index, n))
+	}
+}
+
+// complete checks that every index was handed out, for a run that wasn't
+// stopped early.
+func (v *dispatchVerifier) complete() {
+	var missing []int
+	for i := range v.counts {
+		if atomic.LoadInt32(&v.counts[i]) == 0 {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	if len(missing) > 10 {
+		panic(fmt.Errorf("%w: %d indices never dispatched, including %v", ErrDispatchViolation, len(missing), missing[:10]))
+	}
+	panic(fmt.Errorf("%w: indices %v never dispatched", ErrDispatchViolation, missing))
+}
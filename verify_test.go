@@ -0,0 +1,46 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVerifyDispatch(t *testing.T) {
+	expectedError := errors.New("boom")
+	schedulers := []Scheduler{Dynamic(), Chunked(7), Static(), WorkStealing(), Deterministic(1)}
+	for _, s := range schedulers {
+		for _, workers := range []int{1, 3, 8} {
+			err := Do(context.Background(), 101, func(ctx context.Context, i int) error { return nil },
+				Workers(workers), WithScheduler(s), VerifyDispatch())
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			// Runs stopped early don't have to get through everything.
+			err = Do(context.Background(), 101, func(ctx context.Context, i int) error {
+				if i == 5 {
+					return expectedError
+				}
+				return nil
+			}, Workers(workers), WithScheduler(s), VerifyDispatch())
+			if err != expectedError {
+				t.Fatalf("expected the error: %v", err)
+			}
+		}
+	}
+}
+
+func TestDispatchVerifier(t *testing.T) {
+	v := (&config{verify: true}).newVerifier(3)
+	v.dispatch(0)
+	v.dispatch(2)
+	expectPanic(t, ErrDispatchViolation, func() { v.dispatch(2) })
+	expectPanic(t, ErrDispatchViolation, func() { v.dispatch(3) })
+	expectPanic(t, ErrDispatchViolation, func() { v.complete() })
+	v.dispatch(1)
+	v.complete()
+
+	if (&config{}).newVerifier(3) != nil && !forceVerify {
+		t.Errorf("expected no verifier without the option")
+	}
+}
@@ -0,0 +1,173 @@
+package spara
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidInterval = errors.New("spara: invalid interval")
+
+// WithWatchdog watches for the run getting stuck. If items are in progress
+// but none has finished for interval, fn is called with how long it's been
+// since one did, and the stack traces of the run's worker goroutines, in the
+// same format as a panic. That's usually enough to see which lock or channel
+// a deadlocked mapping function is waiting on. fn is called once per stall,
+// from its own goroutine; if the run gets going again and then stalls again,
+// it's called again.
+//
+// Runs whose items legitimately take longer than interval will trip the
+// watchdog, so pick an interval well beyond the slowest expected item.
+func WithWatchdog(interval time.Duration, fn func(stalled time.Duration, stacks []byte)) Option {
+	return func(c *config) {
+		c.addObserver(&watchdog{interval: interval, fn: fn, clock: SystemClock})
+	}
+}
+
+type watchdog struct {
+	interval time.Duration
+	fn       func(stalled time.Duration, stacks []byte)
+	clock    Clock
+
+	inFlight int64
+	last     int64 // unix nanos of the last completion, or the start
+
+	mu         sync.Mutex
+	goroutines map[uint64]struct{}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (w *watchdog) validate() error {
+	if w.interval <= 0 {
+		return invalid(ErrInvalidInterval, "interval", w.interval)
+	}
+	if w.fn == nil {
+		return invalid(ErrInvalidInterval, "fn", nil)
+	}
+	return nil
+}
+
+func (w *watchdog) useClock(clock Clock) {
+	w.clock = clock
+}
+
+func (w *watchdog) runStart(ctx context.Context, total int, start time.Time) {
+	atomic.StoreInt64(&w.last, start.UnixNano())
+	w.goroutines = make(map[uint64]struct{})
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.loop()
+}
+
+func (w *watchdog) workerStart(worker int) {
+	id := goroutineID()
+	w.mu.Lock()
+	w.goroutines[id] = struct{}{}
+	w.mu.Unlock()
+}
+
+func (w *watchdog) workerEnd(worker int) {
+	id := goroutineID()
+	w.mu.Lock()
+	delete(w.goroutines, id)
+	w.mu.Unlock()
+}
+
+func (w *watchdog) itemStart(worker, index int) {
+	atomic.AddInt64(&w.inFlight, 1)
+}
+
+func (w *watchdog) itemDone(worker, index int, elapsed time.Duration, err error) {
+	atomic.StoreInt64(&w.last, w.clock.Now().UnixNano())
+	atomic.AddInt64(&w.inFlight, -1)
+}
+
+func (w *watchdog) runEnd(err error, elapsed time.Duration) {
+	close(w.stop)
+	<-w.done
+}
+
+func (w *watchdog) loop() {
+	defer close(w.done)
+	// Checking a few times per interval keeps the report reasonably close
+	// to the moment the run actually crossed the line.
+	check := w.interval / 4
+	if check < time.Millisecond {
+		check = time.Millisecond
+	}
+	ticker := w.clock.NewTicker(check)
+	defer ticker.Stop()
+	var reported int64
+	for {
+		select {
+		case now := <-ticker.C():
+			last := atomic.LoadInt64(&w.last)
+			stalled := now.Sub(time.Unix(0, last))
+			if last == reported || stalled < w.interval || atomic.LoadInt64(&w.inFlight) == 0 {
+				continue
+			}
+			reported = last
+			w.fn(stalled, w.stacks())
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// stacks returns the stack traces of the run's worker goroutines.
+func (w *watchdog) stacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out bytes.Buffer
+	for _, trace := range bytes.Split(buf, []byte("\n\n")) {
+		if id, ok := parseGoroutineID(trace); ok {
+			if _, ok := w.goroutines[id]; ok {
+				out.Write(trace)
+				out.WriteString("\n\n")
+			}
+		}
+	}
+	return out.Bytes()
+}
+
+// goroutineID returns the id of the calling goroutine, as it appears in
+// stack traces. The runtime deliberately doesn't expose this, but parsing it
+// out of the stack trace header is the only way to pick a particular
+// goroutine out of a full dump.
+func goroutineID() uint64 {
+	var buf [64]byte
+	id, _ := parseGoroutineID(buf[:runtime.Stack(buf[:], false)])
+	return id
+}
+
+// parseGoroutineID parses the id out of a stack trace header of the form
+// "goroutine 123 [running]:".
+func parseGoroutineID(trace []byte) (uint64, bool) {
+	trace, ok := bytes.CutPrefix(trace, []byte("goroutine "))
+	if !ok {
+		return 0, false
+	}
+	end := bytes.IndexByte(trace, ' ')
+	if end < 0 {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(string(trace[:end]), 10, 64)
+	return id, err == nil
+}
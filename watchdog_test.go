@@ -0,0 +1,61 @@
+package spara
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func stuckItem(release <-chan struct{}) {
+	<-release
+}
+
+func TestWithWatchdog(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	var reports int
+	var stacks []byte
+	err := Do(context.Background(), 4, func(ctx context.Context, i int) error {
+		if i == 2 {
+			stuckItem(release)
+		}
+		return nil
+	}, Workers(2), WithWatchdog(20*time.Millisecond, func(stalled time.Duration, s []byte) {
+		reports++
+		stacks = s
+		once.Do(func() { close(release) })
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if reports != 1 {
+		t.Fatalf("expected a single report: %d", reports)
+	}
+	if !bytes.Contains(stacks, []byte("stuckItem")) {
+		t.Errorf("expected the stuck worker's stack:\n%s", stacks)
+	}
+	if bytes.Contains(stacks, []byte("TestWithWatchdog(")) {
+		t.Errorf("expected only worker goroutines:\n%s", stacks)
+	}
+
+	noop := func(ctx context.Context, i int) error { return nil }
+	if err := Do(context.Background(), 1, noop, WithWatchdog(0, func(time.Duration, []byte) {})); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("expected ErrInvalidInterval: %v", err)
+	}
+}
+
+func TestParseGoroutineID(t *testing.T) {
+	id, ok := parseGoroutineID([]byte("goroutine 123 [running]:\nmain.main()"))
+	if !ok || id != 123 {
+		t.Errorf("unexpected id: %d %v", id, ok)
+	}
+	if _, ok := parseGoroutineID([]byte("garbage")); ok {
+		t.Errorf("expected garbage not to parse")
+	}
+	if goroutineID() == 0 {
+		t.Errorf("expected to find the current goroutine's id")
+	}
+}
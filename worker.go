@@ -0,0 +1,108 @@
+package spara
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type workerKey struct{}
+
+// workerInfo is what a worker knows about itself and the item it's working
+// on. A single one is attached to the context of each worker when it starts,
+// and updated in place as the worker moves from item to item, so it costs one
+// allocation per worker rather than one per item. The item fields are
+// accessed atomically, since anything the mapping function hands its context
+// to may read them from another goroutine.
+type workerInfo struct {
+	id      int
+	index   int64
+	attempt int32
+	sched   schedule               // for Yield
+	name    func(index int) string // from Names, for ItemName
+}
+
+// begin records that the worker is starting on index.
+func (w *workerInfo) begin(index int) {
+	atomic.StoreInt64(&w.index, int64(index))
+	atomic.StoreInt32(&w.attempt, 1)
+}
+
+// workerFrom returns the info for the worker ctx belongs to, or nil.
+func workerFrom(ctx context.Context) *workerInfo {
+	w, _ := ctx.Value(workerKey{}).(*workerInfo)
+	return w
+}
+
+// WorkerID returns the ID of the worker whose mapping function was called
+// with ctx, in the range [0, workers), and whether ctx belongs to a run at
+// all. Each worker keeps its ID for the whole run, and no two workers of a
+// run ever share one, so it can be used to index into per-worker resources,
+// like connections or buffers, without any locking:
+//
+//	conns := make([]*Conn, workers)
+//	err := spara.RunWithContext(ctx, workers, n, func(ctx context.Context, i int) error {
+//		id, _ := spara.WorkerID(ctx)
+//		if conns[id] == nil {
+//			conns[id] = dial()
+//		}
+//		return send(conns[id], items[i])
+//	})
+//
+// IDs are only unique within a run; nested runs have IDs of their own.
+func WorkerID(ctx context.Context) (int, bool) {
+	w := workerFrom(ctx)
+	if w == nil {
+		return 0, false
+	}
+	return w.id, true
+}
+
+// ItemIndex returns the index the mapping function was called with, for code
+// deep inside the mapping function that only has the context, like a logging
+// or tracing library, and whether ctx belongs to a run at all. Along with
+// RunName and Attempt, it identifies the unit of work being done.
+//
+// The index is only meaningful while the call it was passed to is in
+// progress; once that returns, the worker moves on to its next item, and the
+// index changes with it.
+//
+// Helpers whose functions aren't passed an index report the item's position
+// in the input instead: its index in the slice for Map, row*cols+col for
+// Run2D, and the order it was taken off the channel in for FanOut and the
+// other helpers that read from one.
+func ItemIndex(ctx context.Context) (int, bool) {
+	w := workerFrom(ctx)
+	if w == nil {
+		return 0, false
+	}
+	return int(atomic.LoadInt64(&w.index)), true
+}
+
+// ItemName returns the name the Names option gives the current item, for
+// code like ItemIndex's that wants to say which item it's working on in
+// terms a person would recognize. It returns the empty string if the run
+// doesn't name its items, or ctx doesn't belong to a run.
+func ItemName(ctx context.Context) string {
+	w := workerFrom(ctx)
+	if w == nil || w.name == nil {
+		return ""
+	}
+	return w.name(int(atomic.LoadInt64(&w.index)))
+}
+
+// Attempt returns which attempt at the current item the mapping function is
+// making, starting from 1, when retries are enabled with the Retry option. It
+// returns 1 without retries, and 0 if ctx doesn't belong to a run. Like
+// ItemIndex, it's only meaningful while the call is in progress.
+func Attempt(ctx context.Context) int {
+	w := workerFrom(ctx)
+	if w == nil {
+		return 0
+	}
+	return int(atomic.LoadInt32(&w.attempt))
+}
+
+// withWorker attaches a worker's info to its context.
+func withWorker(ctx context.Context, w *workerInfo) context.Context {
+	return context.WithValue(ctx, workerKey{}, w)
+}
@@ -0,0 +1,113 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestWorkerID(t *testing.T) {
+	if _, ok := WorkerID(context.Background()); ok {
+		t.Errorf("expected no worker ID outside of a run")
+	}
+
+	const workers = 4
+	var mu sync.Mutex
+	busy := make([]bool, workers)
+	err := RunWithContext(context.Background(), workers, 1000, func(ctx context.Context, i int) error {
+		id, ok := WorkerID(ctx)
+		if !ok || id < 0 || id >= workers {
+			t.Errorf("unexpected worker ID: %d, %v", id, ok)
+			return nil
+		}
+		// No two calls in flight at once share an ID.
+		mu.Lock()
+		if busy[id] {
+			t.Errorf("worker %d used concurrently", id)
+		}
+		busy[id] = true
+		mu.Unlock()
+
+		mu.Lock()
+		busy[id] = false
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nested runs get their own IDs.
+	err = RunWithContext(context.Background(), 1, 1, func(ctx context.Context, i int) error {
+		return RunWithContext(ctx, 3, 3, func(ctx context.Context, i int) error {
+			if id, _ := WorkerID(ctx); id != i {
+				t.Errorf("expected the nested worker's ID: %d != %d", id, i)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestItemMetadata(t *testing.T) {
+	if _, ok := ItemIndex(context.Background()); ok || Attempt(context.Background()) != 0 {
+		t.Errorf("expected no item metadata outside of a run")
+	}
+
+	var mu sync.Mutex
+	attempts := make(map[int][]int)
+	err := Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		index, ok := ItemIndex(ctx)
+		if !ok || index != i {
+			t.Errorf("expected the item's index: %d != %d", index, i)
+		}
+		if RunName(ctx) != "sync" {
+			t.Errorf("expected the run name: %q", RunName(ctx))
+		}
+		attempt := Attempt(ctx)
+		mu.Lock()
+		attempts[i] = append(attempts[i], attempt)
+		mu.Unlock()
+		if i%2 == 0 && attempt < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	}, Workers(3), Named("sync"), Retry(3, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, seen := range attempts {
+		expected := []int{1}
+		if i%2 == 0 {
+			expected = []int{1, 2, 3}
+		}
+		if fmt.Sprint(seen) != fmt.Sprint(expected) {
+			t.Errorf("index %d: unexpected attempts: %v", i, seen)
+		}
+	}
+}
+
+func TestItemName(t *testing.T) {
+	if ItemName(context.Background()) != "" {
+		t.Errorf("expected no name outside of a run")
+	}
+	err := Do(context.Background(), 10, func(ctx context.Context, i int) error {
+		if name := ItemName(ctx); name != fmt.Sprint("item-", i) {
+			t.Errorf("expected the item's name: %q", name)
+		}
+		return nil
+	}, Workers(3), Names(func(i int) string { return fmt.Sprint("item-", i) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Do(context.Background(), 1, func(ctx context.Context, i int) error {
+		if name := ItemName(ctx); name != "" {
+			t.Errorf("expected no name without Names: %q", name)
+		}
+		return nil
+	})
+}
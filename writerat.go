@@ -0,0 +1,41 @@
+package spara
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNilWriter = errors.New("spara: writer must not be nil")
+
+// RunWriterAt calls fn with every number in the range [0, iterations) across
+// up to workers goroutines, like RunWithContext, and writes the data each
+// call returns to w at the offset it returns. This is the write side of
+// RunReaderAt; parallel downloads and multipart style assembly can compute
+// each piece concurrently and have it land in the right place, without any
+// coordination between the pieces.
+//
+// Writes happen concurrently, so w must support concurrent calls to WriteAt
+// for non-overlapping ranges, as *os.File does. fn is free to reuse data once
+// the write has completed, ie on its next call. A write that comes up short
+// without an error is reported as io.ErrShortWrite. Otherwise, error handling
+// is the same as RunWithContext.
+func RunWriterAt(parent context.Context, workers int, w io.WriterAt, iterations int, fn func(ctx context.Context, index int) (offset int64, data []byte, err error)) error {
+	if w == nil {
+		return ErrNilWriter
+	}
+	if fn == nil {
+		return ErrNilMappingFunction
+	}
+	return RunWithContext(parent, workers, iterations, func(ctx context.Context, index int) error {
+		offset, data, err := fn(ctx, index)
+		if err != nil {
+			return err
+		}
+		n, err := w.WriteAt(data, offset)
+		if err == nil && n < len(data) {
+			err = io.ErrShortWrite
+		}
+		return err
+	})
+}
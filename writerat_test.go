@@ -0,0 +1,63 @@
+package spara
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// buffer is a minimal in-memory io.WriterAt.
+type buffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *buffer) WriteAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if end := int(off) + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	return copy(b.data[off:], p), nil
+}
+
+func TestRunWriterAt(t *testing.T) {
+	expected := make([]byte, 1000)
+	for i := range expected {
+		expected[i] = byte(i)
+	}
+	const chunkSize = 64
+	chunks := (len(expected) + chunkSize - 1) / chunkSize
+
+	var w buffer
+	err := RunWriterAt(context.Background(), 4, &w, chunks, func(ctx context.Context, index int) (int64, []byte, error) {
+		start := index * chunkSize
+		end := start + chunkSize
+		if end > len(expected) {
+			end = len(expected)
+		}
+		return int64(start), expected[start:end], nil
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(w.data, expected) {
+		t.Errorf("chunks were not assembled in place")
+	}
+}
+
+func TestRunWriterAtError(t *testing.T) {
+	expectedError := errors.New("")
+	var w buffer
+	err := RunWriterAt(context.Background(), 2, &w, 10, func(ctx context.Context, index int) (int64, []byte, error) {
+		if index == 5 {
+			return 0, nil, expectedError
+		}
+		return int64(index), []byte{byte(index)}, nil
+	})
+	if err != expectedError {
+		t.Errorf("did not return the expected error: %v", err)
+	}
+}